@@ -0,0 +1,337 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/Songmu/flextime"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer"
+	"github.com/aws/aws-sdk-go-v2/service/organizations"
+	"github.com/slack-go/slack"
+
+	"github.com/mashiike/aws-cost-anomaly-slack-reactor/reactor"
+)
+
+// handlerOptions returns the options of reactor.New given by the config file
+// and the flags. A non-empty devDir enables the development mode.
+func handlerOptions(cfg *reactor.Config, dynamodbTableName string, devDir string) ([]reactor.Option, error) {
+	var opts []reactor.Option
+	if cfg != nil {
+		cfgOpts, err := cfg.Options()
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, cfgOpts...)
+	}
+	if dynamodbTableName != "" {
+		opts = append(opts, reactor.WithDynamoDBTableName(dynamodbTableName))
+	}
+	if devDir != "" {
+		opts = append(opts, reactor.WithDevMode(devDir))
+	}
+	return opts, nil
+}
+
+// validate checks the config file and the environment without starting the
+// server.
+func validate(cfg *reactor.Config, dynamodbTableName string) error {
+	if cfg == nil {
+		cfg = &reactor.Config{}
+	}
+	if dynamodbTableName != "" {
+		cfg.Storage.DynamoDBTableName = dynamodbTableName
+	}
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("invalid config:\n%w", err)
+	}
+	fmt.Println("config is valid")
+	return nil
+}
+
+// render prints the message template rendered for a sample anomaly, and
+// fails when the message breaks the Block Kit limits.
+func render(ctx context.Context, cfg *reactor.Config, args []string) error {
+	fs := flag.NewFlagSet("render", flag.ExitOnError)
+	var (
+		templatePath string
+		anomalyPath  string
+		builderURL   bool
+	)
+	fs.StringVar(&templatePath, "template", "", "message template file (default: templates.message of the config, or the built-in template)")
+	fs.StringVar(&anomalyPath, "anomaly", "", "anomaly JSON file (SNS notification or raw), or - for stdin (required)")
+	fs.BoolVar(&builderURL, "url", false, "print a Block Kit Builder URL instead of the JSON")
+	fs.Parse(args)
+	if anomalyPath == "" {
+		fs.Usage()
+		return errors.New("-anomaly is required")
+	}
+	var text string
+	switch {
+	case templatePath != "":
+		bs, err := os.ReadFile(templatePath)
+		if err != nil {
+			return fmt.Errorf("failed to read template: %w", err)
+		}
+		text = string(bs)
+	case cfg != nil:
+		var err error
+		if text, err = cfg.MessageTemplate(); err != nil {
+			return err
+		}
+	}
+	payload, err := readPayload(anomalyPath)
+	if err != nil {
+		return fmt.Errorf("failed to read anomaly: %w", err)
+	}
+	a, err := reactor.ParseAnomalyNotification(payload)
+	if err != nil {
+		return err
+	}
+	msg, err := reactor.RenderMessage(ctx, text, a)
+	if err != nil {
+		return err
+	}
+	if builderURL {
+		u, err := reactor.BlockKitBuilderLink(msg)
+		if err != nil {
+			return err
+		}
+		fmt.Println(u)
+	} else {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.SetEscapeHTML(false)
+		payload := struct {
+			Text        string             `json:"text,omitempty"`
+			Blocks      slack.Blocks       `json:"blocks,omitzero"`
+			Attachments []slack.Attachment `json:"attachments,omitempty"`
+		}{msg.Text, msg.Blocks, msg.Attachments}
+		if err := enc.Encode(payload); err != nil {
+			return fmt.Errorf("failed to encode message: %w", err)
+		}
+	}
+	if err := reactor.ValidateBlockKit(msg); err != nil {
+		return fmt.Errorf("message breaks the Block Kit limits:\n%w", err)
+	}
+	return nil
+}
+
+// readPayload reads the file at path, or stdin when path is "-".
+func readPayload(path string) ([]byte, error) {
+	if path == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+	return os.ReadFile(path)
+}
+
+// replay pushes a saved anomaly notification through the whole pipeline. With
+// -dry-run, the messages and graphs are written to files instead of Slack.
+func replay(ctx context.Context, opts []reactor.Option, args []string) error {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s replay [flags] <anomaly.json>\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	var (
+		dryRun bool
+		output string
+	)
+	fs.BoolVar(&dryRun, "dry-run", false, "write the messages and graphs to the output directory instead of posting them")
+	fs.StringVar(&output, "output", "replay", "output directory of -dry-run")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("an anomaly JSON file is required")
+	}
+	payload, err := readPayload(fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("failed to read anomaly: %w", err)
+	}
+	if dryRun {
+		opts = append(opts, reactor.WithDryRun(output))
+	}
+	h, err := reactor.New(ctx, opts...)
+	if err != nil {
+		return err
+	}
+	a, err := h.Replay(ctx, payload)
+	if err != nil {
+		return fmt.Errorf("failed to replay anomaly: %w", err)
+	}
+	if dryRun {
+		fmt.Printf("replayed anomaly %s into %s\n", a.AnomalyID, output)
+	} else {
+		fmt.Printf("replayed anomaly %s\n", a.AnomalyID)
+	}
+	return nil
+}
+
+// backfill posts the anomalies detected since the given date that are not in
+// the state store yet. The state store is required unless -dry-run is given,
+// since without it every anomaly of the period would be posted again.
+func backfill(ctx context.Context, opts []reactor.Option, args []string) error {
+	fs := flag.NewFlagSet("backfill", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s -dynamodb-table-name <table> backfill [flags]\n", os.Args[0])
+		fmt.Fprintln(fs.Output(), "The DynamoDB table is required unless -dry-run is given; the anomalies recorded in it are skipped.")
+		fs.PrintDefaults()
+	}
+	var (
+		since      string
+		until      string
+		monitorArn string
+		interval   time.Duration
+		dryRun     bool
+		output     string
+	)
+	fs.StringVar(&since, "since", "", "first anomaly start date to post (YYYY-MM-DD, required)")
+	fs.StringVar(&until, "until", "", "last anomaly start date to post (YYYY-MM-DD, default today)")
+	fs.StringVar(&monitorArn, "monitor-arn", "", "post only the anomalies of this monitor")
+	fs.DurationVar(&interval, "interval", reactor.DefaultBackfillInterval, "minimum interval between two posted anomalies")
+	fs.BoolVar(&dryRun, "dry-run", false, "write the messages and graphs to the output directory instead of posting them")
+	fs.StringVar(&output, "output", "backfill", "output directory of -dry-run")
+	fs.Parse(args)
+	if since == "" {
+		fs.Usage()
+		return errors.New("-since is required")
+	}
+	in := reactor.BackfillInput{
+		MonitorArn: monitorArn,
+		Interval:   interval,
+	}
+	var err error
+	if in.Since, err = time.Parse(time.DateOnly, since); err != nil {
+		return fmt.Errorf("invalid -since: %w", err)
+	}
+	if until != "" {
+		if in.Until, err = time.Parse(time.DateOnly, until); err != nil {
+			return fmt.Errorf("invalid -until: %w", err)
+		}
+	}
+	if dryRun {
+		opts = append(opts, reactor.WithDryRun(output))
+	}
+	h, err := reactor.New(ctx, opts...)
+	if err != nil {
+		return err
+	}
+	if !dryRun && !h.EnableDynamoDB() {
+		fs.Usage()
+		return errors.New("backfill requires the DynamoDB table (-dynamodb-table-name or storage.dynamodbTableName) unless -dry-run is given")
+	}
+	posted, err := h.Backfill(ctx, in)
+	for _, a := range posted {
+		fmt.Printf("posted anomaly %s (%s)\n", a.AnomalyID, a.AnomalyStartDate.Format(time.DateOnly))
+	}
+	if err != nil {
+		return fmt.Errorf("failed to backfill anomalies: %w", err)
+	}
+	fmt.Printf("backfilled %d anomalies\n", len(posted))
+	return nil
+}
+
+// graph writes cost graphs to local files, either for the given dimension
+// filters and period or for every root cause of an anomaly.
+func graph(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("graph", flag.ExitOnError)
+	var (
+		rc          reactor.RootCause
+		start, end  string
+		anomalyPath string
+		format      string
+		output      string
+	)
+	fs.StringVar(&rc.Service, "service", "", "service, e.g. \"Amazon Elastic Compute Cloud - Compute\"")
+	fs.StringVar(&rc.LinkedAccount, "account", "", "linked account ID; without it the cost is grouped by account")
+	fs.StringVar(&rc.Region, "region", "", "region, e.g. us-east-1")
+	fs.StringVar(&rc.UsageType, "usage-type", "", "usage type")
+	fs.StringVar(&start, "start", "", "start date (YYYY-MM-DD, default: 30 days before -end)")
+	fs.StringVar(&end, "end", "", "end date (YYYY-MM-DD, default: today)")
+	fs.StringVar(&anomalyPath, "anomaly", "", "anomaly JSON file (SNS notification or raw) to graph every root cause of, instead of the filters")
+	fs.StringVar(&format, "format", "png", "image format: png, svg or pdf")
+	fs.StringVar(&output, "output", "", "output file, or the output directory with -anomaly (default: graph.<format>, or the current directory)")
+	fs.Parse(args)
+	switch format {
+	case "png", "svg", "pdf":
+	default:
+		return fmt.Errorf("unsupported format: %s", format)
+	}
+
+	awsCfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to load aws config: %w", err)
+	}
+	gen := reactor.NewGraphGenerator(costexplorer.NewFromConfig(awsCfg), organizations.NewFromConfig(awsCfg))
+
+	if anomalyPath != "" {
+		payload, err := readPayload(anomalyPath)
+		if err != nil {
+			return fmt.Errorf("failed to read anomaly: %w", err)
+		}
+		a, err := reactor.ParseAnomalyNotification(payload)
+		if err != nil {
+			return err
+		}
+		startAt, endAt := a.GraphPeriod()
+		for i, c := range a.RootCauses {
+			path := filepath.Join(output, fmt.Sprintf("anomaly-%s-root-cause%d.%s", a.AnomalyID, i+1, format))
+			if err := writeGraph(ctx, gen, startAt, endAt, c, format, path); err != nil {
+				return fmt.Errorf("root cause #%d: %w", i+1, err)
+			}
+		}
+		return nil
+	}
+
+	endAt := flextime.Now().UTC().Truncate(24 * time.Hour)
+	if end != "" {
+		if endAt, err = time.Parse(time.DateOnly, end); err != nil {
+			return fmt.Errorf("invalid -end: %w", err)
+		}
+	}
+	startAt := endAt.AddDate(0, 0, -30)
+	if start != "" {
+		if startAt, err = time.Parse(time.DateOnly, start); err != nil {
+			return fmt.Errorf("invalid -start: %w", err)
+		}
+	}
+	if !startAt.Before(endAt) {
+		return errors.New("-start must be before -end")
+	}
+	if output == "" {
+		output = "graph." + format
+	}
+	return writeGraph(ctx, gen, startAt, endAt, rc, format, output)
+}
+
+func writeGraph(ctx context.Context, gen *reactor.GraphGenerator, startAt, endAt time.Time, c reactor.RootCause, format string, path string) error {
+	w, err := gen.GenerateRange(ctx, startAt, endAt, c, format)
+	if err != nil {
+		return err
+	}
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return fmt.Errorf("failed to create directory: %w", err)
+		}
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	if _, err := w.WriteTo(f); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	fmt.Println(path)
+	return nil
+}
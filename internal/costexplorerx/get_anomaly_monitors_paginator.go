@@ -0,0 +1,58 @@
+package costexplorerx
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer"
+)
+
+// GetAnomalyMonitorsAPIClient is the subset of the Cost Explorer client
+// used by GetAnomalyMonitorsPaginator.
+type GetAnomalyMonitorsAPIClient interface {
+	GetAnomalyMonitors(context.Context, *costexplorer.GetAnomalyMonitorsInput, ...func(*costexplorer.Options)) (*costexplorer.GetAnomalyMonitorsOutput, error)
+}
+
+var _ GetAnomalyMonitorsAPIClient = (*costexplorer.Client)(nil)
+
+// GetAnomalyMonitorsPaginator paginates over Cost Explorer GetAnomalyMonitors
+// results.
+type GetAnomalyMonitorsPaginator struct {
+	client    GetAnomalyMonitorsAPIClient
+	params    *costexplorer.GetAnomalyMonitorsInput
+	nextToken *string
+	firstPage bool
+}
+
+// NewGetAnomalyMonitorsPaginator returns a new paginator for
+// GetAnomalyMonitors.
+func NewGetAnomalyMonitorsPaginator(client GetAnomalyMonitorsAPIClient, params *costexplorer.GetAnomalyMonitorsInput) *GetAnomalyMonitorsPaginator {
+	return &GetAnomalyMonitorsPaginator{
+		client:    client,
+		params:    params,
+		firstPage: true,
+	}
+}
+
+// HasMorePages reports whether there are more pages to fetch.
+func (p *GetAnomalyMonitorsPaginator) HasMorePages() bool {
+	return p.firstPage || (p.nextToken != nil && len(*p.nextToken) != 0)
+}
+
+// NextPage fetches the next page of GetAnomalyMonitors results.
+func (p *GetAnomalyMonitorsPaginator) NextPage(ctx context.Context, optFns ...func(*costexplorer.Options)) (*costexplorer.GetAnomalyMonitorsOutput, error) {
+	if !p.HasMorePages() {
+		return nil, nil
+	}
+
+	params := *p.params
+	params.NextPageToken = p.nextToken
+
+	result, err := p.client.GetAnomalyMonitors(ctx, &params, optFns...)
+	if err != nil {
+		return nil, err
+	}
+	p.firstPage = false
+	p.nextToken = result.NextPageToken
+
+	return result, nil
+}
@@ -0,0 +1,58 @@
+package costexplorerx
+
+import (
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer"
+)
+
+// GetAnomalySubscriptionsAPIClient is the subset of the Cost Explorer client
+// used by GetAnomalySubscriptionsPaginator.
+type GetAnomalySubscriptionsAPIClient interface {
+	GetAnomalySubscriptions(context.Context, *costexplorer.GetAnomalySubscriptionsInput, ...func(*costexplorer.Options)) (*costexplorer.GetAnomalySubscriptionsOutput, error)
+}
+
+var _ GetAnomalySubscriptionsAPIClient = (*costexplorer.Client)(nil)
+
+// GetAnomalySubscriptionsPaginator paginates over Cost Explorer
+// GetAnomalySubscriptions results.
+type GetAnomalySubscriptionsPaginator struct {
+	client    GetAnomalySubscriptionsAPIClient
+	params    *costexplorer.GetAnomalySubscriptionsInput
+	nextToken *string
+	firstPage bool
+}
+
+// NewGetAnomalySubscriptionsPaginator returns a new paginator for
+// GetAnomalySubscriptions.
+func NewGetAnomalySubscriptionsPaginator(client GetAnomalySubscriptionsAPIClient, params *costexplorer.GetAnomalySubscriptionsInput) *GetAnomalySubscriptionsPaginator {
+	return &GetAnomalySubscriptionsPaginator{
+		client:    client,
+		params:    params,
+		firstPage: true,
+	}
+}
+
+// HasMorePages reports whether there are more pages to fetch.
+func (p *GetAnomalySubscriptionsPaginator) HasMorePages() bool {
+	return p.firstPage || (p.nextToken != nil && len(*p.nextToken) != 0)
+}
+
+// NextPage fetches the next page of GetAnomalySubscriptions results.
+func (p *GetAnomalySubscriptionsPaginator) NextPage(ctx context.Context, optFns ...func(*costexplorer.Options)) (*costexplorer.GetAnomalySubscriptionsOutput, error) {
+	if !p.HasMorePages() {
+		return nil, nil
+	}
+
+	params := *p.params
+	params.NextPageToken = p.nextToken
+
+	result, err := p.client.GetAnomalySubscriptions(ctx, &params, optFns...)
+	if err != nil {
+		return nil, err
+	}
+	p.firstPage = false
+	p.nextToken = result.NextPageToken
+
+	return result, nil
+}
@@ -4,18 +4,24 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
 	"log/slog"
+	"net/http"
 	"os"
 	"os/signal"
 	"strings"
 
+	"github.com/aws/aws-lambda-go/lambda"
+	"github.com/aws/aws-lambda-go/lambdacontext"
 	"github.com/fatih/color"
 	"github.com/fujiwara/ridge"
 	"github.com/handlename/ssmwrap/v2"
 	"github.com/ken39arg/go-flagx"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
 
 	"github.com/mashiike/aws-cost-anomaly-slack-reactor/reactor"
 	"github.com/mashiike/canyon"
@@ -61,13 +67,25 @@ func _main() error {
 		address           string
 		prefix            string
 		sqsQueueName      string
+		eventQueueName    string
 		dynamodbTableName string
+		configPath        string
+		dev               bool
+		devDir            string
 	)
 	flag.StringVar(&logLevel, "log-level", "info", "log level")
 	flag.StringVar(&address, "address", ":8080", "listen address")
 	flag.StringVar(&prefix, "prefix", "/", "path prefix")
 	flag.StringVar(&sqsQueueName, "sqs-queue-name", "", "SQS queue name")
+	flag.StringVar(&eventQueueName, "event-queue-name", "", "SQS queue name to receive anomalies and scheduled events from in socket mode")
 	flag.StringVar(&dynamodbTableName, "dynamodb-table-name", "", "DynamoDB table name")
+	flag.StringVar(&configPath, "config", "", "config file path (.yaml, .yml, .json or .jsonnet)")
+	flag.BoolVar(&dev, "dev", false, "run against in-process fakes of AWS and Slack for local development")
+	flag.StringVar(&devDir, "dev-dir", "dev", "directory of the page rendering the Slack messages of -dev")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] [serve|validate|render|replay|backfill|graph]\n", os.Args[0])
+		flag.PrintDefaults()
+	}
 	flag.VisitAll(flagx.EnvToFlag)
 	flag.Parse()
 	var minLevel slog.Level
@@ -101,20 +119,107 @@ func _main() error {
 	slog.SetDefault(slog.New(middleware))
 	slog.Info("setup logger", "level", minLevel)
 
-	var opts []reactor.Option
-	if dynamodbTableName != "" {
-		opts = append(opts, reactor.WithDynamoDBTableName(dynamodbTableName))
+	var cfg *reactor.Config
+	if configPath != "" {
+		var err error
+		cfg, err = reactor.LoadConfig(configPath)
+		if err != nil {
+			return err
+		}
+	}
+	if !dev {
+		devDir = ""
+	}
+	switch cmd := flag.Arg(0); cmd {
+	case "", "serve":
+	case "validate":
+		return validate(cfg, dynamodbTableName)
+	case "render":
+		return render(ctx, cfg, flag.Args()[1:])
+	case "graph":
+		return graph(ctx, flag.Args()[1:])
+	case "replay":
+		opts, err := handlerOptions(cfg, dynamodbTableName, devDir)
+		if err != nil {
+			return err
+		}
+		return replay(ctx, opts, flag.Args()[1:])
+	case "backfill":
+		opts, err := handlerOptions(cfg, dynamodbTableName, devDir)
+		if err != nil {
+			return err
+		}
+		return backfill(ctx, opts, flag.Args()[1:])
+	default:
+		flag.Usage()
+		return fmt.Errorf("unknown command: %s", cmd)
+	}
+
+	tp, err := reactor.SetupTracing(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to setup tracing: %w", err)
+	}
+	if tp != nil {
+		defer func() {
+			if err := tp.Shutdown(context.Background()); err != nil {
+				slog.Warn("failed to shutdown tracer provider", "error", err)
+			}
+		}()
+	}
+
+	opts, err := handlerOptions(cfg, dynamodbTableName, devDir)
+	if err != nil {
+		return err
+	}
+	// metrics are scraped in server mode, and logged as EMF under Lambda
+	onLambda := ridge.OnLambdaRuntime()
+	if !onLambda {
+		opts = append(opts, reactor.WithMetricsEndpoint())
 	}
 	h, err := reactor.New(ctx, opts...)
 	if err != nil {
 		log.Fatal(err)
 	}
+	if h.SocketModeEnabled() {
+		if !onLambda {
+			return runSocketMode(ctx, h, eventQueueName)
+		}
+		slog.Warn("socket mode is not supported on AWS Lambda, serving the events of AWS only")
+	}
+	var handler http.Handler = h
+	lambdaEventHandler := h.HandleLambdaEvent
+	if onLambda {
+		emf := reactor.NewEMFEmitter(slog.Default())
+		if namespace := os.Getenv("EMF_NAMESPACE"); namespace != "" {
+			emf.Namespace = namespace
+		}
+		handler = emf.Middleware(h)
+		lambdaEventHandler = func(ctx context.Context, event json.RawMessage) (any, error) {
+			defer emf.Emit(ctx)
+			return h.HandleLambdaEvent(ctx, event)
+		}
+		// the execution environment may be frozen right after an
+		// invocation, so spans are exported before returning
+		if tp != nil {
+			handler = flushSpans(tp, handler)
+			eventHandler := lambdaEventHandler
+			lambdaEventHandler = func(ctx context.Context, event json.RawMessage) (any, error) {
+				defer tp.ForceFlush(ctx)
+				return eventHandler(ctx, event)
+			}
+		}
+	}
 	if sqsQueueName == "" {
-		ridge.RunWithContext(ctx, address, prefix, h)
+		if ridge.AsLambdaHandler() {
+			lambda.StartWithOptions(lambdaHandler(prefix, handler, lambdaEventHandler), lambda.WithContext(ctx))
+			return nil
+		}
+		ridge.RunWithContext(ctx, address, prefix, handler)
 	} else {
-		err := canyon.RunWithContext(ctx, sqsQueueName, h,
+		err := canyon.RunWithContext(ctx, sqsQueueName, handler,
 			canyon.WithServerAddress(address, prefix),
 			canyon.WithCanyonEnv("CANYON_"),
+			canyon.WithLambdaFallbackHandler(lambdaEventHandler),
 		)
 		if err != nil {
 			return fmt.Errorf("failed to run canyon: %w", err)
@@ -122,3 +227,70 @@ func _main() error {
 	}
 	return nil
 }
+
+// runSocketMode connects to Slack in Socket Mode, and polls the event queue
+// for anomalies and scheduled events if given, until ctx is canceled.
+func runSocketMode(ctx context.Context, h *reactor.Handler, eventQueueName string) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	errCh := make(chan error, 2)
+	go func() {
+		errCh <- h.RunSocketMode(ctx)
+	}()
+	runners := 1
+	if eventQueueName != "" {
+		runners++
+		go func() {
+			errCh <- h.PollEventQueue(ctx, eventQueueName)
+		}()
+	} else {
+		slog.Warn("event queue name is not set, anomalies are not received in socket mode")
+	}
+	var errs []error
+	for range runners {
+		if err := <-errCh; err != nil {
+			errs = append(errs, err)
+		}
+		// either one stopping stops the other
+		cancel()
+	}
+	return errors.Join(errs...)
+}
+
+// lambdaHandler returns the Lambda handler serving HTTP requests with handler
+// like ridge does. Scheduled events and anomaly events are passed to
+// eventHandler instead, so that no HTTP route accepts them.
+func lambdaHandler(prefix string, handler http.Handler, eventHandler func(context.Context, json.RawMessage) (any, error)) func(context.Context, json.RawMessage) (any, error) {
+	mux := http.NewServeMux()
+	switch {
+	case prefix == "/", prefix == "":
+		mux.Handle("/", handler)
+	case !strings.HasSuffix(prefix, "/"):
+		mux.Handle(prefix+"/", http.StripPrefix(prefix, handler))
+	default:
+		mux.Handle(prefix, http.StripPrefix(strings.TrimSuffix(prefix, "/"), handler))
+	}
+	return func(ctx context.Context, event json.RawMessage) (any, error) {
+		if reactor.IsScheduledEvent(event) || reactor.IsAnomalyEvent(event) {
+			return eventHandler(ctx, event)
+		}
+		req, err := ridge.NewRequest(event)
+		if err != nil {
+			return nil, err
+		}
+		if lc, ok := lambdacontext.FromContext(ctx); ok {
+			req.Header.Set("Lambda-Runtime-Aws-Request-Id", lc.AwsRequestID)
+			req.Header.Set("Lambda-Runtime-Invoked-Function-Arn", lc.InvokedFunctionArn)
+		}
+		w := ridge.NewResponseWriter()
+		mux.ServeHTTP(w, req.WithContext(ctx))
+		return w.ResponseFor(req.Header.Get(ridge.PayloadVersionHeaderName)), nil
+	}
+}
+
+func flushSpans(tp *sdktrace.TracerProvider, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer tp.ForceFlush(r.Context())
+		next.ServeHTTP(w, r)
+	})
+}
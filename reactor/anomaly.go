@@ -20,6 +20,8 @@ import (
 	"github.com/aws/aws-sdk-go-v2/service/costexplorer"
 	"github.com/aws/aws-sdk-go-v2/service/costexplorer/types"
 	"github.com/aws/aws-sdk-go-v2/service/organizations"
+	"go.opentelemetry.io/otel/attribute"
+	"golang.org/x/time/rate"
 
 	"github.com/mashiike/aws-cost-anomaly-slack-reactor/internal/costexplorerx"
 )
@@ -40,6 +42,12 @@ type Anomaly struct {
 	SubscriptionName   string        `json:"subscriptionName"`
 }
 
+// GraphPeriod returns the period of the root-cause graphs of a: 8 days
+// before and after the anomaly.
+func (a Anomaly) GraphPeriod() (startAt, endAt time.Time) {
+	return a.AnomalyStartDate.AddDate(0, 0, -8), a.AnomalyEndDate.AddDate(0, 0, 8)
+}
+
 // AnomalyScore is the score assigned to an Anomaly by Cost Anomaly Detection.
 type AnomalyScore struct {
 	CurrentScore float64 `json:"currentScore"`
@@ -65,11 +73,39 @@ type RootCause struct {
 	UsageType         string `json:"usageType"`
 }
 
+// String returns a short human readable label for the RootCause.
+func (c RootCause) String() string {
+	var parts []string
+	for _, v := range []string{c.LinkedAccount, c.Region, c.Service, c.UsageType} {
+		if v != "" {
+			parts = append(parts, v)
+		}
+	}
+	if len(parts) == 0 {
+		return "(unknown)"
+	}
+	return strings.Join(parts, ",")
+}
+
 // Graph is a rendered PNG image (typically of an Anomaly's cost trend) with
 // its byte size.
 type Graph struct {
-	r    io.Reader
-	size int64
+	r     *bytes.Reader
+	size  int64
+	index int
+}
+
+// NewReader returns a reader over the whole PNG image. Unlike reading the
+// Graph directly, every call starts from the beginning so the same Graph can
+// be uploaded to several notifiers.
+func (g *Graph) NewReader() io.Reader {
+	return io.NewSectionReader(g.r, 0, g.size)
+}
+
+// Index returns the zero-based index of the RootCause the Graph was rendered
+// for.
+func (g *Graph) Index() int {
+	return g.index
 }
 
 // DescribeAccountAPIClient is the subset of the AWS Organizations client used
@@ -78,25 +114,56 @@ type DescribeAccountAPIClient interface {
 	DescribeAccount(ctx context.Context, input *organizations.DescribeAccountInput, optFns ...func(*organizations.Options)) (*organizations.DescribeAccountOutput, error)
 }
 
+// ListTagsForResourceAPIClient is the subset of the AWS Organizations client
+// used to look up account tags. The Organizations client given to
+// NewGraphGenerator is used for it when it implements this interface.
+type ListTagsForResourceAPIClient interface {
+	ListTagsForResource(ctx context.Context, input *organizations.ListTagsForResourceInput, optFns ...func(*organizations.Options)) (*organizations.ListTagsForResourceOutput, error)
+}
+
 // GraphGenerator renders root-cause cost graphs for a given Anomaly.
 type GraphGenerator struct {
+	// Concurrency is the maximum number of root causes rendered in parallel.
+	// Values less than 1 are treated as 1.
+	Concurrency int
+	// RateLimiter, when set, throttles Cost Explorer GetCostAndUsage calls
+	// shared by all concurrent renders.
+	RateLimiter *rate.Limiter
+	// RetryPolicy is applied to each GetCostAndUsage call.
+	RetryPolicy RetryPolicy
+
 	client                     costexplorerx.GetCostAndUsageAPIClient
 	org                        DescribeAccountAPIClient
 	cacheDescribeAccountOutput map[string]*organizations.DescribeAccountOutput
 	cacheDescribeAccountError  map[string]error
 	cacheDescribeAccountMu     sync.Mutex
 	cacheDescribeAccountExpire map[string]time.Time
+	cacheAccountTags           map[string]map[string]string
+	cacheAccountTagsExpire     map[string]time.Time
 }
 
+const (
+	// DefaultGraphConcurrency is the default GraphGenerator.Concurrency.
+	DefaultGraphConcurrency = 4
+	// DefaultCostExplorerRateLimit is the default number of GetCostAndUsage
+	// requests per second issued by a GraphGenerator.
+	DefaultCostExplorerRateLimit rate.Limit = 5
+)
+
 // NewGraphGenerator returns a GraphGenerator backed by the given Cost Explorer
 // and Organizations clients.
 func NewGraphGenerator(client costexplorerx.GetCostAndUsageAPIClient, org DescribeAccountAPIClient) *GraphGenerator {
 	return &GraphGenerator{
+		Concurrency:                DefaultGraphConcurrency,
+		RateLimiter:                rate.NewLimiter(DefaultCostExplorerRateLimit, 1),
+		RetryPolicy:                DefaultRetryPolicy,
 		client:                     client,
 		org:                        org,
 		cacheDescribeAccountOutput: make(map[string]*organizations.DescribeAccountOutput),
 		cacheDescribeAccountError:  make(map[string]error),
 		cacheDescribeAccountExpire: make(map[string]time.Time),
+		cacheAccountTags:           make(map[string]map[string]string),
+		cacheAccountTagsExpire:     make(map[string]time.Time),
 	}
 }
 
@@ -116,25 +183,118 @@ func (g *GraphGenerator) describeAccount(ctx context.Context, accountID string)
 	return out, nil
 }
 
-// Generate renders one Graph per RootCause of the given Anomaly.
-func (g *GraphGenerator) Generate(ctx context.Context, anomaly Anomaly) ([]*Graph, error) {
-	graphs := make([]*Graph, 0, len(anomaly.RootCauses))
-	for _, c := range anomaly.RootCauses {
-		w, err := g.generate(ctx, anomaly.AnomalyStartDate.AddDate(0, 0, -8), anomaly.AnomalyEndDate.AddDate(0, 0, 8), c)
+// listAccountTags returns the tags of an account. Tags are cached for an hour;
+// errors are not cached.
+func (g *GraphGenerator) listAccountTags(ctx context.Context, accountID string) (map[string]string, error) {
+	client, ok := g.org.(ListTagsForResourceAPIClient)
+	if !ok {
+		return nil, errors.New("organizations client does not support ListTagsForResource")
+	}
+	g.cacheDescribeAccountMu.Lock()
+	defer g.cacheDescribeAccountMu.Unlock()
+	if expire, ok := g.cacheAccountTagsExpire[accountID]; ok && time.Now().Before(expire) {
+		return g.cacheAccountTags[accountID], nil
+	}
+	tags := make(map[string]string)
+	paginator := organizations.NewListTagsForResourcePaginator(client, &organizations.ListTagsForResourceInput{
+		ResourceId: aws.String(accountID),
+	})
+	for paginator.HasMorePages() {
+		out, err := paginator.NextPage(ctx)
 		if err != nil {
-			return nil, fmt.Errorf("failed to generate graph: %w", err)
+			return nil, err
 		}
-		var buf bytes.Buffer
-		n, err := w.WriteTo(&buf)
-		if err != nil {
-			return nil, fmt.Errorf("failed to write graph: %w", err)
+		for _, tag := range out.Tags {
+			tags[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
 		}
-		graphs = append(graphs, &Graph{r: &buf, size: n})
 	}
-	return graphs, nil
+	g.cacheAccountTags[accountID] = tags
+	g.cacheAccountTagsExpire[accountID] = time.Now().Add(1 * time.Hour)
+	return tags, nil
 }
 
-func (g *GraphGenerator) generate(ctx context.Context, startAt, endAt time.Time, c RootCause) (io.WriterTo, error) {
+// Generate renders one Graph per RootCause of the given Anomaly, up to
+// Concurrency at a time. Graphs are returned in RootCause order. When some
+// root causes fail, the successfully rendered graphs are still returned
+// together with a joined error describing each failed RootCause.
+func (g *GraphGenerator) Generate(ctx context.Context, anomaly Anomaly) ([]*Graph, error) {
+	concurrency := g.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	results := make([]*Graph, len(anomaly.RootCauses))
+	errs := make([]error, len(anomaly.RootCauses))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, c := range anomaly.RootCauses {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				errs[i] = fmt.Errorf("root cause #%d (%s): %w", i+1, c, ctx.Err())
+				return
+			}
+			defer func() { <-sem }()
+			graph, err := g.generateGraph(ctx, anomaly, c)
+			if err != nil {
+				errs[i] = fmt.Errorf("root cause #%d (%s): %w", i+1, c, err)
+				return
+			}
+			graph.index = i
+			results[i] = graph
+		}()
+	}
+	wg.Wait()
+	graphs := make([]*Graph, 0, len(results))
+	for _, graph := range results {
+		if graph != nil {
+			graphs = append(graphs, graph)
+		}
+	}
+	return graphs, errors.Join(errs...)
+}
+
+func (g *GraphGenerator) generateGraph(ctx context.Context, anomaly Anomaly, c RootCause) (graph *Graph, err error) {
+	ctx, span := startSpan(ctx, "generateGraph",
+		attribute.String("anomaly.id", anomaly.AnomalyID),
+		attribute.String("root_cause.service", c.Service),
+		attribute.String("root_cause.linked_account", c.LinkedAccount),
+		attribute.String("root_cause.region", c.Region),
+		attribute.String("root_cause.usage_type", c.UsageType),
+	)
+	defer func(start time.Time) {
+		graphRenderDuration.WithLabelValues(resultLabel(err)).Observe(time.Since(start).Seconds())
+		endSpan(span, err)
+	}(time.Now())
+	startAt, endAt := anomaly.GraphPeriod()
+	w, err := g.generate(ctx, startAt, endAt, c, "png")
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate graph: %w", err)
+	}
+	var buf bytes.Buffer
+	n, err := w.WriteTo(&buf)
+	if err != nil {
+		return nil, fmt.Errorf("failed to write graph: %w", err)
+	}
+	return &Graph{r: bytes.NewReader(buf.Bytes()), size: n}, nil
+}
+
+// GenerateRange renders the daily cost of the dimensions of c between startAt
+// and endAt in format, e.g. "png" or "svg". Empty dimensions are not
+// filtered; without LinkedAccount the cost is grouped by account. The account
+// name is looked up when LinkedAccountName is empty.
+func (g *GraphGenerator) GenerateRange(ctx context.Context, startAt, endAt time.Time, c RootCause, format string) (io.WriterTo, error) {
+	if c.LinkedAccount != "" && c.LinkedAccountName == "" {
+		if out, err := g.describeAccount(ctx, c.LinkedAccount); err == nil && out.Account != nil {
+			c.LinkedAccountName = aws.ToString(out.Account.Name)
+		}
+	}
+	return g.generate(ctx, startAt, endAt, c, format)
+}
+
+func (g *GraphGenerator) generate(ctx context.Context, startAt, endAt time.Time, c RootCause, format string) (io.WriterTo, error) {
 	graph := NewCostGraph()
 	title, unit, err := g.renderGraph(ctx, graph, startAt, endAt, c, "", nil)
 	if err != nil {
@@ -158,7 +318,7 @@ func (g *GraphGenerator) generate(ctx context.Context, startAt, endAt time.Time,
 		graph.EnableStack = false
 	}
 	slog.InfoContext(ctx, "generate graph", "title", title, "start_at", startAt, "end_at", endAt)
-	w, err := graph.WriteTo(title, fmt.Sprintf("Cost (%s)", unit))
+	w, err := graph.WriteToFormat(title, fmt.Sprintf("Cost (%s)", unit), format)
 	if err != nil {
 		return nil, err
 	}
@@ -276,7 +436,17 @@ func (g *GraphGenerator) renderGraph(ctx context.Context, graph *CostGraph, star
 		input.TimePeriod = tp
 		paginator := costexplorerx.NewGetCostAndUsagePaginator(g.client, input)
 		for paginator.HasMorePages() {
-			out, err := paginator.NextPage(ctx)
+			var out *costexplorer.GetCostAndUsageOutput
+			err := g.RetryPolicy.Do(ctx, func(ctx context.Context) error {
+				if g.RateLimiter != nil {
+					if err := g.RateLimiter.Wait(ctx); err != nil {
+						return fmt.Errorf("failed to wait for rate limit: %w", err)
+					}
+				}
+				var err error
+				out, err = paginator.NextPage(ctx, withoutSDKRetry)
+				return err
+			})
 			if err != nil {
 				return "", "", fmt.Errorf("failed to get cost and usage[%s~%s]: %w", *tp.Start, *tp.End, err)
 			}
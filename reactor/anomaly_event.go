@@ -0,0 +1,177 @@
+package reactor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+)
+
+// The EventBridge event AWS Cost Anomaly Detection emits for an anomaly. Its
+// detail is the same JSON as the message of the SNS notification.
+const (
+	anomalyEventSource     = "aws.ce"
+	anomalyEventDetailType = "Anomaly Detected"
+)
+
+// eventQueueRetryInterval is the wait before receiving from the event queue
+// again after a failure.
+var eventQueueRetryInterval = 5 * time.Second
+
+// SQSAPIClient is the subset of the SQS client used by PollEventQueue.
+type SQSAPIClient interface {
+	GetQueueUrl(ctx context.Context, params *sqs.GetQueueUrlInput, optFns ...func(*sqs.Options)) (*sqs.GetQueueUrlOutput, error) //nolint:revive // method name mirrors the AWS SDK
+	ReceiveMessage(ctx context.Context, params *sqs.ReceiveMessageInput, optFns ...func(*sqs.Options)) (*sqs.ReceiveMessageOutput, error)
+	DeleteMessage(ctx context.Context, params *sqs.DeleteMessageInput, optFns ...func(*sqs.Options)) (*sqs.DeleteMessageOutput, error)
+}
+
+var _ SQSAPIClient = (*sqs.Client)(nil)
+
+// IsAnomalyEvent reports whether a Lambda event carries anomalies: an
+// EventBridge event of AWS Cost Anomaly Detection or an SQS event, which is
+// handled by HandleAnomalyEvent.
+func IsAnomalyEvent(event json.RawMessage) bool {
+	var ev struct {
+		Source     string `json:"source"`
+		DetailType string `json:"detail-type"`
+		Records    []struct {
+			EventSource string `json:"eventSource"`
+		} `json:"Records"`
+	}
+	if err := json.Unmarshal(event, &ev); err != nil {
+		return false
+	}
+	if ev.Source == anomalyEventSource && ev.DetailType == anomalyEventDetailType {
+		return true
+	}
+	return len(ev.Records) > 0 && ev.Records[0].EventSource == "aws:sqs"
+}
+
+// HandleAnomalyEvent handles an EventBridge event of AWS Cost Anomaly
+// Detection or an SQS event. The body of each SQS message is handled like a
+// message of PollEventQueue, and the failed messages are reported in the
+// returned events.SQSEventResponse, so that the event source mapping with
+// ReportBatchItemFailures retries only them.
+func (h *Handler) HandleAnomalyEvent(ctx context.Context, event json.RawMessage) (any, error) {
+	var sqsEvent events.SQSEvent
+	if err := json.Unmarshal(event, &sqsEvent); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal event: %w", err)
+	}
+	if len(sqsEvent.Records) == 0 {
+		return nil, h.handleEventMessage(ctx, event)
+	}
+	var resp events.SQSEventResponse
+	for _, record := range sqsEvent.Records {
+		if err := h.handleEventMessage(ctx, []byte(record.Body)); err != nil {
+			h.logger.ErrorContext(ctx, "failed to handle event message", "message_id", record.MessageId, "error", err)
+			resp.BatchItemFailures = append(resp.BatchItemFailures, events.SQSBatchItemFailure{ItemIdentifier: record.MessageId})
+		}
+	}
+	return resp, nil
+}
+
+// PollEventQueue receives the messages of the SQS queue until ctx is
+// canceled. A message is an SNS notification (raw or not), an EventBridge
+// event of AWS Cost Anomaly Detection or a scheduled event, so that anomalies
+// and reminders reach the Handler without a public endpoint. Messages that
+// fail are left in the queue to be received again.
+func (h *Handler) PollEventQueue(ctx context.Context, queueName string) error {
+	out, err := h.sqs.GetQueueUrl(ctx, &sqs.GetQueueUrlInput{QueueName: aws.String(queueName)})
+	if err != nil {
+		return fmt.Errorf("failed to get queue url of %s: %w", queueName, err)
+	}
+	queueURL := aws.ToString(out.QueueUrl)
+	h.logger.InfoContext(ctx, "polling event queue", "queue_url", queueURL)
+	for ctx.Err() == nil {
+		out, err := h.sqs.ReceiveMessage(ctx, &sqs.ReceiveMessageInput{
+			QueueUrl:            aws.String(queueURL),
+			MaxNumberOfMessages: 10,
+			WaitTimeSeconds:     20,
+		})
+		if err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			h.logger.WarnContext(ctx, "failed to receive messages", "queue_url", queueURL, "error", err)
+			select {
+			case <-ctx.Done():
+			case <-time.After(eventQueueRetryInterval):
+			}
+			continue
+		}
+		for _, m := range out.Messages {
+			if err := h.handleEventMessage(ctx, []byte(aws.ToString(m.Body))); err != nil {
+				h.logger.ErrorContext(ctx, "failed to handle event message", "message_id", aws.ToString(m.MessageId), "error", err)
+				continue
+			}
+			if _, err := h.sqs.DeleteMessage(ctx, &sqs.DeleteMessageInput{
+				QueueUrl:      aws.String(queueURL),
+				ReceiptHandle: m.ReceiptHandle,
+			}); err != nil {
+				h.logger.WarnContext(ctx, "failed to delete message", "message_id", aws.ToString(m.MessageId), "error", err)
+			}
+		}
+	}
+	return nil
+}
+
+// handleEventMessage runs the scheduled tasks for a scheduled event, and
+// posts the anomaly of any other message.
+func (h *Handler) handleEventMessage(ctx context.Context, bs []byte) error {
+	if IsScheduledEvent(bs) {
+		return h.RunScheduledTasks(ctx)
+	}
+	a, ok, err := parseAnomalyMessage(bs)
+	if err != nil {
+		anomaliesSuppressed.WithLabelValues("invalid_payload").Inc()
+		return err
+	}
+	if !ok {
+		h.logger.InfoContext(ctx, "ignore event message without anomaly")
+		return nil
+	}
+	notificationsReceived.WithLabelValues("Notification").Inc()
+	h.logger.InfoContext(ctx, "handle anomaly event", "anomaly_id", a.AnomalyID)
+	return h.processAnomaly(ctx, a)
+}
+
+// parseAnomalyMessage parses the anomaly of an SNS notification, an
+// EventBridge event or the anomaly itself. It returns false for the other
+// SNS messages and EventBridge events.
+func parseAnomalyMessage(bs []byte) (Anomaly, bool, error) {
+	var msg struct {
+		Type       string          `json:"Type"`
+		Message    string          `json:"Message"`
+		Source     string          `json:"source"`
+		DetailType string          `json:"detail-type"`
+		Detail     json.RawMessage `json:"detail"`
+	}
+	if err := json.Unmarshal(bs, &msg); err != nil {
+		return Anomaly{}, false, fmt.Errorf("failed to unmarshal message: %w", err)
+	}
+	switch {
+	case msg.Type != "":
+		if msg.Type != "Notification" {
+			return Anomaly{}, false, nil
+		}
+		bs = []byte(msg.Message)
+	case msg.DetailType != "":
+		if msg.Source != anomalyEventSource || msg.DetailType != anomalyEventDetailType {
+			return Anomaly{}, false, nil
+		}
+		bs = msg.Detail
+	}
+	var a Anomaly
+	if err := json.Unmarshal(bs, &a); err != nil {
+		return Anomaly{}, false, fmt.Errorf("failed to unmarshal anomaly: %w", err)
+	}
+	if a.AnomalyID == "" {
+		return Anomaly{}, false, errors.New("anomaly id is missing")
+	}
+	return a, true, nil
+}
@@ -0,0 +1,151 @@
+package reactor
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	sqstypes "github.com/aws/aws-sdk-go-v2/service/sqs/types"
+	"github.com/stretchr/testify/require"
+)
+
+func anomalyEventBridgeEvent(t *testing.T, a Anomaly) []byte {
+	t.Helper()
+	bs, err := json.Marshal(map[string]any{
+		"version":     "0",
+		"source":      anomalyEventSource,
+		"detail-type": anomalyEventDetailType,
+		"detail":      a,
+	})
+	require.NoError(t, err)
+	return bs
+}
+
+func TestParseAnomalyMessage(t *testing.T) {
+	a := loadTestAnomaly(t, "testdata/anomaly.json")
+	raw, err := json.Marshal(a)
+	require.NoError(t, err)
+	notification, err := json.Marshal(httpNotification{Type: "Notification", Message: string(raw)})
+	require.NoError(t, err)
+	cases := []struct {
+		name    string
+		message []byte
+		ok      bool
+		wantErr bool
+	}{
+		{name: "raw", message: raw, ok: true},
+		{name: "sns", message: notification, ok: true},
+		{name: "eventbridge", message: anomalyEventBridgeEvent(t, a), ok: true},
+		{name: "sns subscription confirmation", message: []byte(`{"Type":"SubscriptionConfirmation","Message":"confirm"}`)},
+		{name: "other eventbridge event", message: []byte(`{"source":"aws.ec2","detail-type":"EC2 Instance State-change Notification","detail":{}}`)},
+		{name: "no anomaly id", message: []byte(`{"accountId":"123456789012"}`), wantErr: true},
+		{name: "not json", message: []byte(`not json`), wantErr: true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, ok, err := parseAnomalyMessage(c.message)
+			if c.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.ok, ok)
+			if ok {
+				require.Equal(t, a.AnomalyID, got.AnomalyID)
+			}
+		})
+	}
+}
+
+func TestIsAnomalyEvent(t *testing.T) {
+	require.True(t, IsAnomalyEvent(anomalyEventBridgeEvent(t, loadTestAnomaly(t, "testdata/anomaly.json"))))
+	require.True(t, IsAnomalyEvent([]byte(`{"Records":[{"eventSource":"aws:sqs","body":"{}"}]}`)))
+	require.False(t, IsAnomalyEvent([]byte(`{"Records":[{"eventSource":"aws:s3"}]}`)))
+	require.False(t, IsAnomalyEvent([]byte(`{"detail-type":"Scheduled Event","source":"aws.events"}`)))
+	require.False(t, IsAnomalyEvent([]byte(`not json`)))
+}
+
+func TestHandlerHandleLambdaEventAnomaly(t *testing.T) {
+	s := newHandlerTestSuite(t)
+	ctx := context.Background()
+	a := loadTestAnomaly(t, "testdata/anomaly.json")
+	resp, err := s.h.HandleLambdaEvent(ctx, anomalyEventBridgeEvent(t, a))
+	require.NoError(t, err)
+	require.Nil(t, resp)
+	calls := s.slack.Calls()
+	require.Equal(t, "chat.postMessage", calls[0].Method)
+	require.Equal(t, "C0123", calls[0].Channel)
+
+	other := loadTestAnomaly(t, "testdata/anomaly_ec2.json")
+	notification, err := json.Marshal(s.notification(other))
+	require.NoError(t, err)
+	event, err := json.Marshal(map[string]any{
+		"Records": []map[string]any{
+			{"messageId": "message-1", "eventSource": "aws:sqs", "body": string(notification)},
+			{"messageId": "message-2", "eventSource": "aws:sqs", "body": "not json"},
+		},
+	})
+	require.NoError(t, err)
+	// only the failed message is retried
+	resp, err = s.h.HandleLambdaEvent(ctx, event)
+	require.NoError(t, err)
+	require.Equal(t, events.SQSEventResponse{
+		BatchItemFailures: []events.SQSBatchItemFailure{{ItemIdentifier: "message-2"}},
+	}, resp)
+	_, ok, err := s.h.GetAnomalySlackMessage(ctx, other.AnomalyID)
+	require.NoError(t, err)
+	require.True(t, ok)
+}
+
+type fakeSQSClient struct {
+	mu       sync.Mutex
+	messages []sqstypes.Message
+	deleted  []string
+	cancel   context.CancelFunc
+}
+
+func (c *fakeSQSClient) GetQueueUrl(_ context.Context, params *sqs.GetQueueUrlInput, _ ...func(*sqs.Options)) (*sqs.GetQueueUrlOutput, error) { //nolint:revive // method name mirrors the AWS SDK
+	return &sqs.GetQueueUrlOutput{QueueUrl: aws.String("https://sqs.us-east-1.amazonaws.com/123456789012/" + aws.ToString(params.QueueName))}, nil
+}
+
+func (c *fakeSQSClient) ReceiveMessage(_ context.Context, _ *sqs.ReceiveMessageInput, _ ...func(*sqs.Options)) (*sqs.ReceiveMessageOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	messages := c.messages
+	c.messages = nil
+	if len(messages) == 0 {
+		// the queue is drained
+		c.cancel()
+	}
+	return &sqs.ReceiveMessageOutput{Messages: messages}, nil
+}
+
+func (c *fakeSQSClient) DeleteMessage(_ context.Context, params *sqs.DeleteMessageInput, _ ...func(*sqs.Options)) (*sqs.DeleteMessageOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.deleted = append(c.deleted, aws.ToString(params.ReceiptHandle))
+	return &sqs.DeleteMessageOutput{}, nil
+}
+
+func TestHandlerPollEventQueue(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	client := &fakeSQSClient{cancel: cancel}
+	s := newHandlerTestSuite(t, WithSQSClient(client))
+	a := loadTestAnomaly(t, "testdata/anomaly.json")
+	client.messages = []sqstypes.Message{
+		{MessageId: aws.String("message-1"), ReceiptHandle: aws.String("receipt-1"), Body: aws.String(string(anomalyEventBridgeEvent(t, a)))},
+		{MessageId: aws.String("message-2"), ReceiptHandle: aws.String("receipt-2"), Body: aws.String("not json")},
+		{MessageId: aws.String("message-3"), ReceiptHandle: aws.String("receipt-3"), Body: aws.String(`{"version":"0","detail-type":"Scheduled Event","source":"aws.events","detail":{}}`)},
+	}
+	require.NoError(t, s.h.PollEventQueue(ctx, "cost-anomaly-events"))
+	// the invalid message is left in the queue
+	require.Equal(t, []string{"receipt-1", "receipt-3"}, client.deleted)
+	_, ok, err := s.h.GetAnomalySlackMessage(context.Background(), a.AnomalyID)
+	require.NoError(t, err)
+	require.True(t, ok)
+}
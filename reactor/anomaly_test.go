@@ -8,6 +8,7 @@ import (
 	"io"
 	"os"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -56,6 +57,76 @@ func (m *mockGetCostAndUsageAPIClient) GetCostAndUsage(ctx context.Context, para
 	return ret, err
 }
 
+func (m *mockGetCostAndUsageAPIClient) ProvideAnomalyFeedback(ctx context.Context, params *costexplorer.ProvideAnomalyFeedbackInput, _ ...func(*costexplorer.Options)) (*costexplorer.ProvideAnomalyFeedbackOutput, error) {
+	args := m.Called(ctx, params)
+	output := args.Get(0)
+	err := args.Error(1)
+	if output == nil {
+		return nil, err
+	}
+	ret, ok := output.(*costexplorer.ProvideAnomalyFeedbackOutput)
+	if !ok {
+		m.t.Fatalf("unexpected type: %T", output)
+	}
+	return ret, err
+}
+
+func (m *mockGetCostAndUsageAPIClient) GetAnomalies(ctx context.Context, params *costexplorer.GetAnomaliesInput, _ ...func(*costexplorer.Options)) (*costexplorer.GetAnomaliesOutput, error) {
+	args := m.Called(ctx, params)
+	output := args.Get(0)
+	err := args.Error(1)
+	if output == nil {
+		return nil, err
+	}
+	ret, ok := output.(*costexplorer.GetAnomaliesOutput)
+	if !ok {
+		m.t.Fatalf("unexpected type: %T", output)
+	}
+	return ret, err
+}
+
+func (m *mockGetCostAndUsageAPIClient) GetAnomalyMonitors(ctx context.Context, params *costexplorer.GetAnomalyMonitorsInput, _ ...func(*costexplorer.Options)) (*costexplorer.GetAnomalyMonitorsOutput, error) {
+	args := m.Called(ctx, params)
+	output := args.Get(0)
+	err := args.Error(1)
+	if output == nil {
+		return nil, err
+	}
+	ret, ok := output.(*costexplorer.GetAnomalyMonitorsOutput)
+	if !ok {
+		m.t.Fatalf("unexpected type: %T", output)
+	}
+	return ret, err
+}
+
+func (m *mockGetCostAndUsageAPIClient) GetAnomalySubscriptions(ctx context.Context, params *costexplorer.GetAnomalySubscriptionsInput, _ ...func(*costexplorer.Options)) (*costexplorer.GetAnomalySubscriptionsOutput, error) {
+	args := m.Called(ctx, params)
+	output := args.Get(0)
+	err := args.Error(1)
+	if output == nil {
+		return nil, err
+	}
+	ret, ok := output.(*costexplorer.GetAnomalySubscriptionsOutput)
+	if !ok {
+		m.t.Fatalf("unexpected type: %T", output)
+	}
+	return ret, err
+}
+
+func (m *mockGetCostAndUsageAPIClient) UpdateAnomalySubscription(ctx context.Context, params *costexplorer.UpdateAnomalySubscriptionInput, _ ...func(*costexplorer.Options)) (*costexplorer.UpdateAnomalySubscriptionOutput, error) {
+	args := m.Called(ctx, params)
+	output := args.Get(0)
+	err := args.Error(1)
+	if output == nil {
+		return nil, err
+	}
+	ret, ok := output.(*costexplorer.UpdateAnomalySubscriptionOutput)
+	if !ok {
+		m.t.Fatalf("unexpected type: %T", output)
+	}
+	return ret, err
+}
+
 type mockDescribeAccountAPIClient struct {
 	mock.Mock
 	t *testing.T
@@ -944,6 +1015,69 @@ func TestGraphGeneratorForSavingsPlanTarget(t *testing.T) {
 	}
 }
 
+func TestGraphGeneratorPartialFailure(t *testing.T) {
+	bs, err := os.ReadFile("testdata/anomaly.json")
+	require.NoError(t, err)
+	var a Anomaly
+	err = json.Unmarshal(bs, &a)
+	require.NoError(t, err)
+	a.RootCauses = []RootCause{
+		{LinkedAccount: "123456789012", LinkedAccountName: "test", Region: "ap-northeast-1", Service: "Amazon Relational Database Service"},
+		{LinkedAccount: "123456789012", LinkedAccountName: "test", Region: "ap-northeast-1", Service: "Amazon Simple Storage Service"},
+		{LinkedAccount: "123456789012", LinkedAccountName: "test", Region: "us-east-1", Service: "Amazon Relational Database Service"},
+	}
+
+	mockClient := mockGetCostAndUsageAPIClient{t: t}
+	mockOrgClient := mockDescribeAccountAPIClient{t: t}
+	defer mockClient.AssertExpectations(t)
+	matchService := func(service string) any {
+		return mock.MatchedBy(func(input *costexplorer.GetCostAndUsageInput) bool {
+			for _, expr := range input.Filter.And {
+				if expr.Dimensions != nil && expr.Dimensions.Key == types.DimensionService {
+					return expr.Dimensions.Values[0] == service
+				}
+			}
+			return false
+		})
+	}
+	mockClient.On("GetCostAndUsage", mock.Anything, matchService("Amazon Relational Database Service")).Return(&costexplorer.GetCostAndUsageOutput{
+		ResultsByTime: []types.ResultByTime{
+			{
+				TimePeriod: &types.DateInterval{
+					Start: aws.String("2021-05-17"),
+					End:   aws.String("2021-05-18"),
+				},
+				Total: map[string]types.MetricValue{
+					"NetUnblendedCost": {
+						Amount: aws.String("1.25"),
+						Unit:   aws.String("USD"),
+					},
+				},
+			},
+		},
+	}, nil)
+	mockClient.On("GetCostAndUsage", mock.Anything, matchService("Amazon Simple Storage Service")).Return(nil, &smithy.GenericAPIError{
+		Code:    "LimitExceededException",
+		Message: "Rate exceeded",
+	})
+
+	gen := NewGraphGenerator(&mockClient, &mockOrgClient)
+	gen.Concurrency = 2
+	gen.RateLimiter = nil
+	gen.RetryPolicy = RetryPolicy{}
+	graphs, err := gen.Generate(context.Background(), a)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "root cause #2 (123456789012,ap-northeast-1,Amazon Simple Storage Service)")
+	require.NotContains(t, err.Error(), "root cause #1")
+	require.NotContains(t, err.Error(), "root cause #3")
+	require.Len(t, graphs, 2)
+	require.Equal(t, 0, graphs[0].Index())
+	require.Equal(t, 2, graphs[1].Index())
+	for _, graph := range graphs {
+		require.NotZero(t, graph.size)
+	}
+}
+
 func TestGenerateTimePeriods(t *testing.T) {
 	cases := []struct {
 		current  string
@@ -1032,3 +1166,47 @@ func TestGenerateTimePeriods(t *testing.T) {
 		})
 	}
 }
+
+func TestGraphGeneratorGenerateRange(t *testing.T) {
+	restore := flextime.Fix(time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC))
+	defer restore()
+	mockClient := mockGetCostAndUsageAPIClient{t: t}
+	defer mockClient.AssertExpectations(t)
+	mockOrgClient := mockDescribeAccountAPIClient{t: t}
+	defer mockOrgClient.AssertExpectations(t)
+	var accounts []string
+	mockClient.On("GetCostAndUsage", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+		input := args.Get(1).(*costexplorer.GetCostAndUsageInput)
+		require.Equal(t, "2021-05-01", aws.ToString(input.TimePeriod.Start))
+		require.Len(t, input.Filter.And, 3)
+		accounts = append(accounts, input.Filter.And[1].Dimensions.Values[0])
+	}).Return(&costexplorer.GetCostAndUsageOutput{
+		ResultsByTime: []types.ResultByTime{{
+			TimePeriod: &types.DateInterval{Start: aws.String("2021-05-20"), End: aws.String("2021-05-21")},
+			Total: map[string]types.MetricValue{
+				"NetUnblendedCost": {Amount: aws.String("1.75"), Unit: aws.String("USD")},
+			},
+		}},
+	}, nil).Once()
+	mockOrgClient.On("DescribeAccount", mock.Anything, &organizations.DescribeAccountInput{
+		AccountId: aws.String("123456789012"),
+	}).Return(&organizations.DescribeAccountOutput{
+		Account: &organizationstypes.Account{Id: aws.String("123456789012"), Name: aws.String("production")},
+	}, nil).Once()
+	gen := NewGraphGenerator(&mockClient, &mockOrgClient)
+	gen.RateLimiter = nil
+
+	w, err := gen.GenerateRange(context.Background(),
+		time.Date(2021, 5, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(2021, 5, 31, 0, 0, 0, 0, time.UTC),
+		RootCause{LinkedAccount: "123456789012", Service: "Amazon Simple Storage Service"},
+		"svg",
+	)
+	require.NoError(t, err)
+	require.Equal(t, []string{"123456789012"}, accounts)
+	var buf strings.Builder
+	_, err = w.WriteTo(&buf)
+	require.NoError(t, err)
+	require.Contains(t, buf.String(), "<svg")
+	require.Contains(t, buf.String(), "production(123456789012)")
+}
@@ -0,0 +1,231 @@
+package reactor
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer/types"
+	"github.com/gorilla/mux"
+)
+
+// registerAPIRoutes registers the read-only JSON API, the feedback endpoint
+// and /reminders, which runs the scheduled tasks. They require the DynamoDB
+// table and an API token.
+func (h *Handler) registerAPIRoutes(router *mux.Router) {
+	if !h.EnableDynamoDB() || h.apiToken == "" {
+		return
+	}
+	router.HandleFunc("/api/anomalies", h.requireAPIToken(h.handleListAnomalies)).Methods(http.MethodGet)
+	router.HandleFunc("/api/anomalies/{id}", h.requireAPIToken(h.handleGetAnomaly)).Methods(http.MethodGet)
+	router.HandleFunc("/api/anomalies/{id}/graphs/{n:[0-9]+}.png", h.requireAPIToken(h.handleGetAnomalyGraph)).Methods(http.MethodGet)
+	router.HandleFunc("/api/anomalies/{id}/feedback", h.requireAPIToken(h.handlePostAnomalyFeedback)).Methods(http.MethodPost)
+	router.HandleFunc("/reminders", h.requireAPIToken(h.handleReminders)).Methods(http.MethodPost)
+}
+
+// requireAPIToken rejects requests without the API token as a bearer token.
+func (h *Handler) requireAPIToken(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(h.apiToken)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) error {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	return json.NewEncoder(w).Encode(v)
+}
+
+func (h *Handler) writeAPIError(ctx context.Context, w http.ResponseWriter, status int, message string) {
+	if err := writeJSON(w, status, map[string]string{"error": message}); err != nil {
+		h.logger.WarnContext(ctx, "failed to write response", "error", err)
+	}
+}
+
+// getAPIAnomaly loads the lifecycle record of the anomaly in the path, writing
+// the error response when it fails.
+func (h *Handler) getAPIAnomaly(w http.ResponseWriter, r *http.Request) (*AnomalyLifecycle, bool) {
+	ctx := r.Context()
+	anomalyID := mux.Vars(r)["id"]
+	lc, ok, err := h.GetAnomalyLifecycle(ctx, anomalyID)
+	if err != nil {
+		h.logger.ErrorContext(ctx, "failed to get anomaly lifecycle", "anomaly_id", anomalyID, "error", err)
+		h.writeAPIError(ctx, w, http.StatusInternalServerError, "failed to get anomaly")
+		return nil, false
+	}
+	if !ok {
+		h.writeAPIError(ctx, w, http.StatusNotFound, "anomaly not found")
+		return nil, false
+	}
+	return lc, true
+}
+
+func (h *Handler) handleListAnomalies(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	query := r.URL.Query()
+	limit := 100
+	if str := query.Get("limit"); str != "" {
+		n, err := strconv.Atoi(str)
+		if err != nil || n < 1 {
+			h.writeAPIError(ctx, w, http.StatusBadRequest, "invalid limit")
+			return
+		}
+		limit = n
+	}
+	status := AnomalyStatus(query.Get("status"))
+	switch status {
+	case "", AnomalyStatusOpen, AnomalyStatusAcknowledged, AnomalyStatusResolved:
+	default:
+		h.writeAPIError(ctx, w, http.StatusBadRequest, "invalid status")
+		return
+	}
+	lifecycles, err := h.ListAnomalyLifecycles(ctx)
+	if err != nil {
+		h.logger.ErrorContext(ctx, "failed to list anomaly lifecycles", "error", err)
+		h.writeAPIError(ctx, w, http.StatusInternalServerError, "failed to list anomalies")
+		return
+	}
+	anomalies := make([]*AnomalyLifecycle, 0, len(lifecycles))
+	for _, lc := range lifecycles {
+		if status != "" && lc.Status != status {
+			continue
+		}
+		if accountID := query.Get("account_id"); accountID != "" && (lc.Anomaly == nil || lc.Anomaly.AccountID != accountID) {
+			continue
+		}
+		anomalies = append(anomalies, lc)
+	}
+	sort.Slice(anomalies, func(i, j int) bool {
+		return anomalies[i].LastUpdatedAt.After(anomalies[j].LastUpdatedAt)
+	})
+	if len(anomalies) > limit {
+		anomalies = anomalies[:limit]
+	}
+	if err := writeJSON(w, http.StatusOK, map[string]any{"anomalies": anomalies}); err != nil {
+		h.logger.WarnContext(ctx, "failed to write response", "error", err)
+	}
+}
+
+func (h *Handler) handleGetAnomaly(w http.ResponseWriter, r *http.Request) {
+	lc, ok := h.getAPIAnomaly(w, r)
+	if !ok {
+		return
+	}
+	if err := writeJSON(w, http.StatusOK, lc); err != nil {
+		h.logger.WarnContext(r.Context(), "failed to write response", "error", err)
+	}
+}
+
+// handleGetAnomalyGraph renders the graph of the n-th (1-based) root cause,
+// like the ones uploaded to the threads.
+func (h *Handler) handleGetAnomalyGraph(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	lc, ok := h.getAPIAnomaly(w, r)
+	if !ok {
+		return
+	}
+	n, err := strconv.Atoi(mux.Vars(r)["n"])
+	if err != nil || lc.Anomaly == nil || n < 1 || n > len(lc.Anomaly.RootCauses) {
+		h.writeAPIError(ctx, w, http.StatusNotFound, "graph not found")
+		return
+	}
+	g, err := h.graphGenerator.generateGraph(ctx, *lc.Anomaly, lc.Anomaly.RootCauses[n-1])
+	if err != nil {
+		h.logger.ErrorContext(ctx, "failed to generate graph", "anomaly_id", lc.AnomalyID, "root_cause", n, "error", err)
+		h.writeAPIError(ctx, w, http.StatusInternalServerError, "failed to generate graph")
+		return
+	}
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Content-Length", strconv.FormatInt(g.size, 10))
+	w.WriteHeader(http.StatusOK)
+	if _, err := io.Copy(w, g.NewReader()); err != nil {
+		h.logger.WarnContext(ctx, "failed to write response", "error", err)
+	}
+}
+
+// APIFeedbackRequest is the body of POST /api/anomalies/{id}/feedback.
+type APIFeedbackRequest struct {
+	// Feedback is YES, NO or PLANNED_ACTIVITY.
+	Feedback types.AnomalyFeedbackType `json:"feedback"`
+	// User is recorded as the one who provided the feedback.
+	User string `json:"user"`
+}
+
+func (h *Handler) handlePostAnomalyFeedback(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	lc, ok := h.getAPIAnomaly(w, r)
+	if !ok {
+		return
+	}
+	var req APIFeedbackRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		h.writeAPIError(ctx, w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	var actionID string
+	switch req.Feedback {
+	case types.AnomalyFeedbackTypeYes:
+		actionID = actionsYesID
+	case types.AnomalyFeedbackTypeNo:
+		actionID = actionsNoID
+	case types.AnomalyFeedbackTypePlannedActivity:
+		actionID = actionsPlanedActivityID
+	default:
+		h.writeAPIError(ctx, w, http.StatusBadRequest, "feedback must be one of YES, NO or PLANNED_ACTIVITY")
+		return
+	}
+	if req.User == "" {
+		req.User = "api"
+	}
+	h.logger.Info("provide feedback action", "anomaly_id", lc.AnomalyID, "action_id", actionID, "api_user", req.User)
+	if err := h.ProvideFeedback(ctx, lc.AnomalyID, actionID); err != nil {
+		h.logger.ErrorContext(ctx, "failed to provide feedback", "anomaly_id", lc.AnomalyID, "error", err)
+		h.writeAPIError(ctx, w, http.StatusBadGateway, "failed to provide feedback")
+		return
+	}
+	h.sendFeedbackWebhook(ctx, lc.AnomalyID, actionID, req.User, "api")
+	if err := h.trackFeedback(ctx, lc.AnomalyID, actionID, req.User, "", "api"); err != nil {
+		h.logger.WarnContext(ctx, "failed to track feedback", "anomaly_id", lc.AnomalyID, "error", err)
+	}
+	text := fmt.Sprintf("Feedback of `%s` was provided for AnomalyID `%s` by user `%s` via API.", req.Feedback, lc.AnomalyID, req.User)
+	var notifiers []Notifier
+	var threads []string
+	for _, n := range h.allNotifiers(ctx) {
+		msg, ok, err := h.getAnomalySlackMessage(ctx, lc.AnomalyID, n.ID())
+		if err != nil || !ok {
+			continue
+		}
+		notifiers = append(notifiers, n)
+		threads = append(threads, msg.SlackMessageTimestamp)
+	}
+	if h.github != nil && actionID == actionsYesID && len(notifiers) > 0 {
+		if issueText := h.openGitHubIssueText(ctx, lc.AnomalyID, notifiers[0].ID(), req.User); issueText != "" {
+			text += "\n" + issueText
+		}
+	}
+	for i, n := range notifiers {
+		if err := n.PostThreadReply(ctx, threads[i], text); err != nil {
+			h.logger.WarnContext(ctx, "failed to post to thread", "notifier_id", n.ID(), "error", err)
+		}
+	}
+	lc, ok, err := h.GetAnomalyLifecycle(ctx, lc.AnomalyID)
+	if err != nil || !ok {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	if err := writeJSON(w, http.StatusOK, lc); err != nil {
+		h.logger.WarnContext(ctx, "failed to write response", "error", err)
+	}
+}
@@ -0,0 +1,160 @@
+package reactor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"image/png"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer/types"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandlerAPI(t *testing.T) {
+	mockClient := mockGetCostAndUsageAPIClient{t: t}
+	defer mockClient.AssertExpectations(t)
+	a := loadTestAnomaly(t, "testdata/anomaly.json")
+	mockClient.On("ProvideAnomalyFeedback", mock.Anything, &costexplorer.ProvideAnomalyFeedbackInput{
+		AnomalyId: aws.String(a.AnomalyID),
+		Feedback:  types.AnomalyFeedbackTypeNo,
+	}).Return(&costexplorer.ProvideAnomalyFeedbackOutput{}, nil).Once()
+	notifier := &recordingNotifier{id: "T0123"}
+	h := &Handler{
+		ce:                &mockClient,
+		notifiers:         []Notifier{notifier},
+		logger:            slog.Default(),
+		router:            mux.NewRouter(),
+		graphGenerator:    newTestGraphGenerator(t),
+		ddb:               newMemoryDynamoDB(),
+		dynamodbTableName: "test",
+		apiToken:          "secret",
+	}
+	h.registerAPIRoutes(h.router)
+	ctx := context.Background()
+	require.NoError(t, h.postAnomalyDetectedMessage(ctx, a))
+
+	do := func(method string, path string, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		var r io.Reader
+		if body != "" {
+			r = strings.NewReader(body)
+		}
+		req := httptest.NewRequest(method, path, r)
+		req.Header.Set("Authorization", "Bearer secret")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/anomalies", nil)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = do(http.MethodGet, "/api/anomalies?status=open", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	var list struct {
+		Anomalies []AnomalyLifecycle `json:"anomalies"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	require.Len(t, list.Anomalies, 1)
+	require.Equal(t, a.AnomalyID, list.Anomalies[0].AnomalyID)
+	w = do(http.MethodGet, "/api/anomalies?status=resolved", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.JSONEq(t, `{"anomalies":[]}`, w.Body.String())
+	require.Equal(t, http.StatusBadRequest, do(http.MethodGet, "/api/anomalies?status=unknown", "").Code)
+
+	w = do(http.MethodGet, "/api/anomalies/"+a.AnomalyID+"/graphs/1.png", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, "image/png", w.Header().Get("Content-Type"))
+	_, err := png.Decode(bytes.NewReader(w.Body.Bytes()))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusNotFound, do(http.MethodGet, "/api/anomalies/"+a.AnomalyID+"/graphs/2.png", "").Code)
+	require.Equal(t, http.StatusNotFound, do(http.MethodGet, "/api/anomalies/unknown", "").Code)
+
+	require.Equal(t, http.StatusBadRequest, do(http.MethodPost, "/api/anomalies/"+a.AnomalyID+"/feedback", `{"feedback":"MAYBE"}`).Code)
+	w = do(http.MethodPost, "/api/anomalies/"+a.AnomalyID+"/feedback", `{"feedback":"NO","user":"portal"}`)
+	require.Equal(t, http.StatusOK, w.Code)
+	var lc AnomalyLifecycle
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &lc))
+	require.Equal(t, AnomalyStatusResolved, lc.Status)
+	require.Len(t, lc.Feedback, 1)
+	require.Equal(t, "portal", lc.Feedback[0].User)
+	require.Equal(t, "api", lc.Feedback[0].Source)
+
+	records := notifier.Records()
+	var replies []string
+	for _, r := range records {
+		if r.Method == "PostThreadReply" {
+			replies = append(replies, r.Text)
+		}
+	}
+	require.Equal(t, []string{"Feedback of `NO` was provided for AnomalyID `" + a.AnomalyID + "` by user `portal` via API."}, replies)
+}
+
+func TestHandlerAPIFeedbackOpensGitHubIssueOnce(t *testing.T) {
+	srv, requests := newFakeGitHub(t)
+	tpl, err := parseTemplate("github_issue", defaultGitHubIssueTemplate)
+	require.NoError(t, err)
+	mockClient := mockGetCostAndUsageAPIClient{t: t}
+	defer mockClient.AssertExpectations(t)
+	a := loadTestAnomaly(t, "testdata/anomaly.json")
+	mockClient.On("ProvideAnomalyFeedback", mock.Anything, &costexplorer.ProvideAnomalyFeedbackInput{
+		AnomalyId: aws.String(a.AnomalyID),
+		Feedback:  types.AnomalyFeedbackTypeYes,
+	}).Return(&costexplorer.ProvideAnomalyFeedbackOutput{}, nil).Once()
+	slackNotifier := &recordingNotifier{id: "T0123"}
+	teamsNotifier := &recordingNotifier{id: "teams"}
+	h := &Handler{
+		ce:                &mockClient,
+		notifiers:         []Notifier{slackNotifier, teamsNotifier},
+		logger:            slog.Default(),
+		router:            mux.NewRouter(),
+		graphGenerator:    newTestGraphGenerator(t),
+		ddb:               newMemoryDynamoDB(),
+		dynamodbTableName: "test",
+		apiToken:          "secret",
+		github: &githubClient{
+			cfg: GitHubConfig{
+				Token:      "ghp_dummy",
+				APIURL:     srv.URL,
+				Repository: "example/finops",
+			},
+			client: http.DefaultClient,
+		},
+		githubTemplate: tpl,
+	}
+	h.registerAPIRoutes(h.router)
+	ctx := context.Background()
+	require.NoError(t, h.postAnomalyDetectedMessage(ctx, a))
+
+	req := httptest.NewRequest(http.MethodPost, "/api/anomalies/"+a.AnomalyID+"/feedback", strings.NewReader(`{"feedback":"YES","user":"portal"}`))
+	req.Header.Set("Authorization", "Bearer secret")
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	reqs := requests()
+	require.Len(t, reqs, 1)
+	require.Equal(t, "/repos/example/finops/issues", reqs[0].Path)
+	for _, n := range []*recordingNotifier{slackNotifier, teamsNotifier} {
+		var replies []string
+		for _, r := range n.Records() {
+			if r.Method == "PostThreadReply" {
+				replies = append(replies, r.Text)
+			}
+		}
+		require.Len(t, replies, 1, n.ID())
+		require.Contains(t, replies[0], "https://github.example.com/example/finops/issues/42", n.ID())
+	}
+}
@@ -0,0 +1,207 @@
+package reactor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/Songmu/flextime"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer/types"
+	"golang.org/x/time/rate"
+
+	"github.com/mashiike/aws-cost-anomaly-slack-reactor/internal/costexplorerx"
+)
+
+// DefaultBackfillInterval is the default minimum interval between two
+// anomalies posted by Backfill.
+const DefaultBackfillInterval = 3 * time.Second
+
+const anomalyDetailsLinkFormat = "https://console.aws.amazon.com/cost-management/home#/anomaly-detection/monitors/%s/anomalies/%s"
+
+// BackfillInput selects the anomalies posted by Backfill.
+type BackfillInput struct {
+	// Since is the first anomaly start date to fetch. It is required.
+	Since time.Time
+	// Until is the last anomaly start date to fetch. It defaults to today.
+	Until time.Time
+	// MonitorArn limits the anomalies to a single monitor when set.
+	MonitorArn string
+	// Interval is the minimum interval between two posted anomalies. It
+	// defaults to DefaultBackfillInterval.
+	Interval time.Duration
+}
+
+// Backfill fetches the anomalies detected since in.Since from Cost Explorer
+// and posts those not already in the state store in chronological order, so
+// that a new channel or a restored deployment catches up with history. The
+// anomalies are only posted: they are not escalated, sent to the webhooks or
+// reminded. It returns the posted anomalies.
+func (h *Handler) Backfill(ctx context.Context, in BackfillInput) ([]Anomaly, error) {
+	if in.Since.IsZero() {
+		return nil, errors.New("since is required")
+	}
+	anomalies, err := h.fetchAnomalies(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	interval := in.Interval
+	if interval <= 0 {
+		interval = DefaultBackfillInterval
+	}
+	limiter := rate.NewLimiter(rate.Every(interval), 1)
+	var posted []Anomaly
+	for _, a := range anomalies {
+		exists, err := h.anomalyPosted(ctx, a.AnomalyID)
+		if err != nil {
+			return posted, err
+		}
+		if exists {
+			h.logger.InfoContext(ctx, "skip backfill of posted anomaly", "anomaly_id", a.AnomalyID)
+			continue
+		}
+		if err := limiter.Wait(ctx); err != nil {
+			return posted, err
+		}
+		h.logger.InfoContext(ctx, "backfill anomaly", "anomaly_id", a.AnomalyID, "anomaly_start_date", a.AnomalyStartDate)
+		if err := h.postAnomalyOnly(ctx, a); err != nil {
+			return posted, fmt.Errorf("failed to post anomaly %s: %w", a.AnomalyID, err)
+		}
+		posted = append(posted, a)
+	}
+	return posted, nil
+}
+
+// fetchAnomalies returns the anomalies selected by in, oldest first.
+func (h *Handler) fetchAnomalies(ctx context.Context, in BackfillInput) ([]Anomaly, error) {
+	until := in.Until
+	if until.IsZero() {
+		until = flextime.Now()
+	}
+	input := &costexplorer.GetAnomaliesInput{
+		DateInterval: &types.AnomalyDateInterval{
+			StartDate: aws.String(in.Since.Format(time.DateOnly)),
+			EndDate:   aws.String(until.Format(time.DateOnly)),
+		},
+	}
+	if in.MonitorArn != "" {
+		input.MonitorArn = aws.String(in.MonitorArn)
+	}
+	var anomalies []Anomaly
+	paginator := costexplorerx.NewGetAnomaliesPaginator(h.ce, input)
+	for paginator.HasMorePages() {
+		if h.graphGenerator != nil && h.graphGenerator.RateLimiter != nil {
+			if err := h.graphGenerator.RateLimiter.Wait(ctx); err != nil {
+				return nil, err
+			}
+		}
+		output, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get anomalies: %w", err)
+		}
+		for _, v := range output.Anomalies {
+			a, err := NewAnomalyFromCostExplorer(v)
+			if err != nil {
+				return nil, err
+			}
+			anomalies = append(anomalies, a)
+		}
+	}
+	slices.SortStableFunc(anomalies, func(a, b Anomaly) int {
+		return a.AnomalyStartDate.Compare(b.AnomalyStartDate)
+	})
+	return anomalies, nil
+}
+
+// anomalyPosted reports whether any notifier already has a message for the
+// anomaly in the state store. It is always false without DynamoDB.
+func (h *Handler) anomalyPosted(ctx context.Context, anomalyID string) (bool, error) {
+	if !h.EnableDynamoDB() {
+		return false, nil
+	}
+	for _, n := range h.allNotifiers(ctx) {
+		_, ok, err := h.getAnomalySlackMessage(ctx, anomalyID, n.ID())
+		if err != nil {
+			return false, fmt.Errorf("failed to get anomaly slack message: %w", err)
+		}
+		if ok {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// NewAnomalyFromCostExplorer converts an anomaly returned by the Cost Explorer
+// GetAnomalies API into the Anomaly delivered via SNS. The subscription is
+// unknown and left empty. The end date of an ongoing anomaly, which Cost
+// Explorer leaves empty, is today.
+func NewAnomalyFromCostExplorer(v types.Anomaly) (Anomaly, error) {
+	a := Anomaly{
+		AnomalyID:        aws.ToString(v.AnomalyId),
+		DimensionalValue: aws.ToString(v.DimensionValue),
+		MonitorArn:       aws.ToString(v.MonitorArn),
+	}
+	var err error
+	if a.AnomalyStartDate, err = parseAnomalyDate(aws.ToString(v.AnomalyStartDate)); err != nil {
+		return Anomaly{}, fmt.Errorf("failed to parse start date of anomaly %s: %w", a.AnomalyID, err)
+	}
+	if a.AnomalyEndDate, err = parseAnomalyDate(aws.ToString(v.AnomalyEndDate)); err != nil {
+		return Anomaly{}, fmt.Errorf("failed to parse end date of anomaly %s: %w", a.AnomalyID, err)
+	}
+	if a.AnomalyEndDate.IsZero() {
+		// the anomaly is still ongoing
+		now := flextime.Now().UTC()
+		a.AnomalyEndDate = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+		if a.AnomalyEndDate.Before(a.AnomalyStartDate) {
+			a.AnomalyEndDate = a.AnomalyStartDate
+		}
+	}
+	if v.AnomalyScore != nil {
+		a.AnomalyScore = AnomalyScore{
+			CurrentScore: v.AnomalyScore.CurrentScore,
+			MaxScore:     v.AnomalyScore.MaxScore,
+		}
+	}
+	if v.Impact != nil {
+		a.Impact = AnomalyImpact{
+			MaxImpact:             v.Impact.MaxImpact,
+			TotalActualSpend:      aws.ToFloat64(v.Impact.TotalActualSpend),
+			TotalExpectedSpend:    aws.ToFloat64(v.Impact.TotalExpectedSpend),
+			TotalImpact:           v.Impact.TotalImpact,
+			TotalImpactPercentage: aws.ToFloat64(v.Impact.TotalImpactPercentage),
+		}
+	}
+	for _, rc := range v.RootCauses {
+		a.RootCauses = append(a.RootCauses, RootCause{
+			LinkedAccount:     aws.ToString(rc.LinkedAccount),
+			LinkedAccountName: aws.ToString(rc.LinkedAccountName),
+			Region:            aws.ToString(rc.Region),
+			Service:           aws.ToString(rc.Service),
+			UsageType:         aws.ToString(rc.UsageType),
+		})
+	}
+	// arn:aws:ce::<account>:anomalymonitor/<monitor id>
+	if parts := strings.SplitN(a.MonitorArn, ":", 6); len(parts) == 6 {
+		a.AccountID = parts[4]
+		if _, monitorID, ok := strings.Cut(parts[5], "/"); ok {
+			a.AnomalyDetailsLink = fmt.Sprintf(anomalyDetailsLinkFormat, monitorID, a.AnomalyID)
+		}
+	}
+	return a, nil
+}
+
+// parseAnomalyDate parses a Cost Explorer anomaly date, which is either a
+// date or an RFC 3339 timestamp. An empty date is the zero time.
+func parseAnomalyDate(s string) (time.Time, error) {
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.DateOnly, s); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, s)
+}
@@ -0,0 +1,141 @@
+package reactor
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/Songmu/flextime"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer/types"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func testCostExplorerAnomaly(id string, startDate string) types.Anomaly {
+	return types.Anomaly{
+		AnomalyId:        aws.String(id),
+		AnomalyStartDate: aws.String(startDate),
+		AnomalyEndDate:   aws.String(startDate),
+		AnomalyScore:     &types.AnomalyScore{CurrentScore: 0.47, MaxScore: 0.47},
+		DimensionValue:   aws.String("Amazon Elastic Compute Cloud - Compute"),
+		Impact: &types.Impact{
+			MaxImpact:             151,
+			TotalActualSpend:      aws.Float64(1301),
+			TotalExpectedSpend:    aws.Float64(300),
+			TotalImpact:           1001,
+			TotalImpactPercentage: aws.Float64(333.67),
+		},
+		MonitorArn: aws.String("arn:aws:ce::123456789012:anomalymonitor/abcdef12-1234-4ea0-84cc-918a97d736ef"),
+		RootCauses: []types.RootCause{
+			{
+				LinkedAccount:     aws.String("123456789012"),
+				LinkedAccountName: aws.String("test"),
+				Region:            aws.String("ap-northeast-1"),
+				Service:           aws.String("Amazon Relational Database Service"),
+				UsageType:         aws.String("AnomalousUsageType"),
+			},
+		},
+	}
+}
+
+func TestNewAnomalyFromCostExplorer(t *testing.T) {
+	want := loadTestAnomaly(t, "testdata/anomaly.json")
+	want.SubscriptionID = ""
+	want.SubscriptionName = ""
+	a, err := NewAnomalyFromCostExplorer(testCostExplorerAnomaly(want.AnomalyID, "2021-05-25"))
+	require.NoError(t, err)
+	require.Equal(t, want, a)
+
+	flextime.Fix(time.Date(2021, 5, 27, 15, 4, 5, 0, time.UTC))
+	defer flextime.Restore()
+	ongoing := testCostExplorerAnomaly(want.AnomalyID, "2021-05-25")
+	ongoing.AnomalyEndDate = nil
+	a, err = NewAnomalyFromCostExplorer(ongoing)
+	require.NoError(t, err)
+	require.Equal(t, time.Date(2021, 5, 27, 0, 0, 0, 0, time.UTC), a.AnomalyEndDate)
+	ongoing.AnomalyEndDate = aws.String("")
+	ongoing.AnomalyStartDate = aws.String("2021-05-28T00:00:00Z")
+	a, err = NewAnomalyFromCostExplorer(ongoing)
+	require.NoError(t, err)
+	require.Equal(t, a.AnomalyStartDate, a.AnomalyEndDate)
+
+	_, err = NewAnomalyFromCostExplorer(types.Anomaly{AnomalyId: aws.String("x"), AnomalyStartDate: aws.String("yesterday")})
+	require.ErrorContains(t, err, "failed to parse start date of anomaly x")
+}
+
+func TestHandlerBackfill(t *testing.T) {
+	flextime.Fix(time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC))
+	defer flextime.Restore()
+	ce := &mockGetCostAndUsageAPIClient{t: t}
+	ce.On("GetAnomalies", mock.Anything, mock.MatchedBy(func(in *costexplorer.GetAnomaliesInput) bool {
+		return in.NextPageToken == nil
+	})).Return(&costexplorer.GetAnomaliesOutput{
+		Anomalies: []types.Anomaly{
+			testCostExplorerAnomaly("anomaly-3", "2026-09-20"),
+			testCostExplorerAnomaly("anomaly-1", "2026-09-02"),
+		},
+		NextPageToken: aws.String("page-2"),
+	}, nil).Once()
+	ce.On("GetAnomalies", mock.Anything, mock.MatchedBy(func(in *costexplorer.GetAnomaliesInput) bool {
+		return aws.ToString(in.NextPageToken) == "page-2"
+	})).Return(&costexplorer.GetAnomaliesOutput{
+		Anomalies: []types.Anomaly{
+			testCostExplorerAnomaly("anomaly-2", "2026-09-10"),
+		},
+	}, nil).Once()
+
+	srv, records := newFakeEscalationServer(t)
+	escalation := EscalationConfig{
+		MinTotalImpact:      1000,
+		PagerDutyRoutingKey: "pd-key",
+		PagerDutyEventsURL:  srv.URL + "/v2/enqueue",
+	}
+	notifier := &recordingNotifier{id: "T0001"}
+	h := &Handler{
+		ce:                ce,
+		notifiers:         []Notifier{notifier},
+		logger:            slog.Default(),
+		graphGenerator:    newTestGraphGenerator(t),
+		ddb:               newMemoryDynamoDB(),
+		dynamodbTableName: "test",
+		escalation:        escalation,
+		escalators:        newEscalators(escalation, "", RetryPolicy{}),
+	}
+	ctx := context.Background()
+	h.saveAnomalyMessage(ctx, notifier, Anomaly{AnomalyID: "anomaly-2"}, "1600000000.000001", nil, flextime.Now())
+
+	posted, err := h.Backfill(ctx, BackfillInput{
+		Since:    time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC),
+		Interval: time.Millisecond,
+	})
+	require.NoError(t, err)
+	ce.AssertExpectations(t)
+	in := ce.Calls[0].Arguments.Get(1).(*costexplorer.GetAnomaliesInput)
+	require.Equal(t, "2026-09-01", aws.ToString(in.DateInterval.StartDate))
+	require.Equal(t, "2026-10-01", aws.ToString(in.DateInterval.EndDate))
+
+	var ids []string
+	for _, a := range posted {
+		ids = append(ids, a.AnomalyID)
+	}
+	require.Equal(t, []string{"anomaly-1", "anomaly-3"}, ids)
+	var postedIDs []string
+	for _, r := range notifier.Records() {
+		if r.Method == "PostAnomaly" {
+			postedIDs = append(postedIDs, r.Data.Anomaly.AnomalyID)
+		}
+	}
+	require.Equal(t, []string{"anomaly-1", "anomaly-3"}, postedIDs)
+	// the backfilled anomalies are neither escalated nor reminded
+	require.Empty(t, records())
+	msg, ok, err := h.getAnomalySlackMessage(ctx, "anomaly-1", notifier.ID())
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Zero(t, msg.PostedAt)
+
+	_, err = h.Backfill(ctx, BackfillInput{})
+	require.ErrorContains(t, err, "since is required")
+}
@@ -0,0 +1,410 @@
+package reactor
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/goccy/go-yaml"
+	"github.com/google/go-jsonnet"
+	"github.com/google/go-jsonnet/ast"
+)
+
+// Config is the configuration file of the reactor. Every field is optional;
+// the settings left out fall back to the environment variables read by New.
+// A section that is set replaces the environment variables of that section,
+// except for the webhooks, which are added to WEBHOOK_ENDPOINTS.
+type Config struct {
+	Slack      SlackConfig        `json:"slack,omitzero"`
+	Templates  TemplatesConfig    `json:"templates,omitzero"`
+	Storage    StorageConfig      `json:"storage,omitzero"`
+	Graph      GraphConfig        `json:"graph,omitzero"`
+	Currency   string             `json:"currency,omitempty"`
+	APIToken   string             `json:"apiToken,omitempty"`
+	Owners     OwnerConfig        `json:"owners,omitzero"`
+	Feedback   FeedbackAuthConfig `json:"feedback,omitzero"`
+	Reminder   ReminderSettings   `json:"reminder,omitzero"`
+	Escalation EscalationConfig   `json:"escalation,omitzero"`
+	GitHub     GitHubConfig       `json:"github,omitzero"`
+	Teams      TeamsConfig        `json:"teams,omitzero"`
+	Webhooks   []WebhookEndpoint  `json:"webhooks,omitempty"`
+
+	dir string
+}
+
+// SlackConfig is the Slack section of Config.
+type SlackConfig struct {
+	BotToken      string           `json:"botToken,omitempty"`
+	Channel       string           `json:"channel,omitempty"`
+	SigningSecret string           `json:"signingSecret,omitempty"`
+	AppToken      string           `json:"appToken,omitempty"`
+	NoErrorReport bool             `json:"noErrorReport,omitempty"`
+	Admins        []string         `json:"admins,omitempty"`
+	OAuth         SlackOAuthConfig `json:"oauth,omitzero"`
+}
+
+// TemplatesConfig is the templates section of Config. The paths are relative
+// to the configuration file.
+type TemplatesConfig struct {
+	Message     string `json:"message,omitempty"`
+	TeamsCard   string `json:"teamsCard,omitempty"`
+	GitHubIssue string `json:"githubIssue,omitempty"`
+}
+
+// StorageConfig is the state store section of Config.
+type StorageConfig struct {
+	DynamoDBTableName string `json:"dynamodbTableName,omitempty"`
+}
+
+// GraphConfig is the root-cause graph section of Config.
+type GraphConfig struct {
+	Concurrency           int     `json:"concurrency,omitempty"`
+	CostExplorerRateLimit float64 `json:"costExplorerRateLimit,omitempty"`
+}
+
+// ReminderSettings is the reminder section of Config. The durations are
+// written like "24h".
+type ReminderSettings struct {
+	RemindAfter         string `json:"remindAfter,omitempty"`
+	EscalateAfter       string `json:"escalateAfter,omitempty"`
+	EscalationUserGroup string `json:"escalationUserGroup,omitempty"`
+}
+
+func (s ReminderSettings) config() (ReminderConfig, error) {
+	cfg := ReminderConfig{EscalationUserGroup: s.EscalationUserGroup}
+	for _, f := range []struct {
+		name string
+		str  string
+		d    *time.Duration
+	}{
+		{"remindAfter", s.RemindAfter, &cfg.RemindAfter},
+		{"escalateAfter", s.EscalateAfter, &cfg.EscalateAfter},
+	} {
+		if f.str == "" {
+			continue
+		}
+		d, err := time.ParseDuration(f.str)
+		if err != nil {
+			return cfg, fmt.Errorf("invalid reminder.%s: %w", f.name, err)
+		}
+		*f.d = d
+	}
+	return cfg, nil
+}
+
+// LoadConfig reads the configuration file at path. The format is chosen by
+// the extension: .jsonnet and .libsonnet are evaluated as Jsonnet with the
+// native functions env and must_env, while .yaml, .yml and .json are
+// rendered as a text/template with the env and must_env functions first.
+func LoadConfig(path string) (*Config, error) {
+	bs, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+	var js []byte
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".jsonnet", ".libsonnet":
+		vm := jsonnet.MakeVM()
+		for _, f := range jsonnetNativeFunctions() {
+			vm.NativeFunction(f)
+		}
+		out, err := vm.EvaluateAnonymousSnippet(path, string(bs))
+		if err != nil {
+			return nil, fmt.Errorf("failed to evaluate jsonnet: %w", err)
+		}
+		js = []byte(out)
+	case ".yaml", ".yml", ".json":
+		rendered, err := renderConfigTemplate(path, string(bs))
+		if err != nil {
+			return nil, err
+		}
+		js = rendered
+		if ext != ".json" {
+			js, err = yaml.YAMLToJSON(rendered)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse yaml: %w", err)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unsupported config file extension: %s", ext)
+	}
+	var cfg Config
+	dec := json.NewDecoder(bytes.NewReader(js))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&cfg); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to decode config: %w", err)
+	}
+	cfg.dir = filepath.Dir(path)
+	return &cfg, nil
+}
+
+func renderConfigTemplate(name string, text string) ([]byte, error) {
+	tpl, err := template.New(filepath.Base(name)).Funcs(template.FuncMap{
+		"env":      configEnv,
+		"must_env": templateMustEnv,
+	}).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config template: %w", err)
+	}
+	var buf bytes.Buffer
+	if err := tpl.Execute(&buf, nil); err != nil {
+		return nil, fmt.Errorf("failed to render config template: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// configEnv returns the value of the environment variable key, or
+// defaultValue when it is empty, like the jsonnet env native function.
+func configEnv(key string, defaultValue ...string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	if len(defaultValue) > 0 {
+		return defaultValue[0]
+	}
+	return ""
+}
+
+func jsonnetNativeFunctions() []*jsonnet.NativeFunction {
+	return []*jsonnet.NativeFunction{
+		{
+			Name:   "env",
+			Params: ast.Identifiers{"name", "default"},
+			Func: func(args []any) (any, error) {
+				key, ok := args[0].(string)
+				if !ok {
+					return nil, errors.New("env: name must be a string")
+				}
+				if v := os.Getenv(key); v != "" {
+					return v, nil
+				}
+				return args[1], nil
+			},
+		},
+		{
+			Name:   "must_env",
+			Params: ast.Identifiers{"name"},
+			Func: func(args []any) (any, error) {
+				key, ok := args[0].(string)
+				if !ok {
+					return nil, errors.New("must_env: name must be a string")
+				}
+				return templateMustEnv(key)
+			},
+		},
+	}
+}
+
+func (cfg *Config) readTemplate(path string) (string, error) {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(cfg.dir, path)
+	}
+	bs, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read template: %w", err)
+	}
+	return string(bs), nil
+}
+
+// MessageTemplate returns the text of the message template file, or an empty
+// string when templates.message is not set.
+func (cfg *Config) MessageTemplate() (string, error) {
+	if cfg.Templates.Message == "" {
+		return "", nil
+	}
+	return cfg.readTemplate(cfg.Templates.Message)
+}
+
+// Validate checks the configuration together with the environment variables
+// it falls back to, without connecting to any service. All problems found
+// are returned joined.
+func (cfg *Config) Validate() error {
+	var errs []error
+	if cmp.Or(cfg.Slack.BotToken, os.Getenv("SLACK_TOKEN"), os.Getenv("SLACK_BOT_TOKEN")) == "" {
+		errs = append(errs, errors.New("slack.botToken (or SLACK_TOKEN) is required"))
+	}
+	if cmp.Or(cfg.Slack.Channel, os.Getenv("SLACK_CHANNEL")) == "" {
+		errs = append(errs, errors.New("slack.channel (or SLACK_CHANNEL) is required"))
+	}
+	if cmp.Or(cfg.Slack.SigningSecret, os.Getenv("SLACK_SIGNING_SECRET"), cfg.Slack.AppToken, os.Getenv("SLACK_APP_TOKEN")) == "" {
+		errs = append(errs, errors.New("slack.signingSecret (or SLACK_SIGNING_SECRET) is required, unless slack.appToken (or SLACK_APP_TOKEN) is set for socket mode"))
+	}
+	var dummy TemplateData
+	if cfg.Templates.Message != "" {
+		if err := cfg.validateTemplate("templates.message", cfg.Templates.Message, func(tpl *template.Template) error {
+			_, err := NewSlackNotifier(nil, "", "", tpl, RetryPolicy{}).newDetectAnomalyMessageOptions(dummy)
+			return err
+		}); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if cfg.Templates.TeamsCard != "" {
+		if err := cfg.validateTemplate("templates.teamsCard", cfg.Templates.TeamsCard, func(tpl *template.Template) error {
+			_, err := NewTeamsNotifier(TeamsConfig{}, tpl, RetryPolicy{}).renderCard(dummy)
+			return err
+		}); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if cfg.Templates.GitHubIssue != "" {
+		if err := cfg.validateTemplate("templates.githubIssue", cfg.Templates.GitHubIssue, func(tpl *template.Template) error {
+			return tpl.Execute(io.Discard, GitHubIssueData{TemplateData: dummy})
+		}); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if cfg.Graph.Concurrency < 0 {
+		errs = append(errs, errors.New("graph.concurrency must not be negative"))
+	}
+	if cfg.Graph.CostExplorerRateLimit < 0 {
+		errs = append(errs, errors.New("graph.costExplorerRateLimit must not be negative"))
+	}
+	if cfg.Currency != "" && len(cfg.Currency) != 3 {
+		errs = append(errs, fmt.Errorf("currency must be an ISO 4217 code: %s", cfg.Currency))
+	}
+	hasStorage := cmp.Or(cfg.Storage.DynamoDBTableName, os.Getenv("DYNAMODB_TABLE_NAME")) != ""
+	reminder, err := cfg.Reminder.config()
+	if err != nil {
+		errs = append(errs, err)
+	}
+	if reminder.Enabled() && !hasStorage {
+		errs = append(errs, errors.New("reminder requires storage.dynamodbTableName"))
+	}
+	if cfg.GitHub.Enabled() && !hasStorage {
+		errs = append(errs, errors.New("github requires storage.dynamodbTableName"))
+	}
+	if cfg.APIToken != "" && !hasStorage {
+		errs = append(errs, errors.New("apiToken requires storage.dynamodbTableName"))
+	}
+	if cfg.Slack.OAuth.Enabled() {
+		if err := cfg.Slack.OAuth.validate(); err != nil {
+			errs = append(errs, err)
+		}
+		if !hasStorage {
+			errs = append(errs, errors.New("slack.oauth requires storage.dynamodbTableName"))
+		}
+	}
+	if cfg.Teams.Enabled() {
+		if err := cfg.Teams.validate(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if cfg.Escalation != (EscalationConfig{}) && !cfg.Escalation.Enabled() {
+		errs = append(errs, errors.New("escalation requires a threshold and pagerDutyRoutingKey or opsgenieApiKey"))
+	}
+	for i, e := range cfg.Webhooks {
+		u, err := url.Parse(e.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("webhooks[%d].url is invalid: %q", i, e.URL))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (cfg *Config) validateTemplate(field string, path string, check func(*template.Template) error) error {
+	text, err := cfg.readTemplate(path)
+	if err != nil {
+		return fmt.Errorf("%s: %w", field, err)
+	}
+	tpl, err := parseTemplate(field, text)
+	if err != nil {
+		return fmt.Errorf("%s: failed to parse template: %w", field, err)
+	}
+	if err := check(tpl); err != nil {
+		return fmt.Errorf("%s: %w", field, err)
+	}
+	return nil
+}
+
+// Options returns the Options that apply the configuration to New.
+func (cfg *Config) Options() ([]Option, error) {
+	var opts []Option
+	if cfg.Slack.BotToken != "" {
+		opts = append(opts, WithSlackBotToken(cfg.Slack.BotToken))
+	}
+	if cfg.Slack.Channel != "" {
+		opts = append(opts, WithSlackChannel(cfg.Slack.Channel))
+	}
+	if cfg.Slack.SigningSecret != "" {
+		opts = append(opts, WithSlackSignalSecret(cfg.Slack.SigningSecret))
+	}
+	if cfg.Slack.AppToken != "" {
+		opts = append(opts, WithSlackAppToken(cfg.Slack.AppToken))
+	}
+	if cfg.Slack.NoErrorReport {
+		opts = append(opts, WithNoErrorReport())
+	}
+	if len(cfg.Slack.Admins) > 0 {
+		opts = append(opts, WithSlackAdmins(cfg.Slack.Admins...))
+	}
+	if cfg.Slack.OAuth.Enabled() {
+		opts = append(opts, WithSlackOAuth(cfg.Slack.OAuth))
+	}
+	for _, t := range []struct {
+		path string
+		opt  func(string) Option
+	}{
+		{cfg.Templates.Message, WithTemplate},
+		{cfg.Templates.TeamsCard, WithTeamsTemplate},
+		{cfg.Templates.GitHubIssue, WithGitHubIssueTemplate},
+	} {
+		if t.path == "" {
+			continue
+		}
+		text, err := cfg.readTemplate(t.path)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, t.opt(text))
+	}
+	if cfg.Storage.DynamoDBTableName != "" {
+		opts = append(opts, WithDynamoDBTableName(cfg.Storage.DynamoDBTableName))
+	}
+	if cfg.Graph.Concurrency > 0 {
+		opts = append(opts, WithGraphConcurrency(cfg.Graph.Concurrency))
+	}
+	if cfg.Graph.CostExplorerRateLimit > 0 {
+		opts = append(opts, WithCostExplorerRateLimit(cfg.Graph.CostExplorerRateLimit))
+	}
+	if cfg.Currency != "" {
+		opts = append(opts, WithCurrency(cfg.Currency))
+	}
+	if cfg.APIToken != "" {
+		opts = append(opts, WithAPIToken(cfg.APIToken))
+	}
+	if cfg.Owners.Enabled() {
+		opts = append(opts, WithOwners(cfg.Owners))
+	}
+	if cfg.Feedback.Enabled() {
+		opts = append(opts, WithFeedbackAuth(cfg.Feedback))
+	}
+	if cfg.Reminder != (ReminderSettings{}) {
+		reminder, err := cfg.Reminder.config()
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, WithReminder(reminder))
+	}
+	if cfg.Escalation != (EscalationConfig{}) {
+		opts = append(opts, WithEscalation(cfg.Escalation))
+	}
+	if cfg.GitHub.Token != "" || cfg.GitHub.Repository != "" || len(cfg.GitHub.AccountRepositories) > 0 {
+		opts = append(opts, WithGitHub(cfg.GitHub))
+	}
+	if cfg.Teams != (TeamsConfig{}) {
+		opts = append(opts, WithTeams(cfg.Teams))
+	}
+	if len(cfg.Webhooks) > 0 {
+		opts = append(opts, WithWebhookEndpoints(cfg.Webhooks...))
+	}
+	return opts, nil
+}
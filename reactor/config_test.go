@@ -0,0 +1,101 @@
+package reactor
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadConfig(t *testing.T) {
+	for _, path := range []string{"testdata/config.yaml", "testdata/config.jsonnet"} {
+		t.Run(filepath.Ext(path), func(t *testing.T) {
+			t.Setenv("TEST_SLACK_TOKEN", "xoxb-test")
+			t.Setenv("TEST_SLACK_CHANNEL", "")
+			cfg, err := LoadConfig(path)
+			require.NoError(t, err)
+			require.Equal(t, SlackConfig{
+				BotToken:      "xoxb-test",
+				Channel:       "cost-anomalies",
+				SigningSecret: "secret",
+			}, cfg.Slack)
+			require.NoError(t, cfg.Validate())
+
+			opts, err := cfg.Options()
+			require.NoError(t, err)
+			params := &optionParams{}
+			for _, opt := range opts {
+				opt(params)
+			}
+			require.Equal(t, "xoxb-test", params.slackBotToken)
+			require.Equal(t, "cost-anomalies", params.slackChannel)
+			require.Equal(t, defaultTemplate, params.templateStr)
+			require.Equal(t, "reactor-state", params.dynamodbTableName)
+			require.Equal(t, "JPY", params.currency)
+			require.Equal(t, map[string]string{"123456789012": "S0123ABC"}, params.owners.Accounts)
+			require.Equal(t, 24*time.Hour, params.reminder.RemindAfter)
+			require.Equal(t, []WebhookEndpoint{{
+				URL:    "https://example.com/hook",
+				Filter: WebhookFilter{MinTotalImpact: 100},
+			}}, params.webhookEndpoints)
+		})
+	}
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	t.Setenv("TEST_SLACK_TOKEN", "")
+	os.Unsetenv("TEST_SLACK_TOKEN")
+	_, err := LoadConfig("testdata/config.yaml")
+	require.ErrorContains(t, err, "environment variable TEST_SLACK_TOKEN is not set")
+	_, err = LoadConfig("testdata/config.jsonnet")
+	require.ErrorContains(t, err, "environment variable TEST_SLACK_TOKEN is not set")
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("slack:\n  token: xoxb-test\n"), 0o644))
+	_, err = LoadConfig(path)
+	require.ErrorContains(t, err, `unknown field "token"`)
+
+	_, err = LoadConfig(filepath.Join(dir, "config.toml"))
+	require.Error(t, err)
+}
+
+func TestConfigValidate(t *testing.T) {
+	for _, env := range []string{"SLACK_TOKEN", "SLACK_BOT_TOKEN", "SLACK_CHANNEL", "SLACK_SIGNING_SECRET", "DYNAMODB_TABLE_NAME"} {
+		t.Setenv(env, "")
+	}
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "broken.json.tpl"), []byte(`{"blocks": [{{ .Unknown }}]}`), 0o644))
+	cfg := &Config{
+		Templates: TemplatesConfig{Message: "broken.json.tpl", GitHubIssue: "missing.md.tpl"},
+		Currency:  "JP",
+		Reminder:  ReminderSettings{RemindAfter: "1 day"},
+		GitHub:    GitHubConfig{Token: "token", Repository: "owner/repo"},
+		Teams:     TeamsConfig{AppID: "app"},
+		Webhooks:  []WebhookEndpoint{{URL: "example.com/hook"}},
+		dir:       dir,
+	}
+	err := cfg.Validate()
+	require.Error(t, err)
+	for _, msg := range []string{
+		"slack.botToken (or SLACK_TOKEN) is required",
+		"slack.channel (or SLACK_CHANNEL) is required",
+		"slack.signingSecret (or SLACK_SIGNING_SECRET) is required",
+		"templates.message:",
+		"templates.githubIssue: failed to read template",
+		"currency must be an ISO 4217 code: JP",
+		"invalid reminder.remindAfter",
+		"github requires storage.dynamodbTableName",
+		"teams app password is required",
+		`webhooks[0].url is invalid: "example.com/hook"`,
+	} {
+		require.ErrorContains(t, err, msg)
+	}
+
+	t.Setenv("SLACK_TOKEN", "xoxb-test")
+	t.Setenv("SLACK_CHANNEL", "cost-anomalies")
+	t.Setenv("SLACK_SIGNING_SECRET", "secret")
+	require.NoError(t, (&Config{}).Validate())
+}
@@ -0,0 +1,25 @@
+## AWS Cost Anomaly Detected
+
+| | |
+| --- | --- |
+| Anomaly ID | `{{ .Anomaly.AnomalyID }}` |
+| Account | `{{ .Anomaly.AccountID }}` |
+| Monitor | `{{ .MonitorID }}` |
+| Start Date | {{ .Anomaly.AnomalyStartDate | to_date_str }} |
+| End Date | {{ .Anomaly.AnomalyEndDate | to_date_str }} |
+| Total Impact | ${{ .Anomaly.Impact.TotalImpact }} ({{ .Anomaly.Impact.TotalImpactPercentage }}%) |
+| Actual / Expected Spend | ${{ .Anomaly.Impact.TotalActualSpend }} / ${{ .Anomaly.Impact.TotalExpectedSpend }} |
+
+[Open in AWS Console]({{ .Anomaly.AnomalyDetailsLink }})
+
+### Root Causes
+{{ range .Anomaly.RootCauses }}
+- Service: `{{ .Service }}`, Account: `{{ .LinkedAccount }}` ({{ .LinkedAccountName }}), Region: `{{ .Region }}`, UsageType: `{{ .UsageType }}`
+{{- end }}
+{{ if .GraphPermalinks }}
+### Graphs
+{{ range .GraphPermalinks }}
+- {{ . }}
+{{- end }}
+{{ end }}
+Confirmed as an accurate anomaly by {{ .ConfirmedBy }}.
@@ -14,7 +14,7 @@
 			"type": "section",
 			"text": {
 				"type": "mrkdwn",
-				"text": "コスト異常を検知しました。\n\n- Start Date: {{ .Anomaly.AnomalyStartDate | to_date_str }}\n- End Date: {{ .Anomaly.AnomalyEndDate | to_date_str }}\n-  Total Impact: ${{ .Anomaly.Impact.TotalImpact }} \n"
+				"text": "コスト異常を検知しました。\n\n- Start Date: {{ .Anomaly.AnomalyStartDate | to_date_str }}\n- End Date: {{ .Anomaly.AnomalyEndDate | to_date_str }}\n-  Total Impact: ${{ .Anomaly.Impact.TotalImpact }} \n{{ if .Status }}- Status: {{ .Status }}\n{{ end }}{{ if .Owners }}- Owners:{{ range .Owners }} {{ json_escape . }}{{ end }}\n{{ end }}"
       }
 		},
     {{ range $i, $v := .Anomaly.RootCauses }}
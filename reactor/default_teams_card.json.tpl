@@ -0,0 +1,88 @@
+{
+	"type": "AdaptiveCard",
+	"$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+	"version": "1.4",
+	"body": [
+		{
+			"type": "TextBlock",
+			"size": "Medium",
+			"weight": "Bolder",
+			"wrap": true,
+			"text": "📣 AWS Cost Anomaly Detected | Account: {{ .Anomaly.AccountID }}"
+		},
+		{
+			"type": "TextBlock",
+			"wrap": true,
+			"text": "コスト異常を検知しました。"
+		},
+		{
+			"type": "FactSet",
+			"facts": [
+				{ "title": "Anomaly ID", "value": "{{ .Anomaly.AnomalyID }}" },
+				{ "title": "Start Date", "value": "{{ .Anomaly.AnomalyStartDate | to_date_str }}" },
+				{ "title": "End Date", "value": "{{ .Anomaly.AnomalyEndDate | to_date_str }}" },
+				{ "title": "Total Impact", "value": "${{ .Anomaly.Impact.TotalImpact }}" }{{ if .Status }},
+				{ "title": "Status", "value": "{{ .Status }}" }{{ end }}
+			]
+		},
+		{{ range $i, $v := .Anomaly.RootCauses }}
+		{
+			"type": "TextBlock",
+			"separator": true,
+			"weight": "Bolder",
+			"text": "根本原因 #{{ $i }}"
+		},
+		{
+			"type": "FactSet",
+			"facts": [
+				{ "title": "Service", "value": "{{ json_escape $v.Service }}" },
+				{ "title": "Account", "value": "{{ $v.LinkedAccount }}" },
+				{ "title": "AccountName", "value": "{{ json_escape $v.LinkedAccountName }}" },
+				{ "title": "Region", "value": "{{ $v.Region }}" },
+				{ "title": "UsageType", "value": "{{ json_escape $v.UsageType }}" }
+			]
+		},
+		{{ end }}
+		{
+			"type": "TextBlock",
+			"separator": true,
+			"wrap": true,
+			"text": "[AWS Console]({{ .Anomaly.AnomalyDetailsLink }})"
+		}
+	],
+	"actions": [
+		{
+			"type": "Action.Execute",
+			"title": "正確な異常",
+			"verb": "{{ .ActionsBlockID }}",
+			"data": { "anomaly_id": "{{ .Anomaly.AnomalyID }}", "action_id": "{{ .ActionsYesID }}" },
+			"fallback": {
+				"type": "Action.Submit",
+				"title": "正確な異常",
+				"data": { "anomaly_id": "{{ .Anomaly.AnomalyID }}", "action_id": "{{ .ActionsYesID }}" }
+			}
+		},
+		{
+			"type": "Action.Execute",
+			"title": "誤検出",
+			"verb": "{{ .ActionsBlockID }}",
+			"data": { "anomaly_id": "{{ .Anomaly.AnomalyID }}", "action_id": "{{ .ActionsNoID }}" },
+			"fallback": {
+				"type": "Action.Submit",
+				"title": "誤検出",
+				"data": { "anomaly_id": "{{ .Anomaly.AnomalyID }}", "action_id": "{{ .ActionsNoID }}" }
+			}
+		},
+		{
+			"type": "Action.Execute",
+			"title": "問題ではありません",
+			"verb": "{{ .ActionsBlockID }}",
+			"data": { "anomaly_id": "{{ .Anomaly.AnomalyID }}", "action_id": "{{ .ActionsPlanedActivityID }}" },
+			"fallback": {
+				"type": "Action.Submit",
+				"title": "問題ではありません",
+				"data": { "anomaly_id": "{{ .Anomaly.AnomalyID }}", "action_id": "{{ .ActionsPlanedActivityID }}" }
+			}
+		}
+	]
+}
@@ -0,0 +1,611 @@
+package reactor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"html/template"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Songmu/flextime"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer/types"
+	"github.com/aws/aws-sdk-go-v2/service/organizations"
+	orgtypes "github.com/aws/aws-sdk-go-v2/service/organizations/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/slack-go/slack"
+)
+
+// Development mode identities reported by the fake backends.
+const (
+	DevAWSAccountID = "123456789012"
+	DevSlackTeamID  = "TDEV"
+	DevSlackChannel = "#dev"
+	// DevSlackSigningSecret is the signing secret used in development mode
+	// unless one is configured, to sign requests sent to the Handler by hand.
+	DevSlackSigningSecret = "dev"
+	devSlackBaseURL       = "http://slack.dev.invalid/"
+	devDynamoDBTableName  = "dev"
+)
+
+// applyDevMode replaces Cost Explorer, Organizations, STS, the state store
+// and Slack with in-process fakes, and disables the integrations that would
+// call other external services.
+func (p *optionParams) applyDevMode() {
+	p.awsCfg = &aws.Config{Region: "us-east-1"}
+	p.stsClient = &devSTS{}
+	p.ceClient = &devCostExplorer{}
+	p.orgClient = &devOrganizations{}
+	p.ddbClient = newMemoryDynamoDB()
+	p.dynamodbTableName = devDynamoDBTableName
+	fake := newDevSlack(p.devDir, p.logger)
+	p.slackBotToken = "xoxb-dev"
+	p.slackClient = slack.New(p.slackBotToken,
+		slack.OptionAPIURL(devSlackBaseURL+"api/"),
+		slack.OptionHTTPClient(&http.Client{Transport: fake}),
+	)
+	if p.slackChannel == "" {
+		p.slackChannel = DevSlackChannel
+	}
+	if p.slackSignalSecret == "" {
+		p.slackSignalSecret = DevSlackSigningSecret
+	}
+	p.slackAppToken = ""
+	p.slackOAuth = SlackOAuthConfig{}
+	p.teams = TeamsConfig{}
+	p.github = GitHubConfig{}
+	p.escalation = EscalationConfig{}
+	p.webhookEndpoints = nil
+}
+
+// devCostExplorer is a fake Cost Explorer returning a synthetic daily cost
+// series for every filter. The series is deterministic for a given filter
+// and group so that graphs are stable across runs.
+type devCostExplorer struct {
+	mu            sync.Mutex
+	subscriptions []types.AnomalySubscription
+}
+
+var (
+	_ CostExplorerAPIClient    = (*devCostExplorer)(nil)
+	_ AnomalyMonitorsAPIClient = (*devCostExplorer)(nil)
+)
+
+const (
+	devAnomalyMonitorArn      = "arn:aws:ce::" + DevAWSAccountID + ":anomalymonitor/dev"
+	devAnomalySubscriptionArn = "arn:aws:ce::" + DevAWSAccountID + ":anomalysubscription/dev"
+)
+
+var devGroupKeys = map[string][]string{
+	string(types.DimensionLinkedAccount): {DevAWSAccountID, "210987654321"},
+}
+
+func (c *devCostExplorer) GetCostAndUsage(_ context.Context, params *costexplorer.GetCostAndUsageInput, _ ...func(*costexplorer.Options)) (*costexplorer.GetCostAndUsageOutput, error) {
+	start, err := time.Parse(time.DateOnly, aws.ToString(params.TimePeriod.Start))
+	if err != nil {
+		return nil, fmt.Errorf("invalid start: %w", err)
+	}
+	end, err := time.Parse(time.DateOnly, aws.ToString(params.TimePeriod.End))
+	if err != nil {
+		return nil, fmt.Errorf("invalid end: %w", err)
+	}
+	filter, _ := json.Marshal(params.Filter)
+	out := &costexplorer.GetCostAndUsageOutput{}
+	var groups [][]string
+	for _, g := range params.GroupBy {
+		out.GroupDefinitions = append(out.GroupDefinitions, g)
+		keys, ok := devGroupKeys[aws.ToString(g.Key)]
+		if !ok {
+			keys = []string{"dev-a", "dev-b"}
+		}
+		if len(groups) == 0 {
+			for _, k := range keys {
+				groups = append(groups, []string{k})
+			}
+			continue
+		}
+		var next [][]string
+		for _, prefix := range groups {
+			for _, k := range keys {
+				next = append(next, append(append([]string(nil), prefix...), k))
+			}
+		}
+		groups = next
+	}
+	for d := start; d.Before(end); d = d.AddDate(0, 0, 1) {
+		result := types.ResultByTime{
+			TimePeriod: &types.DateInterval{
+				Start: aws.String(d.Format(time.DateOnly)),
+				End:   aws.String(d.AddDate(0, 0, 1).Format(time.DateOnly)),
+			},
+			Estimated: true,
+		}
+		if len(groups) == 0 {
+			result.Total = map[string]types.MetricValue{
+				"NetUnblendedCost": devCost(string(filter), d),
+			}
+		}
+		for _, keys := range groups {
+			result.Groups = append(result.Groups, types.Group{
+				Keys: keys,
+				Metrics: map[string]types.MetricValue{
+					"NetUnblendedCost": devCost(string(filter)+strings.Join(keys, ","), d),
+				},
+			})
+		}
+		out.ResultsByTime = append(out.ResultsByTime, result)
+	}
+	return out, nil
+}
+
+// devCost returns the synthetic cost of seed on day d: a base level with a
+// weekly cycle, and a spike for two days in every 17.
+func devCost(seed string, d time.Time) types.MetricValue {
+	h := fnv.New32a()
+	h.Write([]byte(seed))
+	sum := h.Sum32()
+	base := 20 + float64(sum%80)
+	day := int(d.Unix() / 86400)
+	cost := base * (1 + 0.15*math.Sin(2*math.Pi*float64(day)/7))
+	if (day+int(sum%17))%17 < 2 {
+		cost += base * 2
+	}
+	return types.MetricValue{
+		Amount: aws.String(strconv.FormatFloat(cost, 'f', 4, 64)),
+		Unit:   aws.String("USD"),
+	}
+}
+
+func (c *devCostExplorer) GetAnomalies(_ context.Context, _ *costexplorer.GetAnomaliesInput, _ ...func(*costexplorer.Options)) (*costexplorer.GetAnomaliesOutput, error) {
+	return &costexplorer.GetAnomaliesOutput{}, nil
+}
+
+func (c *devCostExplorer) ProvideAnomalyFeedback(_ context.Context, params *costexplorer.ProvideAnomalyFeedbackInput, _ ...func(*costexplorer.Options)) (*costexplorer.ProvideAnomalyFeedbackOutput, error) {
+	return &costexplorer.ProvideAnomalyFeedbackOutput{AnomalyId: params.AnomalyId}, nil
+}
+
+func (c *devCostExplorer) GetAnomalyMonitors(_ context.Context, _ *costexplorer.GetAnomalyMonitorsInput, _ ...func(*costexplorer.Options)) (*costexplorer.GetAnomalyMonitorsOutput, error) {
+	return &costexplorer.GetAnomalyMonitorsOutput{
+		AnomalyMonitors: []types.AnomalyMonitor{{
+			MonitorArn:       aws.String(devAnomalyMonitorArn),
+			MonitorName:      aws.String("dev"),
+			MonitorType:      types.MonitorTypeDimensional,
+			MonitorDimension: types.MonitorDimensionService,
+		}},
+	}, nil
+}
+
+func (c *devCostExplorer) GetAnomalySubscriptions(_ context.Context, _ *costexplorer.GetAnomalySubscriptionsInput, _ ...func(*costexplorer.Options)) (*costexplorer.GetAnomalySubscriptionsOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.subscriptions == nil {
+		c.subscriptions = []types.AnomalySubscription{{
+			SubscriptionArn:  aws.String(devAnomalySubscriptionArn),
+			SubscriptionName: aws.String("dev"),
+			Frequency:        types.AnomalySubscriptionFrequencyImmediate,
+			MonitorArnList:   []string{devAnomalyMonitorArn},
+			Subscribers:      []types.Subscriber{{Type: types.SubscriberTypeSns, Address: aws.String("arn:aws:sns:us-east-1:" + DevAWSAccountID + ":dev")}},
+		}}
+		c.subscriptions[0].ThresholdExpression, _ = parseThresholdExpression("100")
+	}
+	return &costexplorer.GetAnomalySubscriptionsOutput{
+		AnomalySubscriptions: slices.Clone(c.subscriptions),
+	}, nil
+}
+
+func (c *devCostExplorer) UpdateAnomalySubscription(_ context.Context, params *costexplorer.UpdateAnomalySubscriptionInput, _ ...func(*costexplorer.Options)) (*costexplorer.UpdateAnomalySubscriptionOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, s := range c.subscriptions {
+		if aws.ToString(s.SubscriptionArn) != aws.ToString(params.SubscriptionArn) {
+			continue
+		}
+		if params.ThresholdExpression != nil {
+			c.subscriptions[i].ThresholdExpression = params.ThresholdExpression
+		}
+		if params.Frequency != "" {
+			c.subscriptions[i].Frequency = params.Frequency
+		}
+		return &costexplorer.UpdateAnomalySubscriptionOutput{SubscriptionArn: s.SubscriptionArn}, nil
+	}
+	return nil, fmt.Errorf("subscription %s is not found", aws.ToString(params.SubscriptionArn))
+}
+
+// devSTS is a fake STS returning DevAWSAccountID.
+type devSTS struct{}
+
+var _ STSAPIClient = (*devSTS)(nil)
+
+func (s *devSTS) GetCallerIdentity(_ context.Context, _ *sts.GetCallerIdentityInput, _ ...func(*sts.Options)) (*sts.GetCallerIdentityOutput, error) {
+	return &sts.GetCallerIdentityOutput{Account: aws.String(DevAWSAccountID)}, nil
+}
+
+// devOrganizations is a fake Organizations naming every account
+// "dev-<account id>" without tags.
+type devOrganizations struct{}
+
+var (
+	_ DescribeAccountAPIClient     = (*devOrganizations)(nil)
+	_ ListTagsForResourceAPIClient = (*devOrganizations)(nil)
+)
+
+func (o *devOrganizations) DescribeAccount(_ context.Context, params *organizations.DescribeAccountInput, _ ...func(*organizations.Options)) (*organizations.DescribeAccountOutput, error) {
+	id := aws.ToString(params.AccountId)
+	return &organizations.DescribeAccountOutput{
+		Account: &orgtypes.Account{
+			Id:   aws.String(id),
+			Name: aws.String("dev-" + id),
+		},
+	}, nil
+}
+
+func (o *devOrganizations) ListTagsForResource(_ context.Context, _ *organizations.ListTagsForResourceInput, _ ...func(*organizations.Options)) (*organizations.ListTagsForResourceOutput, error) {
+	return &organizations.ListTagsForResourceOutput{}, nil
+}
+
+// devSlack is a fake Slack Web API served in-process through its
+// RoundTrip. It keeps the posted messages, thread replies and uploaded files
+// and renders them into index.html in dir after every change.
+type devSlack struct {
+	dir    string
+	logger *slog.Logger
+	mux    *http.ServeMux
+
+	mu       sync.Mutex
+	seq      int
+	messages []*devSlackMessage
+	files    map[string]*devSlackFile
+}
+
+type devSlackMessage struct {
+	Channel string
+	TS      string
+	Text    string
+	Blocks  []map[string]any
+	Updated int
+	Replies []*devSlackMessage
+	Files   []*devSlackFile
+}
+
+type devSlackFile struct {
+	ID   string
+	Name string
+	Path string
+}
+
+var _ http.RoundTripper = (*devSlack)(nil)
+
+func newDevSlack(dir string, logger *slog.Logger) *devSlack {
+	s := &devSlack{
+		dir:    dir,
+		logger: logger,
+		mux:    http.NewServeMux(),
+		files:  make(map[string]*devSlackFile),
+	}
+	s.mux.HandleFunc("POST /api/auth.test", s.authTest)
+	s.mux.HandleFunc("POST /api/chat.postMessage", s.postMessage)
+	s.mux.HandleFunc("POST /api/chat.update", s.update)
+	s.mux.HandleFunc("POST /api/chat.postEphemeral", s.postEphemeral)
+	s.mux.HandleFunc("/api/usergroups.users.list", s.userGroupMembers)
+	s.mux.HandleFunc("POST /api/files.getUploadURLExternal", s.getUploadURL)
+	s.mux.HandleFunc("POST /upload/{id}", s.upload)
+	s.mux.HandleFunc("POST /api/files.completeUploadExternal", s.completeUpload)
+	s.mux.HandleFunc("POST /api/files.info", s.fileInfo)
+	s.mux.HandleFunc("/", func(w http.ResponseWriter, _ *http.Request) {
+		devSlackJSON(w, map[string]any{"ok": true})
+	})
+	return s
+}
+
+// RoundTrip implements http.RoundTripper by serving req with the fake.
+func (s *devSlack) RoundTrip(req *http.Request) (*http.Response, error) {
+	rec := httptest.NewRecorder()
+	s.mux.ServeHTTP(rec, req)
+	return rec.Result(), nil
+}
+
+func devSlackJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func devSlackError(w http.ResponseWriter, code string) {
+	devSlackJSON(w, map[string]any{"ok": false, "error": code})
+}
+
+func (s *devSlack) authTest(w http.ResponseWriter, _ *http.Request) {
+	devSlackJSON(w, map[string]any{
+		"ok":      true,
+		"url":     devSlackBaseURL,
+		"team":    "dev",
+		"user":    "reactor",
+		"team_id": DevSlackTeamID,
+		"user_id": "UDEV",
+		"bot_id":  "BDEV",
+	})
+}
+
+func (s *devSlack) nextTS() string {
+	s.seq++
+	return fmt.Sprintf("%d.%06d", flextime.Now().Unix(), s.seq)
+}
+
+// find returns the message or thread reply with the timestamp ts.
+func (s *devSlack) find(ts string) *devSlackMessage {
+	for _, m := range s.messages {
+		if m.TS == ts {
+			return m
+		}
+		for _, r := range m.Replies {
+			if r.TS == ts {
+				return r
+			}
+		}
+	}
+	return nil
+}
+
+func parseDevSlackBlocks(s string) []map[string]any {
+	var blocks []map[string]any
+	if s != "" {
+		json.Unmarshal([]byte(s), &blocks)
+	}
+	return blocks
+}
+
+func (s *devSlack) postMessage(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m := &devSlackMessage{
+		Channel: r.FormValue("channel"),
+		TS:      s.nextTS(),
+		Text:    r.FormValue("text"),
+		Blocks:  parseDevSlackBlocks(r.FormValue("blocks")),
+	}
+	if thread := r.FormValue("thread_ts"); thread != "" {
+		parent := s.find(thread)
+		if parent == nil {
+			devSlackError(w, "thread_not_found")
+			return
+		}
+		parent.Replies = append(parent.Replies, m)
+	} else {
+		s.messages = append(s.messages, m)
+	}
+	s.render()
+	devSlackJSON(w, map[string]any{"ok": true, "channel": m.Channel, "ts": m.TS})
+}
+
+func (s *devSlack) update(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m := s.find(r.FormValue("ts"))
+	if m == nil {
+		devSlackError(w, "message_not_found")
+		return
+	}
+	m.Text = r.FormValue("text")
+	m.Blocks = parseDevSlackBlocks(r.FormValue("blocks"))
+	m.Updated++
+	s.render()
+	devSlackJSON(w, map[string]any{"ok": true, "channel": m.Channel, "ts": m.TS})
+}
+
+// postEphemeral logs the ephemeral message, which is visible only to the
+// user and is not rendered.
+func (s *devSlack) postEphemeral(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.logger.Info("ephemeral message", "channel", r.FormValue("channel"), "user", r.FormValue("user"), "text", r.FormValue("text"))
+	devSlackJSON(w, map[string]any{"ok": true, "message_ts": s.nextTS()})
+}
+
+// userGroupMembers returns no members: there are no user groups in
+// development mode.
+func (s *devSlack) userGroupMembers(w http.ResponseWriter, _ *http.Request) {
+	devSlackJSON(w, map[string]any{"ok": true, "users": []string{}})
+}
+
+func (s *devSlack) getUploadURL(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.seq++
+	f := &devSlackFile{
+		ID:   fmt.Sprintf("FDEV%06d", s.seq),
+		Name: filepath.Base(r.FormValue("filename")),
+	}
+	s.files[f.ID] = f
+	devSlackJSON(w, map[string]any{
+		"ok":         true,
+		"upload_url": devSlackBaseURL + "upload/" + f.ID,
+		"file_id":    f.ID,
+	})
+}
+
+func (s *devSlack) upload(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	f, ok := s.files[r.PathValue("id")]
+	s.mu.Unlock()
+	if !ok {
+		http.Error(w, "file not found", http.StatusNotFound)
+		return
+	}
+	src, _, err := r.FormFile("file")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer src.Close()
+	var buf bytes.Buffer
+	if _, err := io.Copy(&buf, src); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	name := f.ID + "-" + f.Name
+	if err := os.MkdirAll(filepath.Join(s.dir, "files"), 0o755); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := os.WriteFile(filepath.Join(s.dir, "files", name), buf.Bytes(), 0o644); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	s.mu.Lock()
+	f.Path = "files/" + name
+	s.mu.Unlock()
+	io.WriteString(w, "OK")
+}
+
+func (s *devSlack) completeUpload(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var summaries []slack.FileSummary
+	if err := json.Unmarshal([]byte(r.FormValue("files")), &summaries); err != nil {
+		devSlackError(w, "invalid_arguments")
+		return
+	}
+	var parent *devSlackMessage
+	if thread := r.FormValue("thread_ts"); thread != "" {
+		parent = s.find(thread)
+	}
+	if parent == nil {
+		parent = &devSlackMessage{Channel: r.FormValue("channel_id"), TS: s.nextTS()}
+		s.messages = append(s.messages, parent)
+	}
+	for _, summary := range summaries {
+		f, ok := s.files[summary.ID]
+		if !ok {
+			devSlackError(w, "file_not_found")
+			return
+		}
+		parent.Files = append(parent.Files, f)
+	}
+	s.render()
+	devSlackJSON(w, map[string]any{"ok": true, "files": summaries})
+}
+
+func (s *devSlack) fileInfo(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, ok := s.files[r.FormValue("file")]
+	if !ok {
+		devSlackError(w, "file_not_found")
+		return
+	}
+	path := filepath.Join(s.dir, filepath.FromSlash(f.Path))
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	devSlackJSON(w, map[string]any{
+		"ok": true,
+		"file": map[string]any{
+			"id":        f.ID,
+			"name":      f.Name,
+			"permalink": "file://" + filepath.ToSlash(path),
+		},
+	})
+}
+
+var devSlackPage = template.Must(template.New("index.html").Funcs(template.FuncMap{
+	"text": textField,
+	"str":  stringField,
+	"items": func(m map[string]any, key string) []map[string]any {
+		var items []map[string]any
+		values, _ := m[key].([]any)
+		for _, v := range values {
+			if v, ok := v.(map[string]any); ok {
+				items = append(items, v)
+			}
+		}
+		return items
+	},
+	"builder": func(blocks []map[string]any) string {
+		bs, _ := json.Marshal(blocks)
+		var msg slack.Msg
+		if err := json.Unmarshal(bs, &msg.Blocks); err != nil {
+			return ""
+		}
+		link, _ := BlockKitBuilderLink(msg)
+		return link
+	},
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>aws-cost-anomaly-slack-reactor (dev)</title>
+<style>
+body { font-family: sans-serif; max-width: 960px; margin: 2em auto; color: #1d1c1d; }
+.message { border: 1px solid #ddd; border-radius: 6px; padding: 1em; margin-bottom: 1.5em; }
+.reply { border-left: 3px solid #ddd; margin: 1em 0 0 1em; padding-left: 1em; }
+.meta { color: #616061; font-size: 0.85em; }
+.section, .context { white-space: pre-wrap; margin: 0.5em 0; }
+.context { color: #616061; font-size: 0.9em; }
+.fields { display: grid; grid-template-columns: 1fr 1fr; gap: 0.5em; white-space: pre-wrap; }
+img { max-width: 100%; border: 1px solid #eee; }
+button { margin-right: 0.5em; }
+</style>
+</head>
+<body>
+<h1>Slack (dev)</h1>
+{{ define "blocks" }}
+{{ if .Text }}<div class="section">{{ .Text }}</div>{{ end }}
+{{ range .Blocks }}
+{{ $type := str . "type" }}
+{{ if eq $type "header" }}<h2>{{ text . "text" }}</h2>
+{{ else if eq $type "section" }}<div class="section">{{ text . "text" }}</div>
+{{ with items . "fields" }}<div class="fields">{{ range . }}<div>{{ str . "text" }}</div>{{ end }}</div>{{ end }}
+{{ else if eq $type "context" }}<div class="context">{{ range items . "elements" }}{{ str . "text" }} {{ end }}</div>
+{{ else if eq $type "divider" }}<hr>
+{{ else if eq $type "image" }}<img src="{{ str . "image_url" }}" alt="{{ str . "alt_text" }}">
+{{ else if eq $type "actions" }}<div>{{ range items . "elements" }}<button disabled title="{{ str . "action_id" }}">{{ text . "text" }}</button>{{ end }}</div>
+{{ end }}
+{{ end }}
+{{ range .Files }}<div><img src="{{ .Path }}" alt="{{ .Name }}"></div>{{ end }}
+{{ end }}
+{{ range .Messages }}
+<div class="message">
+<div class="meta">{{ .Channel }} ts={{ .TS }}{{ if .Updated }} (updated {{ .Updated }} times){{ end }}{{ with builder .Blocks }} <a href="{{ . }}">Block Kit Builder</a>{{ end }}</div>
+{{ template "blocks" . }}
+{{ range .Replies }}
+<div class="reply">
+<div class="meta">ts={{ .TS }}</div>
+{{ template "blocks" . }}
+</div>
+{{ end }}
+</div>
+{{ else }}
+<p>No messages yet.</p>
+{{ end }}
+</body>
+</html>
+`))
+
+// render writes index.html. It is called with mu held.
+func (s *devSlack) render() {
+	var buf bytes.Buffer
+	err := devSlackPage.Execute(&buf, struct {
+		Messages []*devSlackMessage
+	}{s.messages})
+	if err == nil {
+		if err = os.MkdirAll(s.dir, 0o755); err == nil {
+			err = os.WriteFile(filepath.Join(s.dir, "index.html"), buf.Bytes(), 0o644)
+		}
+	}
+	if err != nil {
+		s.logger.Warn("failed to render dev slack page", "dir", s.dir, "error", err)
+	}
+}
@@ -0,0 +1,45 @@
+package reactor
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDevMode(t *testing.T) {
+	for _, env := range []string{"SLACK_TOKEN", "SLACK_BOT_TOKEN", "SLACK_CHANNEL", "SLACK_SIGNING_SECRET"} {
+		t.Setenv(env, "")
+		os.Unsetenv(env)
+	}
+	dir := t.TempDir()
+	ctx := context.Background()
+	h, err := New(ctx, WithDevMode(dir), WithLogger(slog.Default()))
+	require.NoError(t, err)
+	require.Equal(t, DevSlackTeamID, h.slackTeamID)
+	require.Equal(t, DevAWSAccountID, h.awsAccountID)
+	require.True(t, h.EnableDynamoDB())
+
+	a := loadTestAnomaly(t, "testdata/anomaly.json")
+	require.NoError(t, h.postAnomalyDetectedMessage(ctx, a))
+	msg, ok, err := h.GetAnomalySlackMessage(ctx, a.AnomalyID)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Len(t, msg.GraphPermalinks, 1)
+
+	a.Impact.TotalImpact = 1500
+	require.NoError(t, h.postAnomalyDetectedMessage(ctx, a))
+
+	bs, err := os.ReadFile(filepath.Join(dir, "index.html"))
+	require.NoError(t, err)
+	page := string(bs)
+	require.Contains(t, page, "updated 1 times")
+	require.Contains(t, page, "Total Impact updated")
+	require.Contains(t, page, `<img src="files/`)
+	files, err := filepath.Glob(filepath.Join(dir, "files", "*-root-cause1.png"))
+	require.NoError(t, err)
+	require.Len(t, files, 2)
+}
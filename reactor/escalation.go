@@ -0,0 +1,298 @@
+package reactor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultPagerDutyEventsURL = "https://events.pagerduty.com/v2/enqueue"
+	defaultOpsgenieAPIURL     = "https://api.opsgenie.com"
+	escalationSource          = "aws-cost-anomaly-slack-reactor"
+)
+
+// EscalationConfig configures paging PagerDuty and/or Opsgenie for anomalies
+// whose impact reaches a threshold. The AnomalyID is used as the dedup key,
+// so updates of the same anomaly do not open new incidents, and the incident
+// is resolved when the anomaly gets a No or PlannedActivity feedback.
+type EscalationConfig struct {
+	// MinTotalImpact and MinTotalImpactPercentage are the thresholds. An
+	// anomaly is escalated when it reaches any non-zero threshold.
+	MinTotalImpact           float64 `json:"minTotalImpact,omitempty"`
+	MinTotalImpactPercentage float64 `json:"minTotalImpactPercentage,omitempty"`
+
+	// PagerDutyRoutingKey is the integration key of an Events API v2
+	// integration.
+	PagerDutyRoutingKey string `json:"pagerDutyRoutingKey,omitempty"`
+	// PagerDutySeverity is critical, error, warning or info. Defaults to
+	// error.
+	PagerDutySeverity string `json:"pagerDutySeverity,omitempty"`
+	// PagerDutyEventsURL overrides the Events API v2 endpoint.
+	PagerDutyEventsURL string `json:"pagerDutyEventsUrl,omitempty"`
+
+	// OpsgenieAPIKey is the key of an API integration.
+	OpsgenieAPIKey string `json:"opsgenieApiKey,omitempty"`
+	// OpsgeniePriority is P1 to P5. Defaults to P2.
+	OpsgeniePriority string `json:"opsgeniePriority,omitempty"`
+	// OpsgenieAPIURL overrides the API base URL, e.g. https://api.eu.opsgenie.com .
+	OpsgenieAPIURL string `json:"opsgenieApiUrl,omitempty"`
+}
+
+// Enabled reports whether a threshold and at least one destination are
+// configured.
+func (cfg EscalationConfig) Enabled() bool {
+	return (cfg.MinTotalImpact > 0 || cfg.MinTotalImpactPercentage > 0) &&
+		(cfg.PagerDutyRoutingKey != "" || cfg.OpsgenieAPIKey != "")
+}
+
+// ShouldEscalate reports whether a reaches a threshold.
+func (cfg EscalationConfig) ShouldEscalate(a Anomaly) bool {
+	if cfg.MinTotalImpact > 0 && a.Impact.TotalImpact >= cfg.MinTotalImpact {
+		return true
+	}
+	if cfg.MinTotalImpactPercentage > 0 && a.Impact.TotalImpactPercentage >= cfg.MinTotalImpactPercentage {
+		return true
+	}
+	return false
+}
+
+// escalator is an incident management service anomalies are escalated to.
+type escalator interface {
+	Name() string
+	Trigger(ctx context.Context, a Anomaly) error
+	Resolve(ctx context.Context, anomalyID string, reason string) error
+}
+
+func newEscalators(cfg EscalationConfig, currency string, policy RetryPolicy) []escalator {
+	var escalators []escalator
+	if cfg.PagerDutyRoutingKey != "" {
+		escalators = append(escalators, &pagerDutyEscalator{cfg: cfg, currency: currency, client: http.DefaultClient, retryPolicy: policy})
+	}
+	if cfg.OpsgenieAPIKey != "" {
+		escalators = append(escalators, &opsgenieEscalator{cfg: cfg, currency: currency, client: http.DefaultClient, retryPolicy: policy})
+	}
+	return escalators
+}
+
+func escalationSummary(a Anomaly, currency string) string {
+	services := make([]string, 0, len(a.RootCauses))
+	for _, rc := range a.RootCauses {
+		if rc.Service != "" {
+			services = append(services, rc.Service)
+		}
+	}
+	summary := fmt.Sprintf("AWS Cost Anomaly: %s (%.2f%%) in account %s", formatAmount(a.Impact.TotalImpact, currency), a.Impact.TotalImpactPercentage, a.AccountID)
+	if len(services) > 0 {
+		summary += " [" + strings.Join(services, ", ") + "]"
+	}
+	return summary
+}
+
+func escalationDetails(a Anomaly) map[string]any {
+	rootCauses := make([]string, 0, len(a.RootCauses))
+	for _, rc := range a.RootCauses {
+		rootCauses = append(rootCauses, rc.String())
+	}
+	return map[string]any{
+		"anomaly_id":              a.AnomalyID,
+		"account_id":              a.AccountID,
+		"monitor_arn":             a.MonitorArn,
+		"anomaly_start_date":      a.AnomalyStartDate.Format("2006-01-02"),
+		"anomaly_end_date":        a.AnomalyEndDate.Format("2006-01-02"),
+		"total_impact":            a.Impact.TotalImpact,
+		"total_impact_percentage": a.Impact.TotalImpactPercentage,
+		"total_actual_spend":      a.Impact.TotalActualSpend,
+		"total_expected_spend":    a.Impact.TotalExpectedSpend,
+		"root_causes":             rootCauses,
+		"details_link":            a.AnomalyDetailsLink,
+	}
+}
+
+// https://developer.pagerduty.com/docs/events-api-v2/trigger-events/
+type pagerDutyEscalator struct {
+	cfg         EscalationConfig
+	currency    string
+	client      *http.Client
+	retryPolicy RetryPolicy
+}
+
+type pagerDutyEvent struct {
+	RoutingKey  string            `json:"routing_key"`
+	EventAction string            `json:"event_action"`
+	DedupKey    string            `json:"dedup_key"`
+	Payload     *pagerDutyPayload `json:"payload,omitempty"`
+	Links       []pagerDutyLink   `json:"links,omitempty"`
+}
+
+type pagerDutyPayload struct {
+	Summary       string         `json:"summary"`
+	Source        string         `json:"source"`
+	Severity      string         `json:"severity"`
+	Timestamp     string         `json:"timestamp,omitempty"`
+	Component     string         `json:"component,omitempty"`
+	CustomDetails map[string]any `json:"custom_details,omitempty"`
+}
+
+type pagerDutyLink struct {
+	Href string `json:"href"`
+	Text string `json:"text"`
+}
+
+func (e *pagerDutyEscalator) Name() string {
+	return "pagerduty"
+}
+
+func (e *pagerDutyEscalator) send(ctx context.Context, ev *pagerDutyEvent) error {
+	eventsURL := e.cfg.PagerDutyEventsURL
+	if eventsURL == "" {
+		eventsURL = defaultPagerDutyEventsURL
+	}
+	return e.retryPolicy.Do(ctx, func(ctx context.Context) error {
+		return doJSONRequest(ctx, e.client, http.MethodPost, eventsURL, nil, ev, nil)
+	})
+}
+
+func (e *pagerDutyEscalator) Trigger(ctx context.Context, a Anomaly) error {
+	severity := e.cfg.PagerDutySeverity
+	if severity == "" {
+		severity = "error"
+	}
+	var component string
+	if len(a.RootCauses) > 0 {
+		component = a.RootCauses[0].Service
+	}
+	ev := &pagerDutyEvent{
+		RoutingKey:  e.cfg.PagerDutyRoutingKey,
+		EventAction: "trigger",
+		DedupKey:    a.AnomalyID,
+		Payload: &pagerDutyPayload{
+			Summary:       escalationSummary(a, e.currency),
+			Source:        escalationSource,
+			Severity:      severity,
+			Timestamp:     a.AnomalyStartDate.Format(time.RFC3339),
+			Component:     component,
+			CustomDetails: escalationDetails(a),
+		},
+	}
+	if a.AnomalyDetailsLink != "" {
+		ev.Links = []pagerDutyLink{{Href: a.AnomalyDetailsLink, Text: "AWS Console"}}
+	}
+	return e.send(ctx, ev)
+}
+
+func (e *pagerDutyEscalator) Resolve(ctx context.Context, anomalyID string, _ string) error {
+	return e.send(ctx, &pagerDutyEvent{
+		RoutingKey:  e.cfg.PagerDutyRoutingKey,
+		EventAction: "resolve",
+		DedupKey:    anomalyID,
+	})
+}
+
+// https://docs.opsgenie.com/docs/alert-api
+type opsgenieEscalator struct {
+	cfg         EscalationConfig
+	currency    string
+	client      *http.Client
+	retryPolicy RetryPolicy
+}
+
+func (e *opsgenieEscalator) Name() string {
+	return "opsgenie"
+}
+
+func (e *opsgenieEscalator) send(ctx context.Context, path string, in any) error {
+	apiURL := e.cfg.OpsgenieAPIURL
+	if apiURL == "" {
+		apiURL = defaultOpsgenieAPIURL
+	}
+	header := http.Header{}
+	header.Set("Authorization", "GenieKey "+e.cfg.OpsgenieAPIKey)
+	return e.retryPolicy.Do(ctx, func(ctx context.Context) error {
+		return doJSONRequest(ctx, e.client, http.MethodPost, strings.TrimSuffix(apiURL, "/")+path, header, in, nil)
+	})
+}
+
+func (e *opsgenieEscalator) Trigger(ctx context.Context, a Anomaly) error {
+	priority := e.cfg.OpsgeniePriority
+	if priority == "" {
+		priority = "P2"
+	}
+	details := make(map[string]string)
+	for k, v := range escalationDetails(a) {
+		switch v := v.(type) {
+		case string:
+			details[k] = v
+		case float64:
+			details[k] = strconv.FormatFloat(v, 'f', 2, 64)
+		case []string:
+			details[k] = strings.Join(v, "\n")
+		}
+	}
+	return e.send(ctx, "/v2/alerts", map[string]any{
+		"message":     truncate(escalationSummary(a, e.currency), 130),
+		"alias":       a.AnomalyID,
+		"description": a.AnomalyDetailsLink,
+		"details":     details,
+		"priority":    priority,
+		"source":      escalationSource,
+		"tags":        []string{"aws-cost-anomaly"},
+	})
+}
+
+func (e *opsgenieEscalator) Resolve(ctx context.Context, anomalyID string, reason string) error {
+	return e.send(ctx, "/v2/alerts/"+url.PathEscape(anomalyID)+"/close?identifierType=alias", map[string]any{
+		"source": escalationSource,
+		"note":   reason,
+	})
+}
+
+func truncate(str string, n int) string {
+	r := []rune(str)
+	if len(r) <= n {
+		return str
+	}
+	return string(r[:n-1]) + "…"
+}
+
+// escalateAnomaly triggers an incident for a on every escalator when it
+// reaches the thresholds. An anomaly that is resolved or dismissed by
+// feedback in its lifecycle lc, which is nil without the DynamoDB table, is
+// not escalated again by its updates.
+func (h *Handler) escalateAnomaly(ctx context.Context, a Anomaly, lc *AnomalyLifecycle) error {
+	if len(h.escalators) == 0 || !h.escalation.ShouldEscalate(a) {
+		return nil
+	}
+	if lc != nil && (lc.Status == AnomalyStatusResolved || lc.dismissed()) {
+		h.logger.InfoContext(ctx, "skip escalation of dismissed anomaly", "anomaly_id", a.AnomalyID, "status", lc.Status)
+		return nil
+	}
+	var errs []error
+	for _, e := range h.escalators {
+		if err := e.Trigger(ctx, a); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", e.Name(), err))
+			continue
+		}
+		h.logger.InfoContext(ctx, "escalated anomaly", "anomaly_id", a.AnomalyID, "escalator", e.Name(), "total_impact", a.Impact.TotalImpact)
+	}
+	return errors.Join(errs...)
+}
+
+// resolveEscalation resolves the incidents of an anomaly. Resolving an
+// anomaly that was never escalated is a no-op on both services.
+func (h *Handler) resolveEscalation(ctx context.Context, anomalyID string, reason string) error {
+	var errs []error
+	for _, e := range h.escalators {
+		if err := e.Resolve(ctx, anomalyID, reason); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", e.Name(), err))
+			continue
+		}
+		h.logger.InfoContext(ctx, "resolved escalation", "anomaly_id", anomalyID, "escalator", e.Name())
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,155 @@
+package reactor
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer/types"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type recordedEscalation struct {
+	Path string
+	Body map[string]any
+}
+
+func newFakeEscalationServer(t *testing.T) (*httptest.Server, func() []recordedEscalation) {
+	t.Helper()
+	var mu sync.Mutex
+	var records []recordedEscalation
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v2/enqueue" {
+			require.Equal(t, "GenieKey og-key", r.Header.Get("Authorization"))
+		}
+		var body map[string]any
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		mu.Lock()
+		records = append(records, recordedEscalation{Path: r.URL.RequestURI(), Body: body})
+		mu.Unlock()
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, func() []recordedEscalation {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]recordedEscalation(nil), records...)
+	}
+}
+
+func TestEscalationConfigShouldEscalate(t *testing.T) {
+	a := Anomaly{Impact: AnomalyImpact{TotalImpact: 500, TotalImpactPercentage: 40}}
+	cases := []struct {
+		name     string
+		cfg      EscalationConfig
+		expected bool
+	}{
+		{name: "no threshold", cfg: EscalationConfig{}, expected: false},
+		{name: "impact reached", cfg: EscalationConfig{MinTotalImpact: 500}, expected: true},
+		{name: "impact not reached", cfg: EscalationConfig{MinTotalImpact: 1000}, expected: false},
+		{name: "percentage reached", cfg: EscalationConfig{MinTotalImpact: 1000, MinTotalImpactPercentage: 30}, expected: true},
+		{name: "percentage not reached", cfg: EscalationConfig{MinTotalImpactPercentage: 50}, expected: false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			require.Equal(t, c.expected, c.cfg.ShouldEscalate(a))
+		})
+	}
+}
+
+func TestEscalationSummary(t *testing.T) {
+	a := loadTestAnomaly(t, "testdata/anomaly.json")
+	require.Equal(t, "AWS Cost Anomaly: $1,001.00 (333.67%) in account 123456789012 [Amazon Relational Database Service]", escalationSummary(a, ""))
+	require.Equal(t, "AWS Cost Anomaly: ¥1,001 (333.67%) in account 123456789012 [Amazon Relational Database Service]", escalationSummary(a, "JPY"))
+}
+
+func TestHandlerEscalation(t *testing.T) {
+	srv, records := newFakeEscalationServer(t)
+	cfg := EscalationConfig{
+		MinTotalImpact:      1000,
+		PagerDutyRoutingKey: "pd-key",
+		PagerDutyEventsURL:  srv.URL + "/v2/enqueue",
+		OpsgenieAPIKey:      "og-key",
+		OpsgenieAPIURL:      srv.URL,
+	}
+	mockClient := mockGetCostAndUsageAPIClient{t: t}
+	defer mockClient.AssertExpectations(t)
+	mockClient.On("ProvideAnomalyFeedback", mock.Anything, &costexplorer.ProvideAnomalyFeedbackInput{
+		AnomalyId: aws.String("12345678-abcd-ef12-3456-987654321a12"),
+		Feedback:  types.AnomalyFeedbackTypePlannedActivity,
+	}).Return(&costexplorer.ProvideAnomalyFeedbackOutput{}, nil).Once()
+	h := &Handler{
+		ce:             &mockClient,
+		notifiers:      []Notifier{&recordingNotifier{}},
+		logger:         slog.Default(),
+		graphGenerator: newTestGraphGenerator(t),
+		escalation:     cfg,
+		escalators:     newEscalators(cfg, "", RetryPolicy{}),
+	}
+	ctx := context.Background()
+	a := loadTestAnomaly(t, "testdata/anomaly.json")
+	a.Impact.TotalImpact = 999
+	require.NoError(t, h.postAnomalyDetectedMessage(ctx, a))
+	require.Empty(t, records())
+
+	a.Impact.TotalImpact = 1001
+	require.NoError(t, h.postAnomalyDetectedMessage(ctx, a))
+	require.NoError(t, h.ProvideFeedback(ctx, a.AnomalyID, actionsPlanedActivityID))
+
+	got := records()
+	require.Len(t, got, 4)
+	require.Equal(t, "/v2/enqueue", got[0].Path)
+	require.Equal(t, "trigger", got[0].Body["event_action"])
+	require.Equal(t, a.AnomalyID, got[0].Body["dedup_key"])
+	require.Equal(t, "pd-key", got[0].Body["routing_key"])
+	require.Equal(t, "/v2/alerts", got[1].Path)
+	require.Equal(t, a.AnomalyID, got[1].Body["alias"])
+	require.Equal(t, "P2", got[1].Body["priority"])
+	require.Equal(t, "/v2/enqueue", got[2].Path)
+	require.Equal(t, "resolve", got[2].Body["event_action"])
+	require.Equal(t, a.AnomalyID, got[2].Body["dedup_key"])
+	require.Equal(t, "/v2/alerts/"+a.AnomalyID+"/close?identifierType=alias", got[3].Path)
+}
+
+func TestHandlerEscalationSkipsDismissedAnomaly(t *testing.T) {
+	srv, records := newFakeEscalationServer(t)
+	cfg := EscalationConfig{
+		MinTotalImpact:      1000,
+		PagerDutyRoutingKey: "pd-key",
+		PagerDutyEventsURL:  srv.URL + "/v2/enqueue",
+	}
+	h := &Handler{
+		notifiers:         []Notifier{&recordingNotifier{id: "T0123"}},
+		logger:            slog.Default(),
+		graphGenerator:    newTestGraphGenerator(t),
+		ddb:               newMemoryDynamoDB(),
+		dynamodbTableName: "test",
+		escalation:        cfg,
+		escalators:        newEscalators(cfg, "", RetryPolicy{}),
+	}
+	ctx := context.Background()
+	a := loadTestAnomaly(t, "testdata/anomaly.json")
+	a.Impact.TotalImpact = 1001
+	require.NoError(t, h.postAnomalyDetectedMessage(ctx, a))
+	require.Len(t, records(), 1)
+
+	for _, feedback := range []string{actionsNoID, actionsPlanedActivityID} {
+		require.NoError(t, h.trackFeedback(ctx, a.AnomalyID, feedback, "alice", "U0123ABCDE", "slack"))
+		a.Impact.TotalImpact += 100
+		require.NoError(t, h.postAnomalyDetectedMessage(ctx, a))
+		require.Len(t, records(), 1, "the dismissed anomaly is escalated again")
+	}
+
+	// the anomaly is escalated again once it is confirmed
+	require.NoError(t, h.trackFeedback(ctx, a.AnomalyID, actionsYesID, "alice", "U0123ABCDE", "slack"))
+	a.Impact.TotalImpact += 100
+	require.NoError(t, h.postAnomalyDetectedMessage(ctx, a))
+	require.Len(t, records(), 2)
+}
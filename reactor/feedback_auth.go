@@ -0,0 +1,156 @@
+package reactor
+
+import (
+	"context"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// FeedbackAuthConfig restricts who may submit anomaly feedback from Slack.
+// Principals are Slack user IDs (U0123ABCDE), user group IDs (S0123ABCDE) or
+// mentions such as <!subteam^S0123ABCDE>. Feedback is open to everyone when
+// nothing is configured.
+type FeedbackAuthConfig struct {
+	// Users are the principals allowed to submit feedback for any anomaly.
+	Users []string `json:"users,omitempty"`
+	// Accounts maps an account ID (the anomaly account or a root-cause
+	// linked account) to the principals allowed for its anomalies.
+	Accounts map[string][]string `json:"accounts,omitempty"`
+	// Owners allows the owners of the anomaly resolved by OwnerConfig.
+	Owners bool `json:"owners,omitempty"`
+}
+
+// Enabled reports whether feedback is restricted.
+func (cfg FeedbackAuthConfig) Enabled() bool {
+	return len(cfg.Users) > 0 || len(cfg.Accounts) > 0 || cfg.Owners
+}
+
+// ParseFeedbackAuthAccounts parses "account=principal|principal" pairs
+// separated by commas, as given in the FEEDBACK_ALLOWED_ACCOUNTS environment
+// variable.
+func ParseFeedbackAuthAccounts(str string) (map[string][]string, error) {
+	m, err := ParseOwnerMap(str)
+	if err != nil {
+		return nil, err
+	}
+	accounts := make(map[string][]string, len(m))
+	for account, principals := range m {
+		accounts[account] = strings.Split(principals, "|")
+	}
+	return accounts, nil
+}
+
+// userGroupIDPattern matches the ID of a Slack user group.
+var userGroupIDPattern = regexp.MustCompile(`^S[A-Z0-9]{8,}$`)
+
+// principalID returns the user or user group ID of a principal.
+func principalID(principal string) string {
+	principal = strings.TrimSpace(principal)
+	switch {
+	case strings.HasPrefix(principal, "<!subteam^"):
+		id, _, _ := strings.Cut(strings.TrimPrefix(principal, "<!subteam^"), "|")
+		return strings.TrimSuffix(id, ">")
+	case strings.HasPrefix(principal, "<@"):
+		id, _, _ := strings.Cut(strings.TrimPrefix(principal, "<@"), "|")
+		return strings.TrimSuffix(id, ">")
+	default:
+		return principal
+	}
+}
+
+// feedbackPrincipals returns the principals allowed to submit feedback for
+// the anomaly. The rules by account and owner are skipped when the anomaly
+// is not found in the state store.
+func (h *Handler) feedbackPrincipals(ctx context.Context, anomalyID string) ([]string, error) {
+	cfg := h.feedbackAuth
+	principals := slices.Clone(cfg.Users)
+	if len(cfg.Accounts) == 0 && !cfg.Owners {
+		return principals, nil
+	}
+	if !h.EnableDynamoDB() {
+		return principals, nil
+	}
+	msg, ok, err := h.GetAnomalySlackMessage(ctx, anomalyID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok || msg.Anomaly == nil {
+		h.logger.WarnContext(ctx, "anomaly not found for feedback authorization", "anomaly_id", anomalyID)
+		return principals, nil
+	}
+	a := *msg.Anomaly
+	accountIDs := []string{a.AccountID}
+	for _, rc := range a.RootCauses {
+		accountIDs = append(accountIDs, rc.LinkedAccount)
+	}
+	for _, id := range accountIDs {
+		principals = append(principals, cfg.Accounts[id]...)
+	}
+	if cfg.Owners {
+		principals = append(principals, h.resolveOwners(ctx, a)...)
+	}
+	return principals, nil
+}
+
+// authorizeFeedback reports whether the Slack user of the workspace may
+// submit feedback for the anomaly. User groups are expanded with
+// usergroups.users.list, which needs the usergroups:read scope. A user group
+// that fails to expand is skipped.
+func (h *Handler) authorizeFeedback(ctx context.Context, workspace *SlackNotifier, anomalyID string, userID string) (bool, error) {
+	if !h.feedbackAuth.Enabled() {
+		return true, nil
+	}
+	principals, err := h.feedbackPrincipals(ctx, anomalyID)
+	if err != nil {
+		return false, err
+	}
+	seen := make(map[string]bool)
+	for _, p := range principals {
+		id := principalID(p)
+		if id == "" || seen[id] {
+			continue
+		}
+		seen[id] = true
+		if id == userID {
+			return true, nil
+		}
+		if !userGroupIDPattern.MatchString(id) {
+			continue
+		}
+		members, err := workspace.userGroupMembers(ctx, id)
+		if err != nil {
+			// a broken user group must not deny the other principals
+			h.logger.WarnContext(ctx, "failed to get members of user group", "user_group", id, "error", err)
+			continue
+		}
+		if slices.Contains(members, userID) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// authorizeTeamsFeedback reports whether the Microsoft Teams user may submit
+// feedback for the anomaly. The principals are compared with the Teams user
+// ID and the Microsoft Entra object ID as is, since Slack user groups can not
+// be expanded for Teams users.
+func (h *Handler) authorizeTeamsFeedback(ctx context.Context, anomalyID string, from *teamsChannelAccount) (bool, error) {
+	if !h.feedbackAuth.Enabled() {
+		return true, nil
+	}
+	if from == nil {
+		return false, nil
+	}
+	principals, err := h.feedbackPrincipals(ctx, anomalyID)
+	if err != nil {
+		return false, err
+	}
+	for _, p := range principals {
+		id := principalID(p)
+		if id != "" && (id == from.ID || id == from.AADObjectID) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,86 @@
+package reactor
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer/types"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFeedbackAuthAccounts(t *testing.T) {
+	accounts, err := ParseFeedbackAuthAccounts("123456789012=U0123|S0456, 210987654321=<!subteam^S0789>")
+	require.NoError(t, err)
+	require.Equal(t, map[string][]string{
+		"123456789012": {"U0123", "S0456"},
+		"210987654321": {"<!subteam^S0789>"},
+	}, accounts)
+	_, err = ParseFeedbackAuthAccounts("123456789012")
+	require.Error(t, err)
+}
+
+func TestPrincipalID(t *testing.T) {
+	for principal, want := range map[string]string{
+		"U0123":                 "U0123",
+		"<@U0123>":              "U0123",
+		"<!subteam^S0456>":      "S0456",
+		"<!subteam^S0456|@ops>": "S0456",
+	} {
+		require.Equal(t, want, principalID(principal), principal)
+	}
+}
+
+func TestHandlerSlackFeedbackAuthorization(t *testing.T) {
+	s := newHandlerTestSuite(t, WithFeedbackAuth(FeedbackAuthConfig{
+		// the lookup of the first user group fails
+		Users: []string{"S0BROKEN01", "S0OPS0001"},
+		Accounts: map[string][]string{
+			"123456789012": {"<@U0ACCOUNT>"},
+			"210987654321": {"U0OTHER"},
+		},
+	}))
+	s.slack.groups = map[string][]string{"S0OPS0001": {"U0OPS"}}
+	a := loadTestAnomaly(t, "testdata/anomaly.json")
+	require.Equal(t, http.StatusOK, s.postSNS(s.notification(a)).StatusCode)
+	s.ce.On("ProvideAnomalyFeedback", mock.Anything, &costexplorer.ProvideAnomalyFeedbackInput{
+		AnomalyId: aws.String(a.AnomalyID),
+		Feedback:  types.AnomalyFeedbackTypeYes,
+	}).Return(&costexplorer.ProvideAnomalyFeedbackOutput{AnomalyId: aws.String(a.AnomalyID)}, nil).Twice()
+
+	for _, user := range []string{"U0456", "U0OTHER"} {
+		resp := s.postFeedback(user, "mallory", a.AnomalyID)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		calls := s.slack.Calls()
+		last := calls[len(calls)-1]
+		require.Equal(t, "chat.postEphemeral", last.Method)
+		require.Equal(t, "C0123", last.Channel)
+		require.Equal(t, user, last.User)
+		require.Equal(t, "You are not allowed to provide feedback for AnomalyID `12345678-abcd-ef12-3456-987654321a12` .", last.Text)
+	}
+	s.ce.AssertNotCalled(t, "ProvideAnomalyFeedback", mock.Anything, mock.Anything)
+
+	for _, user := range []string{"U0ACCOUNT", "U0OPS"} {
+		resp := s.postFeedback(user, "alice", a.AnomalyID)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		calls := s.slack.Calls()
+		last := calls[len(calls)-1]
+		require.Equal(t, "chat.postMessage", last.Method)
+		require.Equal(t, "Feedback of `Yes` was provided for AnomalyID `12345678-abcd-ef12-3456-987654321a12` by user `alice` .", last.Text)
+	}
+	s.ce.AssertExpectations(t)
+
+	lc, ok, err := s.h.GetAnomalyLifecycle(context.Background(), a.AnomalyID)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Len(t, lc.DeniedFeedback, 2)
+	require.Equal(t, "U0456", lc.DeniedFeedback[0].UserID)
+	require.Equal(t, types.AnomalyFeedbackTypeYes, lc.DeniedFeedback[0].Type)
+	require.Equal(t, "U0OTHER", lc.DeniedFeedback[1].UserID)
+	require.Len(t, lc.Feedback, 2)
+	require.Equal(t, "U0ACCOUNT", lc.Feedback[0].UserID)
+	require.Equal(t, "U0OPS", lc.Feedback[1].UserID)
+}
@@ -0,0 +1,124 @@
+package reactor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"text/template"
+
+	"github.com/slack-go/slack"
+)
+
+// FileNotifier writes the anomaly messages and graphs to a local directory
+// instead of posting them, for dry runs. An anomaly message is written as
+// <thread>.json with the Slack message rendered from the template, thread
+// replies are appended to <thread>.txt and graphs are written with their
+// upload name.
+type FileNotifier struct {
+	dir    string
+	render *SlackNotifier
+}
+
+var _ Notifier = (*FileNotifier)(nil)
+
+// NewFileNotifier returns a FileNotifier writing into dir, which is created
+// when missing.
+func NewFileNotifier(dir string, tpl *template.Template) *FileNotifier {
+	return &FileNotifier{
+		dir:    dir,
+		render: NewSlackNotifier(nil, "", "", tpl, RetryPolicy{}),
+	}
+}
+
+// ID identifies the FileNotifier. It never matches the ID of a Slack or Teams
+// notifier.
+func (n *FileNotifier) ID() string {
+	return "file"
+}
+
+// Dir returns the directory the FileNotifier writes to.
+func (n *FileNotifier) Dir() string {
+	return n.dir
+}
+
+// PostAnomaly implements Notifier. The returned thread is
+// "anomaly-<AnomalyID>".
+func (n *FileNotifier) PostAnomaly(ctx context.Context, data TemplateData) (string, error) {
+	thread := "anomaly-" + data.Anomaly.AnomalyID
+	if err := n.UpdateAnomaly(ctx, thread, data); err != nil {
+		return "", err
+	}
+	return thread, nil
+}
+
+// UpdateAnomaly implements Notifier by overwriting <thread>.json.
+func (n *FileNotifier) UpdateAnomaly(_ context.Context, thread string, data TemplateData) error {
+	msg, err := n.render.renderMessage(data)
+	if err != nil {
+		return err
+	}
+	bs, err := json.MarshalIndent(struct {
+		Text        string             `json:"text,omitempty"`
+		Blocks      slack.Blocks       `json:"blocks,omitzero"`
+		Attachments []slack.Attachment `json:"attachments,omitempty"`
+	}{msg.Text, msg.Blocks, msg.Attachments}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %w", err)
+	}
+	return n.writeFile(thread+".json", bs)
+}
+
+// PostThreadReply implements Notifier by appending text to <thread>.txt.
+func (n *FileNotifier) PostThreadReply(_ context.Context, thread string, text string) error {
+	return n.appendFile(thread+".txt", text)
+}
+
+// UploadImage implements Notifier. The permalink is the file:// URL of the
+// written PNG.
+func (n *FileNotifier) UploadImage(_ context.Context, _ string, name string, g *Graph) (string, error) {
+	bs, err := io.ReadAll(g.NewReader())
+	if err != nil {
+		return "", fmt.Errorf("failed to read graph: %w", err)
+	}
+	if err := n.writeFile(name, bs); err != nil {
+		return "", err
+	}
+	path := filepath.Join(n.dir, name)
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	return "file://" + filepath.ToSlash(path), nil
+}
+
+// PostMessage implements Notifier by appending text to messages.txt.
+func (n *FileNotifier) PostMessage(_ context.Context, text string) error {
+	return n.appendFile("messages.txt", text)
+}
+
+func (n *FileNotifier) writeFile(name string, bs []byte) error {
+	if err := os.MkdirAll(n.dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(n.dir, name), bs, 0o644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	return nil
+}
+
+func (n *FileNotifier) appendFile(name string, text string) error {
+	if err := os.MkdirAll(n.dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	f, err := os.OpenFile(filepath.Join(n.dir, name), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", name, err)
+	}
+	defer f.Close()
+	if _, err := fmt.Fprintln(f, text); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	return nil
+}
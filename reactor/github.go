@@ -0,0 +1,250 @@
+package reactor
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/Songmu/flextime"
+)
+
+const (
+	defaultGitHubAPIURL = "https://api.github.com"
+	// githubIssueStateID is the range key of the AnomalyGitHubIssue items.
+	githubIssueStateID = "github"
+)
+
+// GitHubConfig configures opening GitHub issues for anomalies confirmed with
+// the "正確な異常" action.
+type GitHubConfig struct {
+	Token string `json:"token,omitempty"`
+	// APIURL is the REST API base URL. Defaults to https://api.github.com .
+	APIURL string `json:"apiUrl,omitempty"`
+	// Repository is the default owner/repo issues are opened in.
+	Repository string `json:"repository,omitempty"`
+	// AccountRepositories routes anomalies of an account (the anomaly account
+	// or a root-cause linked account) to a specific owner/repo.
+	AccountRepositories map[string]string `json:"accountRepositories,omitempty"`
+	Labels              []string          `json:"labels,omitempty"`
+}
+
+// Enabled reports whether GitHub issues are configured.
+func (cfg GitHubConfig) Enabled() bool {
+	return cfg.Token != "" && (cfg.Repository != "" || len(cfg.AccountRepositories) > 0)
+}
+
+// ParseGitHubAccountRepositories parses "account=owner/repo" pairs separated
+// by commas, as given in the GITHUB_ACCOUNT_REPOSITORIES environment variable.
+func ParseGitHubAccountRepositories(str string) (map[string]string, error) {
+	m := make(map[string]string)
+	for _, pair := range strings.Split(str, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		account, repo, ok := strings.Cut(pair, "=")
+		if !ok || account == "" || !strings.Contains(repo, "/") {
+			return nil, fmt.Errorf("invalid github account repository: %q", pair)
+		}
+		m[strings.TrimSpace(account)] = strings.TrimSpace(repo)
+	}
+	return m, nil
+}
+
+// repositoryFor returns the repository issues of a are opened in.
+func (cfg GitHubConfig) repositoryFor(a *Anomaly) string {
+	if repo, ok := cfg.AccountRepositories[a.AccountID]; ok {
+		return repo
+	}
+	for _, rc := range a.RootCauses {
+		if repo, ok := cfg.AccountRepositories[rc.LinkedAccount]; ok {
+			return repo
+		}
+	}
+	return cfg.Repository
+}
+
+//go:embed default_github_issue.md.tpl
+var defaultGitHubIssueTemplate string
+
+// GitHubIssueData is the data the GitHub issue body template is executed
+// with.
+type GitHubIssueData struct {
+	TemplateData
+	GraphPermalinks []string
+	ConfirmedBy     string
+}
+
+// AnomalyGitHubIssue is the DynamoDB record of the issue opened for an
+// anomaly. It shares the table of AnomalySlackMessage with the fixed range
+// key "github".
+type AnomalyGitHubIssue struct {
+	AnomalyID   string
+	SlackTeamID string
+	Repository  string
+	IssueNumber int
+	IssueURL    string
+	TotalImpact float64
+	TTL         int64
+}
+
+type githubClient struct {
+	cfg         GitHubConfig
+	client      *http.Client
+	retryPolicy RetryPolicy
+}
+
+type githubIssue struct {
+	Number  int    `json:"number"`
+	HTMLURL string `json:"html_url"`
+}
+
+func (c *githubClient) do(ctx context.Context, method string, path string, in any, out any) error {
+	apiURL := c.cfg.APIURL
+	if apiURL == "" {
+		apiURL = defaultGitHubAPIURL
+	}
+	header := http.Header{}
+	header.Set("Authorization", "Bearer "+c.cfg.Token)
+	header.Set("X-GitHub-Api-Version", "2022-11-28")
+	policy := c.retryPolicy
+	if method == http.MethodPost {
+		policy = policy.onlyThrottled()
+	}
+	return policy.Do(ctx, func(ctx context.Context) error {
+		return doJSONRequest(ctx, c.client, method, strings.TrimSuffix(apiURL, "/")+path, header, in, out)
+	})
+}
+
+func (c *githubClient) CreateIssue(ctx context.Context, repo string, title string, body string) (*githubIssue, error) {
+	var issue githubIssue
+	in := map[string]any{"title": title, "body": body}
+	if len(c.cfg.Labels) > 0 {
+		in["labels"] = c.cfg.Labels
+	}
+	if err := c.do(ctx, http.MethodPost, "/repos/"+repo+"/issues", in, &issue); err != nil {
+		return nil, fmt.Errorf("failed to create issue: %w", err)
+	}
+	return &issue, nil
+}
+
+func (c *githubClient) CreateComment(ctx context.Context, repo string, number int, body string) error {
+	if err := c.do(ctx, http.MethodPost, fmt.Sprintf("/repos/%s/issues/%d/comments", repo, number), map[string]any{"body": body}, nil); err != nil {
+		return fmt.Errorf("failed to create issue comment: %w", err)
+	}
+	return nil
+}
+
+func (h *Handler) getAnomalyGitHubIssue(ctx context.Context, anomalyID string) (*AnomalyGitHubIssue, bool, error) {
+	var issue AnomalyGitHubIssue
+	if ok, err := h.getStateItem(ctx, anomalyID, githubIssueStateID, &issue); err != nil || !ok {
+		return nil, false, err
+	}
+	if issue.IssueNumber == 0 {
+		return nil, false, nil
+	}
+	return &issue, true, nil
+}
+
+func (h *Handler) saveAnomalyGitHubIssue(ctx context.Context, issue *AnomalyGitHubIssue) error {
+	issue.SlackTeamID = githubIssueStateID
+	issue.TTL = flextime.Now().AddDate(0, 1, 0).Unix()
+	return h.putStateItem(ctx, issue)
+}
+
+// openGitHubIssue opens an issue for a confirmed anomaly, using what was
+// posted to the notifier notifierID. When an issue was already opened, it
+// comments on it instead. The issue URL is returned, or "" when no
+// repository is routed for the anomaly.
+func (h *Handler) openGitHubIssue(ctx context.Context, anomalyID string, notifierID string, user string) (string, error) {
+	existing, ok, err := h.getAnomalyGitHubIssue(ctx, anomalyID)
+	if err != nil {
+		return "", err
+	}
+	if ok {
+		if err := h.github.CreateComment(ctx, existing.Repository, existing.IssueNumber, fmt.Sprintf("Confirmed again as an accurate anomaly by %s.", user)); err != nil {
+			return "", err
+		}
+		return existing.IssueURL, nil
+	}
+	msg, ok, err := h.getAnomalySlackMessage(ctx, anomalyID, notifierID)
+	if err != nil {
+		return "", err
+	}
+	if !ok || msg.Anomaly == nil {
+		return "", fmt.Errorf("anomaly %s is not found in the state store", anomalyID)
+	}
+	repo := h.github.cfg.repositoryFor(msg.Anomaly)
+	if repo == "" {
+		h.logger.InfoContext(ctx, "no github repository for anomaly", "anomaly_id", anomalyID)
+		return "", nil
+	}
+	data, err := h.newTemplateData(ctx, *msg.Anomaly)
+	if err != nil {
+		return "", fmt.Errorf("failed to create template data: %w", err)
+	}
+	var body bytes.Buffer
+	if err := h.githubTemplate.Execute(&body, GitHubIssueData{
+		TemplateData:    data,
+		GraphPermalinks: msg.GraphPermalinks,
+		ConfirmedBy:     user,
+	}); err != nil {
+		return "", fmt.Errorf("failed to execute github issue template: %w", err)
+	}
+	a := msg.Anomaly
+	title := fmt.Sprintf("AWS Cost Anomaly: %s in account %s (%s)", formatAmount(a.Impact.TotalImpact, h.currency), a.AccountID, a.AnomalyStartDate.Format("2006-01-02"))
+	issue, err := h.github.CreateIssue(ctx, repo, title, body.String())
+	if err != nil {
+		return "", err
+	}
+	h.logger.InfoContext(ctx, "opened github issue", "anomaly_id", anomalyID, "repository", repo, "issue_number", issue.Number)
+	if err := h.saveAnomalyGitHubIssue(ctx, &AnomalyGitHubIssue{
+		AnomalyID:   anomalyID,
+		Repository:  repo,
+		IssueNumber: issue.Number,
+		IssueURL:    issue.HTMLURL,
+		TotalImpact: a.Impact.TotalImpact,
+	}); err != nil {
+		return issue.HTMLURL, fmt.Errorf("failed to save github issue: %w", err)
+	}
+	return issue.HTMLURL, nil
+}
+
+// commentAnomalyUpdate comments the new total impact on the issue opened for
+// a, if any.
+func (h *Handler) commentAnomalyUpdate(ctx context.Context, a Anomaly) error {
+	if h.github == nil {
+		return nil
+	}
+	issue, ok, err := h.getAnomalyGitHubIssue(ctx, a.AnomalyID)
+	if err != nil || !ok {
+		return err
+	}
+	if issue.TotalImpact == a.Impact.TotalImpact {
+		return nil
+	}
+	body := fmt.Sprintf("Total impact updated: %s\n\n[Open in AWS Console](%s)",
+		formatImpactDelta(issue.TotalImpact, a.Impact.TotalImpact, h.currency), a.AnomalyDetailsLink)
+	if err := h.github.CreateComment(ctx, issue.Repository, issue.IssueNumber, body); err != nil {
+		return err
+	}
+	issue.TotalImpact = a.Impact.TotalImpact
+	return h.saveAnomalyGitHubIssue(ctx, issue)
+}
+
+// openGitHubIssueText opens the issue and returns the thread reply
+// announcing it, or describing the failure.
+func (h *Handler) openGitHubIssueText(ctx context.Context, anomalyID string, notifierID string, user string) string {
+	issueURL, err := h.openGitHubIssue(ctx, anomalyID, notifierID, user)
+	if err != nil {
+		h.logger.ErrorContext(ctx, "failed to open github issue", "anomaly_id", anomalyID, "error", err)
+		return fmt.Sprintf("[error] failed to open github issue: %s", err)
+	}
+	if issueURL == "" {
+		return ""
+	}
+	return fmt.Sprintf("GitHub issue: %s", issueURL)
+}
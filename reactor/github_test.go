@@ -0,0 +1,114 @@
+package reactor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type recordedGitHubRequest struct {
+	Path string
+	Body map[string]any
+}
+
+func newFakeGitHub(t *testing.T) (*httptest.Server, func() []recordedGitHubRequest) {
+	t.Helper()
+	var mu sync.Mutex
+	var requests []recordedGitHubRequest
+	mux := http.NewServeMux()
+	record := func(r *http.Request) {
+		require.Equal(t, "Bearer ghp_dummy", r.Header.Get("Authorization"))
+		var body map[string]any
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		mu.Lock()
+		requests = append(requests, recordedGitHubRequest{Path: r.URL.Path, Body: body})
+		mu.Unlock()
+	}
+	mux.HandleFunc("POST /repos/{owner}/{repo}/issues", func(w http.ResponseWriter, r *http.Request) {
+		record(r)
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"number":42,"html_url":"https://github.example.com/%s/%s/issues/42"}`, r.PathValue("owner"), r.PathValue("repo"))
+	})
+	mux.HandleFunc("POST /repos/{owner}/{repo}/issues/{number}/comments", func(w http.ResponseWriter, r *http.Request) {
+		record(r)
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprint(w, `{"id":1}`)
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv, func() []recordedGitHubRequest {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]recordedGitHubRequest(nil), requests...)
+	}
+}
+
+func TestHandlerOpenGitHubIssue(t *testing.T) {
+	srv, requests := newFakeGitHub(t)
+	tpl, err := parseTemplate("github_issue", defaultGitHubIssueTemplate)
+	require.NoError(t, err)
+	a := loadTestAnomaly(t, "testdata/anomaly.json")
+	notifier := &recordingNotifier{id: "T0123"}
+	h := &Handler{
+		notifiers:         []Notifier{notifier},
+		logger:            slog.Default(),
+		graphGenerator:    newTestGraphGenerator(t),
+		ddb:               newMemoryDynamoDB(),
+		dynamodbTableName: "test",
+		github: &githubClient{
+			cfg: GitHubConfig{
+				Token:               "ghp_dummy",
+				APIURL:              srv.URL,
+				Repository:          "example/finops",
+				AccountRepositories: map[string]string{"000000000000": "example/other"},
+			},
+			client: http.DefaultClient,
+		},
+		githubTemplate: tpl,
+		currency:       "JPY",
+	}
+	ctx := context.Background()
+	require.NoError(t, h.postAnomalyDetectedMessage(ctx, a))
+
+	issueURL, err := h.openGitHubIssue(ctx, a.AnomalyID, "T0123", "alice")
+	require.NoError(t, err)
+	require.Equal(t, "https://github.example.com/example/finops/issues/42", issueURL)
+
+	issueURL, err = h.openGitHubIssue(ctx, a.AnomalyID, "T0123", "bob")
+	require.NoError(t, err)
+	require.Equal(t, "https://github.example.com/example/finops/issues/42", issueURL)
+
+	a.Impact.TotalImpact += 10
+	require.NoError(t, h.postAnomalyDetectedMessage(ctx, a))
+
+	reqs := requests()
+	require.Len(t, reqs, 3)
+	require.Equal(t, "/repos/example/finops/issues", reqs[0].Path)
+	require.Equal(t, "AWS Cost Anomaly: ¥1,001 in account 123456789012 (2021-05-25)", reqs[0].Body["title"])
+	body := reqs[0].Body["body"].(string)
+	require.Contains(t, body, a.AnomalyDetailsLink)
+	require.Contains(t, body, "https://files.example.com/anomaly-12345678-abcd-ef12-3456-987654321a12-root-cause1.png")
+	require.Contains(t, body, "by alice")
+	require.Equal(t, "/repos/example/finops/issues/42/comments", reqs[1].Path)
+	require.Contains(t, reqs[1].Body["body"], "by bob")
+	require.Equal(t, "/repos/example/finops/issues/42/comments", reqs[2].Path)
+	require.Contains(t, reqs[2].Body["body"], "Total impact updated: ¥1,001 → ¥1,011")
+}
+
+func TestParseGitHubAccountRepositories(t *testing.T) {
+	repos, err := ParseGitHubAccountRepositories("123456789012=example/a, 210987654321=example/b")
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{
+		"123456789012": "example/a",
+		"210987654321": "example/b",
+	}, repos)
+	_, err = ParseGitHubAccountRepositories("123456789012")
+	require.Error(t, err)
+}
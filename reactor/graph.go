@@ -62,6 +62,12 @@ var graphColors = []color.RGBA{
 // WriteTo renders the accumulated data points to a PNG and returns an
 // io.WriterTo for the encoded image.
 func (g *CostGraph) WriteTo(title string, yLabel string) (io.WriterTo, error) {
+	return g.WriteToFormat(title, yLabel, "png")
+}
+
+// WriteToFormat is like WriteTo but encodes the image in format, one of the
+// formats supported by gonum/plot such as "png", "svg" or "pdf".
+func (g *CostGraph) WriteToFormat(title string, yLabel string, format string) (io.WriterTo, error) {
 	g.mu.Lock()
 	defer g.mu.Unlock()
 	// fill data points
@@ -165,7 +171,7 @@ func (g *CostGraph) WriteTo(title string, yLabel string) (io.WriterTo, error) {
 	}
 	p.Legend.Top = true
 	p.Legend.TextStyle.Font.Size = vg.Points(8)
-	w, err := p.WriterTo(vg.Points(800), vg.Points(400), "png")
+	w, err := p.WriterTo(vg.Points(800), vg.Points(400), format)
 	if err != nil {
 		return nil, err
 	}
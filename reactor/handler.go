@@ -2,6 +2,7 @@ package reactor
 
 import (
 	"bytes"
+	"cmp"
 	"context"
 	_ "embed"
 	"encoding/json"
@@ -12,10 +13,15 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
 	"text/template"
 	"time"
 
+	"github.com/Songmu/flextime"
 	"github.com/aws/aws-lambda-go/lambdacontext"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/aws/arn"
@@ -27,36 +33,120 @@ import (
 	ddbtypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
 	"github.com/aws/aws-sdk-go-v2/service/organizations"
 	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/aws/aws-sdk-go-v2/service/sqs"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
 	"github.com/aws/aws-sdk-go-v2/service/sts"
 	"github.com/gorilla/mux"
 	"github.com/slack-go/slack"
 	"github.com/slack-go/slack/slackevents"
+	"github.com/slack-go/slack/socketmode"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/aws/aws-sdk-go-v2/otelaws"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"golang.org/x/time/rate"
 
+	"github.com/mashiike/aws-cost-anomaly-slack-reactor/internal/costexplorerx"
 	"github.com/mashiike/canyon"
 )
 
 // Handler is the http.Handler that receives AWS Cost Anomaly SNS notifications
 // and Slack events, posts anomaly messages to Slack, and records user feedback.
 type Handler struct {
-	ce                *costexplorer.Client
-	org               *organizations.Client
-	ddb               *dynamodb.Client
-	client            *slack.Client
+	ce                CostExplorerAPIClient
+	org               DescribeAccountAPIClient
+	ddb               DynamoDBAPIClient
+	sns               SNSAPIClient
+	sqs               SQSAPIClient
+	ssm               SSMAPIClient
+	slack             *SlackNotifier
+	notifiers         []Notifier
 	logger            *slog.Logger
 	router            *mux.Router
-	channel           string
 	botUserID         string
 	botID             string
 	slackTeamID       string
+	slackAdmins       []string
+	slackOAuth        SlackOAuthConfig
+	workspacesMu      sync.RWMutex
+	workspaces        []*SlackNotifier
+	workspacesLoaded  time.Time
 	signalSecret      string
+	socketMode        *socketmode.Client
 	awsAccountID      string
 	noErrorReport     bool
-	tpl               *template.Template
 	dynamodbTableName string
+	graphGenerator    *GraphGenerator
+	retryPolicy       RetryPolicy
+	teams             *TeamsNotifier
+	teamsAuth         *botFrameworkAuthenticator
+	webhooks          *WebhookSink
+	github            *githubClient
+	githubTemplate    *template.Template
+	escalation        EscalationConfig
+	escalators        []escalator
+	reminder          ReminderConfig
+	owners            OwnerConfig
+	feedbackAuth      FeedbackAuthConfig
+	apiToken          string
+	currency          string
 }
 
 var _ http.Handler = (*Handler)(nil)
 
+// CostExplorerAPIClient is the subset of the Cost Explorer client used by the
+// Handler.
+type CostExplorerAPIClient interface {
+	costexplorerx.GetCostAndUsageAPIClient
+	costexplorerx.GetAnomaliesAPIClient
+	ProvideAnomalyFeedback(ctx context.Context, params *costexplorer.ProvideAnomalyFeedbackInput, optFns ...func(*costexplorer.Options)) (*costexplorer.ProvideAnomalyFeedbackOutput, error)
+}
+
+var _ CostExplorerAPIClient = (*costexplorer.Client)(nil)
+
+// DynamoDBAPIClient is the subset of the DynamoDB client used by the Handler
+// for its state table.
+type DynamoDBAPIClient interface {
+	DescribeTable(ctx context.Context, params *dynamodb.DescribeTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeTableOutput, error)
+	CreateTable(ctx context.Context, params *dynamodb.CreateTableInput, optFns ...func(*dynamodb.Options)) (*dynamodb.CreateTableOutput, error)
+	DescribeTimeToLive(ctx context.Context, params *dynamodb.DescribeTimeToLiveInput, optFns ...func(*dynamodb.Options)) (*dynamodb.DescribeTimeToLiveOutput, error)
+	UpdateTimeToLive(ctx context.Context, params *dynamodb.UpdateTimeToLiveInput, optFns ...func(*dynamodb.Options)) (*dynamodb.UpdateTimeToLiveOutput, error)
+	GetItem(ctx context.Context, params *dynamodb.GetItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error)
+	PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+	Scan(ctx context.Context, params *dynamodb.ScanInput, optFns ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error)
+}
+
+var _ DynamoDBAPIClient = (*dynamodb.Client)(nil)
+
+// SlackAPIClient is the subset of the Slack client used by the Handler and
+// the SlackNotifier.
+type SlackAPIClient interface {
+	AuthTestContext(ctx context.Context) (*slack.AuthTestResponse, error)
+	PostMessageContext(ctx context.Context, channelID string, options ...slack.MsgOption) (string, string, error)
+	UpdateMessageContext(ctx context.Context, channelID string, timestamp string, options ...slack.MsgOption) (string, string, string, error)
+	UploadFileContext(ctx context.Context, params slack.UploadFileParameters) (*slack.FileSummary, error)
+	GetFileInfoContext(ctx context.Context, fileID string, count int, page int) (*slack.File, []slack.Comment, *slack.Paging, error)
+	PostEphemeralContext(ctx context.Context, channelID string, userID string, options ...slack.MsgOption) (string, error)
+	GetUserGroupMembersContext(ctx context.Context, userGroup string, options ...slack.GetUserGroupMembersOption) ([]string, error)
+}
+
+var _ SlackAPIClient = (*slack.Client)(nil)
+
+// STSAPIClient is the subset of the STS client used to look up the AWS
+// account ID the Handler runs in.
+type STSAPIClient interface {
+	GetCallerIdentity(ctx context.Context, params *sts.GetCallerIdentityInput, optFns ...func(*sts.Options)) (*sts.GetCallerIdentityOutput, error)
+}
+
+var _ STSAPIClient = (*sts.Client)(nil)
+
+// SNSAPIClient is the subset of the SNS client used to confirm topic
+// subscriptions.
+type SNSAPIClient interface {
+	ConfirmSubscription(ctx context.Context, params *sns.ConfirmSubscriptionInput, optFns ...func(*sns.Options)) (*sns.ConfirmSubscriptionOutput, error)
+}
+
+var _ SNSAPIClient = (*sns.Client)(nil)
+
 //go:embed default_message.json.tpl
 var defaultTemplate string
 
@@ -72,55 +162,156 @@ func New(ctx context.Context, opts ...Option) (*Handler, error) {
 		slackChannel:      os.Getenv("SLACK_CHANNEL"),
 		logger:            slog.Default(),
 		slackSignalSecret: os.Getenv("SLACK_SIGNING_SECRET"),
-		templateStr:       defaultTemplate,
+		slackAppToken:     os.Getenv("SLACK_APP_TOKEN"),
+		slackOAuth: SlackOAuthConfig{
+			ClientID:     os.Getenv("SLACK_CLIENT_ID"),
+			ClientSecret: os.Getenv("SLACK_CLIENT_SECRET"),
+			RedirectURL:  os.Getenv("SLACK_REDIRECT_URL"),
+		},
+		apiToken:         os.Getenv("API_TOKEN"),
+		currency:         cmp.Or(os.Getenv("CURRENCY"), DefaultCurrency),
+		templateStr:      defaultTemplate,
+		graphConcurrency: DefaultGraphConcurrency,
+		ceRateLimit:      DefaultCostExplorerRateLimit,
+		retryPolicy:      DefaultRetryPolicy,
+		teams: TeamsConfig{
+			AppID:          os.Getenv("TEAMS_APP_ID"),
+			AppPassword:    os.Getenv("TEAMS_APP_PASSWORD"),
+			TenantID:       os.Getenv("TEAMS_TENANT_ID"),
+			ServiceURL:     os.Getenv("TEAMS_SERVICE_URL"),
+			ConversationID: os.Getenv("TEAMS_CONVERSATION_ID"),
+		},
+		teamsTemplateStr: defaultTeamsTemplate,
+		github: GitHubConfig{
+			Token:      os.Getenv("GITHUB_TOKEN"),
+			APIURL:     os.Getenv("GITHUB_API_URL"),
+			Repository: os.Getenv("GITHUB_REPOSITORY"),
+		},
+		githubTemplateStr: defaultGitHubIssueTemplate,
+		escalation: EscalationConfig{
+			PagerDutyRoutingKey: os.Getenv("PAGERDUTY_ROUTING_KEY"),
+			PagerDutySeverity:   os.Getenv("PAGERDUTY_SEVERITY"),
+			OpsgenieAPIKey:      os.Getenv("OPSGENIE_API_KEY"),
+			OpsgeniePriority:    os.Getenv("OPSGENIE_PRIORITY"),
+			OpsgenieAPIURL:      os.Getenv("OPSGENIE_API_URL"),
+		},
+	}
+	for env, v := range map[string]*time.Duration{
+		"REMINDER_AFTER":          &params.reminder.RemindAfter,
+		"REMINDER_ESCALATE_AFTER": &params.reminder.EscalateAfter,
+	} {
+		if str := os.Getenv(env); str != "" {
+			d, err := time.ParseDuration(str)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s: %w", env, err)
+			}
+			*v = d
+		}
+	}
+	params.reminder.EscalationUserGroup = os.Getenv("REMINDER_ESCALATION_USERGROUP")
+	params.owners.TagKey = os.Getenv("OWNER_TAG_KEY")
+	for env, v := range map[string]*map[string]string{
+		"OWNER_ACCOUNTS": &params.owners.Accounts,
+		"OWNER_SERVICES": &params.owners.Services,
+		"OWNER_TAGS":     &params.owners.Tags,
+	} {
+		if str := os.Getenv(env); str != "" {
+			m, err := ParseOwnerMap(str)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s: %w", env, err)
+			}
+			*v = m
+		}
+	}
+	if str := os.Getenv("FEEDBACK_ALLOWED_USERS"); str != "" {
+		params.feedbackAuth.Users = strings.Split(str, ",")
+	}
+	if str := os.Getenv("FEEDBACK_ALLOWED_ACCOUNTS"); str != "" {
+		accounts, err := ParseFeedbackAuthAccounts(str)
+		if err != nil {
+			return nil, fmt.Errorf("invalid FEEDBACK_ALLOWED_ACCOUNTS: %w", err)
+		}
+		params.feedbackAuth.Accounts = accounts
+	}
+	if str := os.Getenv("FEEDBACK_ALLOWED_OWNERS"); str != "" {
+		allowed, err := strconv.ParseBool(str)
+		if err != nil {
+			return nil, fmt.Errorf("invalid FEEDBACK_ALLOWED_OWNERS: %w", err)
+		}
+		params.feedbackAuth.Owners = allowed
+	}
+	for env, v := range map[string]*float64{
+		"ESCALATION_MIN_TOTAL_IMPACT":            &params.escalation.MinTotalImpact,
+		"ESCALATION_MIN_TOTAL_IMPACT_PERCENTAGE": &params.escalation.MinTotalImpactPercentage,
+	} {
+		if str := os.Getenv(env); str != "" {
+			f, err := strconv.ParseFloat(str, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s: %w", env, err)
+			}
+			*v = f
+		}
+	}
+	if str := os.Getenv("GITHUB_ACCOUNT_REPOSITORIES"); str != "" {
+		repos, err := ParseGitHubAccountRepositories(str)
+		if err != nil {
+			return nil, err
+		}
+		params.github.AccountRepositories = repos
+	}
+	params.slackOAuth.TokenParameterPrefix = os.Getenv("SLACK_TOKEN_PARAMETER_PREFIX")
+	params.slackOAuth.TokenKMSKeyID = os.Getenv("SLACK_TOKEN_KMS_KEY_ID")
+	if str := os.Getenv("SLACK_ALLOWED_TEAMS"); str != "" {
+		params.slackOAuth.AllowedTeams = strings.Split(str, ",")
+	}
+	if str := os.Getenv("SLACK_ADMIN_USERS"); str != "" {
+		params.slackAdmins = strings.Split(str, ",")
+	}
+	if str := os.Getenv("GITHUB_ISSUE_LABELS"); str != "" {
+		params.github.Labels = strings.Split(str, ",")
+	}
+	if str := os.Getenv("WEBHOOK_ENDPOINTS"); str != "" {
+		endpoints, err := ParseWebhookEndpoints(str)
+		if err != nil {
+			return nil, err
+		}
+		params.webhookEndpoints = endpoints
 	}
 	for _, opt := range opts {
 		opt(params)
 	}
-	if params.slackBotToken == "" {
-		return nil, errors.New("slack bot token is required")
+	if params.devDir != "" {
+		params.applyDevMode()
 	}
-	if params.slackChannel == "" {
-		return nil, errors.New("slack channel is required")
-	}
-	if params.slackSignalSecret == "" {
-		return nil, errors.New("slack signing secret is required")
+	if params.dryRunDir != "" {
+		params.slackBotToken = ""
+		params.slackAppToken = ""
+		params.slackOAuth = SlackOAuthConfig{}
+		params.dynamodbTableName = ""
+		params.teams = TeamsConfig{}
+		params.github = GitHubConfig{}
+		params.escalation = EscalationConfig{}
+		params.reminder = ReminderConfig{}
+		params.webhookEndpoints = nil
+		params.apiToken = ""
+	} else {
+		if params.slackBotToken == "" {
+			return nil, errors.New("slack bot token is required")
+		}
+		if params.slackChannel == "" {
+			return nil, errors.New("slack channel is required")
+		}
+		if params.slackSignalSecret == "" && params.slackAppToken == "" {
+			return nil, errors.New("slack signing secret or app token is required")
+		}
+		if params.slackAppToken != "" && !strings.HasPrefix(params.slackAppToken, "xapp-") {
+			return nil, errors.New("slack app token must start with xapp-")
+		}
 	}
 	if params.templateStr == "" {
 		return nil, errors.New("template string is required")
 	}
-	tpl, err := template.New("default").Funcs(template.FuncMap{
-		"env": func(key string, args ...string) string {
-			keys := []string{key}
-			defaultValue := ""
-			if len(args) > 1 {
-				defaultValue = args[len(args)-1]
-				keys = append(keys, args[:len(args)-1]...)
-			}
-			for _, k := range keys {
-				if v := os.Getenv(k); v != "" {
-					return v
-				}
-			}
-			return defaultValue
-		},
-		"must_env": func(key string) (string, error) {
-			if v, ok := os.LookupEnv(key); ok {
-				return v, nil
-			}
-			return "", fmt.Errorf("environment variable %s is not set", key)
-		},
-		"json_escape": func(str string) (string, error) {
-			bs, err := json.Marshal(str)
-			if err != nil {
-				return "", err
-			}
-			return string(bs[1 : len(bs)-1]), nil
-		},
-		"to_date_str": func(t time.Time) string {
-			return t.Format("2006-01-02")
-		},
-	}).Parse(params.templateStr)
+	tpl, err := parseTemplate("default", params.templateStr)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse template: %w", err)
 	}
@@ -131,7 +322,13 @@ func New(ctx context.Context, opts ...Option) (*Handler, error) {
 		}
 		params.awsCfg = &awsCfg
 	}
-	stsClient := sts.NewFromConfig(*params.awsCfg)
+	awsCfg := instrumentAWSConfig(*params.awsCfg)
+	otelaws.AppendMiddlewares(&awsCfg.APIOptions)
+	params.awsCfg = &awsCfg
+	var stsClient STSAPIClient = sts.NewFromConfig(*params.awsCfg)
+	if params.stsClient != nil {
+		stsClient = params.stsClient
+	}
 	var awsAccountID string
 	if identity, err := stsClient.GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{}); err == nil {
 		awsAccountID = *identity.Account
@@ -140,9 +337,12 @@ func New(ctx context.Context, opts ...Option) (*Handler, error) {
 	}
 
 	var botID, botUserID, teamID string
-	client := slack.New(params.slackBotToken)
+	var client SlackAPIClient = params.slackClient
+	if client == nil {
+		client = slack.New(params.slackBotToken, slack.OptionHTTPClient(newSlackHTTPClient()))
+	}
 	if params.slackBotToken != "" {
-		me, err := client.AuthTest()
+		me, err := client.AuthTestContext(ctx)
 		if err != nil {
 			return nil, err
 		}
@@ -161,22 +361,57 @@ func New(ctx context.Context, opts ...Option) (*Handler, error) {
 		params.logger.Warn("slack bot token is not set, running anonymous mode")
 	}
 	router := mux.NewRouter()
+	var ce CostExplorerAPIClient = costexplorer.NewFromConfig(*params.awsCfg)
+	if params.ceClient != nil {
+		ce = params.ceClient
+	}
+	var org DescribeAccountAPIClient = organizations.NewFromConfig(*params.awsCfg)
+	if params.orgClient != nil {
+		org = params.orgClient
+	}
+	var ddb DynamoDBAPIClient = dynamodb.NewFromConfig(*params.awsCfg)
+	if params.ddbClient != nil {
+		ddb = params.ddbClient
+	}
+	var sqsClient SQSAPIClient = sqs.NewFromConfig(*params.awsCfg)
+	if params.sqsClient != nil {
+		sqsClient = params.sqsClient
+	}
+	var ssmClient SSMAPIClient = ssm.NewFromConfig(*params.awsCfg)
+	if params.ssmClient != nil {
+		ssmClient = params.ssmClient
+	}
+	params.retryPolicy = params.retryPolicy.withLogger(params.logger.With("component", "handler"))
+	graphGenerator := NewGraphGenerator(ce, org)
+	graphGenerator.Concurrency = params.graphConcurrency
+	graphGenerator.RateLimiter = rate.NewLimiter(params.ceRateLimit, 1)
+	graphGenerator.RetryPolicy = params.retryPolicy
+	slackNotifier := NewSlackNotifier(client, teamID, params.slackChannel, tpl, params.retryPolicy)
 	h := &Handler{
-		ce:                costexplorer.NewFromConfig(*params.awsCfg),
-		org:               organizations.NewFromConfig(*params.awsCfg),
-		ddb:               dynamodb.NewFromConfig(*params.awsCfg),
+		ce:                ce,
+		org:               org,
+		ddb:               ddb,
+		sns:               params.snsClient,
+		sqs:               sqsClient,
+		ssm:               ssmClient,
 		logger:            params.logger.With("component", "handler"),
 		router:            router,
-		client:            client,
+		slack:             slackNotifier,
+		notifiers:         []Notifier{slackNotifier},
 		botID:             botID,
-		channel:           params.slackChannel,
 		botUserID:         botUserID,
 		slackTeamID:       teamID,
+		slackAdmins:       params.slackAdmins,
 		signalSecret:      params.slackSignalSecret,
 		awsAccountID:      awsAccountID,
 		noErrorReport:     params.noErrorReport,
 		dynamodbTableName: params.dynamodbTableName,
-		tpl:               tpl,
+		graphGenerator:    graphGenerator,
+		retryPolicy:       params.retryPolicy,
+		owners:            params.owners,
+		feedbackAuth:      params.feedbackAuth,
+		apiToken:          params.apiToken,
+		currency:          params.currency,
 	}
 	if h.EnableDynamoDB() {
 		params.logger.Info("dynamodb enabled", "table_name", h.dynamodbTableName)
@@ -184,10 +419,87 @@ func New(ctx context.Context, opts ...Option) (*Handler, error) {
 			return nil, fmt.Errorf("failed to prepare dynamodb table: %w", err)
 		}
 	}
-	var dummy templateData
-	if _, err := h.newDetectAnomalyMessageOptions(dummy); err != nil {
+	var dummy TemplateData
+	if _, err := slackNotifier.newDetectAnomalyMessageOptions(dummy); err != nil {
 		return nil, fmt.Errorf("failed to create default message: %w", err)
 	}
+	if params.devDir != "" {
+		params.logger.Info("development mode enabled", "dir", params.devDir, "page", filepath.Join(params.devDir, "index.html"))
+	}
+	if params.dryRunDir != "" {
+		h.notifiers = []Notifier{NewFileNotifier(params.dryRunDir, tpl)}
+		params.logger.Info("dry run enabled", "dir", params.dryRunDir)
+	}
+	if params.slackAppToken != "" {
+		h.socketMode = socketmode.New(slack.New(params.slackBotToken,
+			slack.OptionAppLevelToken(params.slackAppToken),
+			slack.OptionHTTPClient(newSlackHTTPClient()),
+		))
+		params.logger.Info("slack socket mode enabled")
+	}
+	if params.slackOAuth.Enabled() {
+		if err := params.slackOAuth.validate(); err != nil {
+			return nil, err
+		}
+		if !h.EnableDynamoDB() {
+			return nil, errors.New("slack oauth requires the dynamodb table")
+		}
+		h.slackOAuth = params.slackOAuth
+		if err := h.loadSlackWorkspaces(ctx); err != nil {
+			return nil, fmt.Errorf("failed to load slack workspaces: %w", err)
+		}
+		params.logger.Info("slack oauth enabled", "redirect_url", params.slackOAuth.RedirectURL, "workspaces", len(h.workspaces))
+	}
+	if params.teams.Enabled() {
+		if err := params.teams.validate(); err != nil {
+			return nil, err
+		}
+		teamsTpl, err := parseTemplate("teams", params.teamsTemplateStr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse teams template: %w", err)
+		}
+		h.teams = NewTeamsNotifier(params.teams, teamsTpl, params.retryPolicy)
+		if _, err := h.teams.renderCard(dummy); err != nil {
+			return nil, fmt.Errorf("failed to create default teams card: %w", err)
+		}
+		h.teamsAuth = newBotFrameworkAuthenticator(params.teams)
+		h.notifiers = append(h.notifiers, h.teams)
+		params.logger.Info("teams enabled", "conversation_id", params.teams.ConversationID)
+	}
+	if params.github.Enabled() {
+		if !h.EnableDynamoDB() {
+			return nil, errors.New("github issues require the dynamodb table")
+		}
+		githubTpl, err := parseTemplate("github_issue", params.githubTemplateStr)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse github issue template: %w", err)
+		}
+		if err := githubTpl.Execute(io.Discard, GitHubIssueData{TemplateData: dummy}); err != nil {
+			return nil, fmt.Errorf("failed to execute github issue template: %w", err)
+		}
+		h.github = &githubClient{cfg: params.github, client: http.DefaultClient, retryPolicy: params.retryPolicy}
+		h.githubTemplate = githubTpl
+		params.logger.Info("github issues enabled", "repository", params.github.Repository)
+	}
+	if params.reminder.Enabled() {
+		if !h.EnableDynamoDB() {
+			return nil, errors.New("reminders require the dynamodb table")
+		}
+		h.reminder = params.reminder
+		params.logger.Info("reminder enabled", "remind_after", params.reminder.RemindAfter, "escalate_after", params.reminder.EscalateAfter)
+	}
+	if params.escalation.Enabled() {
+		h.escalation = params.escalation
+		h.escalators = newEscalators(params.escalation, params.currency, params.retryPolicy)
+		params.logger.Info("escalation enabled",
+			"min_total_impact", params.escalation.MinTotalImpact,
+			"min_total_impact_percentage", params.escalation.MinTotalImpactPercentage,
+		)
+	}
+	if len(params.webhookEndpoints) > 0 {
+		h.webhooks = NewWebhookSink(params.webhookEndpoints, params.retryPolicy, h.logger.With("component", "webhook"))
+		params.logger.Info("webhook enabled", "endpoints", len(params.webhookEndpoints))
+	}
 	router.MethodNotAllowedHandler = http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
 		w.WriteHeader(http.StatusMethodNotAllowed)
 	})
@@ -199,6 +511,17 @@ func New(ctx context.Context, opts ...Option) (*Handler, error) {
 	})
 	router.HandleFunc("/amazon-sns", h.handleAmazonSNS).Methods(http.MethodPost)
 	router.HandleFunc("/slack/events", h.handleSlackEvents).Methods(http.MethodPost)
+	if h.slackOAuth.Enabled() {
+		router.HandleFunc("/slack/install", h.handleSlackInstall).Methods(http.MethodGet)
+		router.HandleFunc("/slack/oauth", h.handleSlackOAuth).Methods(http.MethodGet)
+	}
+	if params.metricsEndpoint {
+		router.Handle("/metrics", MetricsHandler()).Methods(http.MethodGet)
+	}
+	h.registerAPIRoutes(router)
+	if h.teams != nil {
+		router.HandleFunc("/teams/messages", h.handleTeamsMessages).Methods(http.MethodPost)
+	}
 	router.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		if strings.Contains(r.UserAgent(), "Slackbot") {
 			h.handleSlackEvents(w, r)
@@ -213,6 +536,45 @@ func New(ctx context.Context, opts ...Option) (*Handler, error) {
 	return h, nil
 }
 
+func templateEnv(key string, args ...string) string {
+	keys := []string{key}
+	defaultValue := ""
+	if len(args) > 1 {
+		defaultValue = args[len(args)-1]
+		keys = append(keys, args[:len(args)-1]...)
+	}
+	for _, k := range keys {
+		if v := os.Getenv(k); v != "" {
+			return v
+		}
+	}
+	return defaultValue
+}
+
+func templateMustEnv(key string) (string, error) {
+	if v, ok := os.LookupEnv(key); ok {
+		return v, nil
+	}
+	return "", fmt.Errorf("environment variable %s is not set", key)
+}
+
+func parseTemplate(name string, text string) (*template.Template, error) {
+	return template.New(name).Funcs(template.FuncMap{
+		"env":      templateEnv,
+		"must_env": templateMustEnv,
+		"json_escape": func(str string) (string, error) {
+			bs, err := json.Marshal(str)
+			if err != nil {
+				return "", err
+			}
+			return string(bs[1 : len(bs)-1]), nil
+		},
+		"to_date_str": func(t time.Time) string {
+			return t.Format("2006-01-02")
+		},
+	}).Parse(text)
+}
+
 // EnableDynamoDB reports whether the Handler has a DynamoDB table configured
 // for persisting Slack message state.
 func (h *Handler) EnableDynamoDB() bool {
@@ -322,16 +684,38 @@ type AnomalySlackMessage struct {
 	SlackTeamID           string
 	SlackMessageTimestamp string
 	TotalImpact           float64
-	TTL                   int64
+	// Anomaly and GraphPermalinks keep what was posted, so that later
+	// actions (e.g. opening a GitHub issue) can refer to it.
+	Anomaly         *Anomaly `dynamodbav:",omitempty"`
+	GraphPermalinks []string `dynamodbav:",omitempty"`
+	// PostedAt is when the anomaly was first posted. FeedbackStatus is the
+	// Cost Anomaly Detection feedback type once feedback was provided.
+	// RemindedAt and EscalatedAt record the reminders sent while no feedback
+	// was provided. All are Unix times.
+	PostedAt       int64  `dynamodbav:",omitempty"`
+	FeedbackStatus string `dynamodbav:",omitempty"`
+	FeedbackAt     int64  `dynamodbav:",omitempty"`
+	RemindedAt     int64  `dynamodbav:",omitempty"`
+	EscalatedAt    int64  `dynamodbav:",omitempty"`
+	TTL            int64
 }
 
-// SaveAnomalySlackMessage stores the AnomalySlackMessage in DynamoDB, setting
-// SlackTeamID from the Handler and a 1-month TTL.
+// SaveAnomalySlackMessage stores the AnomalySlackMessage in DynamoDB with a
+// 1-month TTL. SlackTeamID defaults to the team of the Handler's Slack bot;
+// other notifiers set it to their Notifier ID.
 func (h *Handler) SaveAnomalySlackMessage(ctx context.Context, m *AnomalySlackMessage) error {
-	m.SlackTeamID = h.slackTeamID
+	if m.SlackTeamID == "" {
+		m.SlackTeamID = h.slackTeamID
+	}
 	m.TTL = time.Now().AddDate(0, 1, 0).Unix()
 	h.logger.DebugContext(ctx, "save anomaly slack message", "anomaly_id", m.AnomalyID, "slack_team_id", m.SlackTeamID)
-	item, err := attributevalue.MarshalMap(m)
+	return h.putStateItem(ctx, m)
+}
+
+// putStateItem stores v, which must have AnomalyID and SlackTeamID
+// attributes, in the DynamoDB table.
+func (h *Handler) putStateItem(ctx context.Context, v any) error {
+	item, err := attributevalue.MarshalMap(v)
 	if err != nil {
 		return fmt.Errorf("failed to marshal item: %w", err)
 	}
@@ -339,33 +723,51 @@ func (h *Handler) SaveAnomalySlackMessage(ctx context.Context, m *AnomalySlackMe
 		TableName: aws.String(h.dynamodbTableName),
 		Item:      item,
 	})
+	stateStoreOperations.WithLabelValues("put", resultLabel(err)).Inc()
 	if err != nil {
 		return fmt.Errorf("failed to put item: %w", err)
 	}
 	return nil
 }
 
-// GetAnomalySlackMessage looks up a previously saved AnomalySlackMessage by
-// anomaly ID. The boolean return is false when no record is found.
-func (h *Handler) GetAnomalySlackMessage(ctx context.Context, anomalyID string) (*AnomalySlackMessage, bool, error) {
-	h.logger.DebugContext(ctx, "get anomaly slack message", "anomaly_id", anomalyID, "slack_team_id", h.slackTeamID)
+// getStateItem loads the item keyed by anomalyID and rangeKey into v. The
+// boolean return is false when no item is found.
+func (h *Handler) getStateItem(ctx context.Context, anomalyID string, rangeKey string, v any) (bool, error) {
 	output, err := h.ddb.GetItem(ctx, &dynamodb.GetItemInput{
 		TableName: aws.String(h.dynamodbTableName),
 		Key: map[string]ddbtypes.AttributeValue{
 			"AnomalyID":   &ddbtypes.AttributeValueMemberS{Value: anomalyID},
-			"SlackTeamID": &ddbtypes.AttributeValueMemberS{Value: h.slackTeamID},
+			"SlackTeamID": &ddbtypes.AttributeValueMemberS{Value: rangeKey},
 		},
 	})
+	stateStoreOperations.WithLabelValues("get", resultLabel(err)).Inc()
 	if err != nil {
 		var notFound *ddbtypes.ResourceNotFoundException
 		if errors.As(err, &notFound) {
-			return nil, false, nil
+			return false, nil
 		}
-		return nil, false, fmt.Errorf("failed to get item: %w", err)
+		return false, fmt.Errorf("failed to get item: %w", err)
+	}
+	if len(output.Item) == 0 {
+		return false, nil
 	}
+	if err := attributevalue.UnmarshalMap(output.Item, v); err != nil {
+		return false, fmt.Errorf("failed to unmarshal item: %w", err)
+	}
+	return true, nil
+}
+
+// GetAnomalySlackMessage looks up a previously saved AnomalySlackMessage by
+// anomaly ID. The boolean return is false when no record is found.
+func (h *Handler) GetAnomalySlackMessage(ctx context.Context, anomalyID string) (*AnomalySlackMessage, bool, error) {
+	return h.getAnomalySlackMessage(ctx, anomalyID, h.slackTeamID)
+}
+
+func (h *Handler) getAnomalySlackMessage(ctx context.Context, anomalyID string, notifierID string) (*AnomalySlackMessage, bool, error) {
+	h.logger.DebugContext(ctx, "get anomaly slack message", "anomaly_id", anomalyID, "slack_team_id", notifierID)
 	var m AnomalySlackMessage
-	if err := attributevalue.UnmarshalMap(output.Item, &m); err != nil {
-		return nil, false, fmt.Errorf("failed to unmarshal item: %w", err)
+	if ok, err := h.getStateItem(ctx, anomalyID, notifierID, &m); err != nil || !ok {
+		return nil, false, err
 	}
 	if m.AnomalyID == "" || m.SlackTeamID == "" {
 		return nil, false, nil
@@ -375,7 +777,7 @@ func (h *Handler) GetAnomalySlackMessage(ctx context.Context, anomalyID string)
 
 func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	h.logger.Info("serve http", "method", r.Method, "path", r.URL.Path, "remote_addr", r.RemoteAddr, "user_agent", r.UserAgent(), "referer", r.Referer())
-	h.router.ServeHTTP(w, r)
+	h.router.ServeHTTP(w, r.WithContext(extractTraceContext(r)))
 }
 
 // https://docs.aws.amazon.com/sns/latest/dg/json-formats.html
@@ -401,7 +803,9 @@ const (
 	actionsPlanedActivityID = "planed_activity"
 )
 
-type templateData struct {
+// TemplateData is the data a message template is executed with. It is passed
+// to Notifier implementations, which render it for their chat platform.
+type TemplateData struct {
 	Anomaly                    Anomaly
 	MonitorID                  string
 	ActionsBlockID             string
@@ -411,16 +815,21 @@ type templateData struct {
 	ActionsNoID                string
 	ActionsPlanedActivityValue string
 	ActionsPlanedActivityID    string
+	// Owners are the Slack mentions of the owners resolved by OwnerConfig.
+	Owners []string
+	// Status is the lifecycle status of the anomaly. It is empty when the
+	// DynamoDB table is not configured.
+	Status AnomalyStatus
 }
 
-func (h *Handler) newTemplateData(_ context.Context, anomaly Anomaly) (templateData, error) {
+func (h *Handler) newTemplateData(ctx context.Context, anomaly Anomaly) (TemplateData, error) {
 	var monitorID string
 	arnObj, err := arn.Parse(anomaly.MonitorArn)
 	if err != nil {
-		return templateData{}, fmt.Errorf("failed to parse monitor arn: %w", err)
+		return TemplateData{}, fmt.Errorf("failed to parse monitor arn: %w", err)
 	}
 	monitorID = strings.TrimPrefix(arnObj.Resource, "anomalymonitor/")
-	data := templateData{
+	data := TemplateData{
 		Anomaly:        anomaly,
 		MonitorID:      monitorID,
 		ActionsBlockID: actionsBlockID,
@@ -439,36 +848,15 @@ func (h *Handler) newTemplateData(_ context.Context, anomaly Anomaly) (templateD
 			"action":     []string{string(types.AnomalyFeedbackTypePlannedActivity)},
 		}.Encode(),
 		ActionsPlanedActivityID: actionsPlanedActivityID,
+		Owners:                  h.resolveOwners(ctx, anomaly),
 	}
 	return data, nil
 }
 
-func (h *Handler) newDetectAnomalyMessageOptions(data templateData) ([]slack.MsgOption, error) {
-	var buf bytes.Buffer
-	if err := h.tpl.Execute(&buf, data); err != nil {
-		return nil, fmt.Errorf("failed to execute template: %w", err)
-	}
-	var msg slack.Msg
-	dec := json.NewDecoder(&buf)
-	dec.DisallowUnknownFields()
-	if err := dec.Decode(&msg); err != nil {
-		return nil, fmt.Errorf("failed to decode template: %w", err)
-	}
-	opts := make([]slack.MsgOption, 0, 3)
-	if msg.Text != "" {
-		opts = append(opts, slack.MsgOptionText(msg.Text, false))
-	}
-	if len(msg.Attachments) > 0 {
-		opts = append(opts, slack.MsgOptionAttachments(msg.Attachments...))
-	}
-	if len(msg.Blocks.BlockSet) > 0 {
-		opts = append(opts, slack.MsgOptionBlocks(msg.Blocks.BlockSet...))
-	}
-	return opts, nil
-}
-
 func (h *Handler) handleAmazonSNS(w http.ResponseWriter, r *http.Request) {
 	h.logger.Debug("start handle amazon sns")
+	ctx, span := startSpan(r.Context(), "handleAmazonSNS")
+	defer span.End()
 	bs, err := io.ReadAll(r.Body)
 	if err != nil {
 		h.logger.Error("failed to read body", "error", err)
@@ -483,13 +871,14 @@ func (h *Handler) handleAmazonSNS(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
-	ctx := r.Context()
 	h.logger.Info("handle amazon sns http notification", "type", n.Type, "topic_arn", n.TopicArn, "subject", n.Subject)
 	if n.Type == "" && n.MessageId == "" && n.TopicArn == "" {
 		h.logger.Warn("maybe this is raw notification, fallbac as notification type")
 		n.Message = string(bs)
 		n.Type = "Notification"
 	}
+	notificationsReceived.WithLabelValues(n.Type).Inc()
+	span.SetAttributes(attribute.String("sns.type", n.Type), attribute.String("sns.topic_arn", n.TopicArn))
 	switch n.Type {
 	case "SubscriptionConfirmation":
 		h.logger.Info("subscription confirmation", "subscribe_url", n.SubscribeURL)
@@ -499,7 +888,10 @@ func (h *Handler) handleAmazonSNS(w http.ResponseWriter, r *http.Request) {
 			w.WriteHeader(http.StatusInternalServerError)
 			return
 		}
-		client := sns.New(sns.Options{Region: arnObj.Region})
+		client := h.sns
+		if client == nil {
+			client = sns.New(sns.Options{Region: arnObj.Region})
+		}
 		_, err = client.ConfirmSubscription(ctx, &sns.ConfirmSubscriptionInput{
 			Token:                     aws.String(n.Token),
 			TopicArn:                  aws.String(n.TopicArn),
@@ -511,10 +903,7 @@ func (h *Handler) handleAmazonSNS(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		h.logger.Info("confirmed subscription", "topic_arn", n.TopicArn)
-		_, _, err = h.client.PostMessage(h.channel, slack.MsgOptionText(fmt.Sprintf("confirmed sns subscription for %s", n.TopicArn), false))
-		if err != nil {
-			h.logger.Error("failed to post message", "error", err)
-		}
+		h.postMessageToAll(ctx, fmt.Sprintf("confirmed sns subscription for %s", n.TopicArn))
 		w.WriteHeader(http.StatusOK)
 		return
 	case "Notification":
@@ -522,18 +911,15 @@ func (h *Handler) handleAmazonSNS(w http.ResponseWriter, r *http.Request) {
 		var a Anomaly
 		if err := json.Unmarshal([]byte(n.Message), &a); err != nil {
 			h.logger.Error("failed to unmarshal message", "error", err)
+			anomaliesSuppressed.WithLabelValues("invalid_payload").Inc()
+			span.SetStatus(codes.Error, "invalid payload")
 			w.WriteHeader(http.StatusBadRequest)
 			return
 		}
-		if err := h.postAnomalyDetectedMessage(ctx, a); err != nil {
-			h.logger.Error("failed to post anomaly detected message", "error", err)
-			var reported *reportedError
-			if !h.noErrorReport && !errors.As(err, &reported) {
-				_, _, err := h.client.PostMessage(h.channel, slack.MsgOptionText(fmt.Sprintf("[error] failed to post anomaly detected message: %s", err), false))
-				if err != nil {
-					h.logger.Error("failed to post message", "error", err)
-				}
-			}
+		span.SetAttributes(attribute.String("anomaly.id", a.AnomalyID), attribute.String("anomaly.account_id", a.AccountID))
+		if err := h.processAnomaly(ctx, a); err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
 			w.WriteHeader(http.StatusInternalServerError)
 			return
 		}
@@ -541,8 +927,27 @@ func (h *Handler) handleAmazonSNS(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// processAnomaly posts the anomaly detected message, reporting the failure to
+// the notifiers unless it has been reported already.
+func (h *Handler) processAnomaly(ctx context.Context, a Anomaly) error {
+	if err := h.postAnomalyDetectedMessage(ctx, a); err != nil {
+		h.logger.ErrorContext(ctx, "failed to post anomaly detected message", "anomaly_id", a.AnomalyID, "error", err)
+		var reported *reportedError
+		if !h.noErrorReport && !errors.As(err, &reported) {
+			h.postMessageToAll(ctx, fmt.Sprintf("[error] failed to post anomaly detected message: %s", err))
+		}
+		return err
+	}
+	return nil
+}
+
 func (h *Handler) handleSlackEvents(w http.ResponseWriter, r *http.Request) {
 	h.logger.Debug("start handle slack events")
+	if h.signalSecret == "" {
+		// Socket Mode, events are not accepted over HTTP
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
 	verifier, err := slack.NewSecretsVerifier(r.Header, h.signalSecret)
 	if err != nil {
 		w.WriteHeader(http.StatusUnauthorized)
@@ -577,6 +982,8 @@ func (h *Handler) handleSlackEvents(w http.ResponseWriter, r *http.Request) {
 
 func (h *Handler) processInteractiveMessage(w http.ResponseWriter, r *http.Request) {
 	h.logger.Debug("process interactive message")
+	ctx, span := startSpan(r.Context(), "processInteractiveMessage", attribute.Bool("canyon.worker", canyon.IsWorker(r)))
+	defer span.End()
 	var payload slack.InteractionCallback
 	err := json.Unmarshal([]byte(r.FormValue("payload")), &payload)
 	if err != nil {
@@ -584,6 +991,9 @@ func (h *Handler) processInteractiveMessage(w http.ResponseWriter, r *http.Reque
 	}
 
 	if canyon.Used(r) && !canyon.IsWorker(r) {
+		// the headers are sent along with the request, so that the worker
+		// continues this trace
+		injectTraceContext(ctx, r.Header)
 		msgID, err := canyon.SendToWorker(r, nil)
 		if err != nil {
 			h.logger.Error("failed to send to worker", "error", err)
@@ -595,22 +1005,28 @@ func (h *Handler) processInteractiveMessage(w http.ResponseWriter, r *http.Reque
 		return
 	}
 	isWorker := canyon.Used(r) && canyon.IsWorker(r)
+	w.WriteHeader(h.handleInteraction(ctx, payload, isWorker))
+}
+
+// handleInteraction provides the feedback of a block action and returns the
+// HTTP status of the result. Errors are posted to the thread of the message
+// when reportErrors is set, that is when Slack has already been answered.
+func (h *Handler) handleInteraction(ctx context.Context, payload slack.InteractionCallback, reportErrors bool) int {
+	workspace := h.slackNotifierFor(ctx, payload.Team.ID)
 	postToThread := func(ctx context.Context, options ...slack.MsgOption) error {
 		msgTs := payload.Message.Timestamp
 		msgChannel := payload.Channel.ID
 		options = append(options, slack.MsgOptionTS(msgTs))
-		_, _, err := h.client.PostMessageContext(ctx, msgChannel, options...)
+		_, err := workspace.postMessage(ctx, msgChannel, options...)
 		if err != nil {
 			return fmt.Errorf("failed to post message: %w", err)
 		}
 		return nil
 	}
-	ctx := r.Context()
 	var action *slack.BlockAction
 	if len(payload.ActionCallback.BlockActions) == 0 {
 		h.logger.Warn("no action found")
-		w.WriteHeader(http.StatusOK)
-		return
+		return http.StatusOK
 	}
 	actionUser := payload.User
 	for _, a := range payload.ActionCallback.BlockActions {
@@ -621,34 +1037,59 @@ func (h *Handler) processInteractiveMessage(w http.ResponseWriter, r *http.Reque
 	}
 	if action == nil {
 		h.logger.Warn("no action found")
-		w.WriteHeader(http.StatusOK)
-		return
+		return http.StatusOK
 	}
 
 	v, err := url.ParseQuery(action.Value)
 	if err != nil {
 		h.logger.Warn("failed to parse action value", "error", err)
-		if isWorker {
+		if reportErrors {
 			if postErr := postToThread(ctx, slack.MsgOptionText(fmt.Sprintf("[error] failed to parse action value: %s", err), false)); postErr != nil {
 				h.logger.WarnContext(ctx, "failed to post to thread", "error", postErr)
 			}
 		}
-		w.WriteHeader(http.StatusBadRequest)
-		return
+		return http.StatusBadRequest
 	}
 	anomalyID := v.Get("anomaly_id")
+	allowed, err := h.authorizeFeedback(ctx, workspace, anomalyID, actionUser.ID)
+	if err != nil {
+		h.logger.ErrorContext(ctx, "failed to authorize feedback", "anomaly_id", anomalyID, "user_id", actionUser.ID, "error", err)
+	}
+	h.logger.InfoContext(ctx, "authorize feedback", "anomaly_id", anomalyID, "action_id", action.ActionID, "user_id", actionUser.ID, "user_name", actionUser.Name, "allowed", allowed)
+	if !allowed {
+		if err := h.trackDeniedFeedback(ctx, anomalyID, action.ActionID, actionUser.Name, actionUser.ID, "slack"); err != nil {
+			h.logger.WarnContext(ctx, "failed to track denied feedback", "anomaly_id", anomalyID, "error", err)
+		}
+		if err := workspace.postEphemeral(ctx, payload.Channel.ID, actionUser.ID,
+			slack.MsgOptionText(fmt.Sprintf("You are not allowed to provide feedback for AnomalyID `%s` .", anomalyID), false),
+		); err != nil {
+			h.logger.WarnContext(ctx, "failed to post ephemeral message", "error", err)
+		}
+		return http.StatusOK
+	}
 	h.logger.Info("provide feedback action", "anomaly_id", anomalyID, "action_id", action.ActionID, "user_id", actionUser.ID)
 	if err := h.ProvideFeedback(ctx, anomalyID, action.ActionID); err != nil {
 		h.logger.Error("failed to provide feedback", "error", err)
-		if isWorker {
+		if reportErrors {
 			if postErr := postToThread(ctx,
 				slack.MsgOptionText(fmt.Sprintf("[error] failed to provide feedback: %s", err), false),
 			); postErr != nil {
 				h.logger.WarnContext(ctx, "failed to post to thread", "error", postErr)
 			}
 		}
-		w.WriteHeader(http.StatusInternalServerError)
-		return
+		return http.StatusInternalServerError
+	}
+	h.sendFeedbackWebhook(ctx, anomalyID, action.ActionID, actionUser.Name, "slack")
+	if err := h.trackFeedback(ctx, anomalyID, action.ActionID, actionUser.Name, actionUser.ID, "slack"); err != nil {
+		h.logger.WarnContext(ctx, "failed to track feedback", "anomaly_id", anomalyID, "error", err)
+	}
+	if h.github != nil && action.ActionID == actionsYesID {
+		text := h.openGitHubIssueText(ctx, anomalyID, workspace.ID(), actionUser.Name)
+		if text != "" {
+			if postErr := postToThread(ctx, slack.MsgOptionText(text, false)); postErr != nil {
+				h.logger.WarnContext(ctx, "failed to post to thread", "error", postErr)
+			}
+		}
 	}
 	if postErr := postToThread(ctx,
 		slack.MsgOptionText(fmt.Sprintf("Feedback of `%s` was provided for AnomalyID `%s` by user `%s` .", action.Text.Text, anomalyID, actionUser.Name), false),
@@ -656,7 +1097,7 @@ func (h *Handler) processInteractiveMessage(w http.ResponseWriter, r *http.Reque
 	); postErr != nil {
 		h.logger.WarnContext(ctx, "failed to post to thread", "error", postErr)
 	}
-	w.WriteHeader(http.StatusOK)
+	return http.StatusOK
 }
 
 func (h *Handler) processEventsAPIEvent(w http.ResponseWriter, r *http.Request) {
@@ -689,37 +1130,80 @@ func (h *Handler) processEventsAPIEvent(w http.ResponseWriter, r *http.Request)
 		h.logger.Info("url verification success")
 		return
 	case slackevents.CallbackEvent:
-		innerEvent := eventsAPIEvent.InnerEvent
-		switch ev := innerEvent.Data.(type) {
-		case *slackevents.AppMentionEvent:
-			h.logger.Info("app mention event", "text", ev.Text)
-			var builder strings.Builder
-			if !strings.Contains(ev.Text, "where") {
-				fmt.Fprintf(&builder, "I'm AWS Cost Anomaly Detection Reactor, If you need to running infomation, please mention me with `where`")
-			} else {
-				fmt.Fprintf(&builder, "AWS Cost Anomaly Detection Reactor running infomation\n")
-				if h.awsAccountID != "" {
-					fmt.Fprintf(&builder, "- aws_account_id: %s\n", h.awsAccountID)
-					fmt.Fprintf(&builder, "- region: %s\n", os.Getenv("AWS_REGION"))
-				}
-				if lambdacontext.FunctionName != "" {
-					fmt.Fprintf(&builder, "- lambda_function_name: %s\n", lambdacontext.FunctionName)
-					fmt.Fprintf(&builder, "- lambda_function_version: %s\n", lambdacontext.FunctionVersion)
-				}
-				if hostname, err := os.Hostname(); err == nil {
-					fmt.Fprintf(&builder, "- hostname: %s\n", hostname)
-				}
-			}
-			h.logger.Info("post message", "text", builder.String())
-			_, _, err := h.client.PostMessage(ev.Channel, slack.MsgOptionText(builder.String(), false))
+		if retry := r.Header.Get("X-Slack-Retry-Num"); retry != "" {
+			// the first delivery is still handled, e.g. a slow monitor
+			// command, and must not run twice
+			h.logger.InfoContext(r.Context(), "ignore retried slack event", "retry_num", retry, "retry_reason", r.Header.Get("X-Slack-Retry-Reason"))
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		if canyon.Used(r) && !canyon.IsWorker(r) {
+			// answer Slack within 3 seconds, the worker calls the AWS APIs
+			r.Body = io.NopCloser(bytes.NewReader(bs))
+			injectTraceContext(r.Context(), r.Header)
+			msgID, err := canyon.SendToWorker(r, nil)
 			if err != nil {
-				h.logger.Error("failed to post message", "error", err)
+				h.logger.Error("failed to send to worker", "error", err)
+				w.WriteHeader(http.StatusInternalServerError)
+				return
 			}
+			h.logger.Info("send events api event request to worker", "msg_id", msgID)
+			w.WriteHeader(http.StatusOK)
+			return
 		}
+		h.handleCallbackEvent(r.Context(), eventsAPIEvent)
 	}
 	w.WriteHeader(http.StatusOK)
 }
 
+// handleCallbackEvent handles an event of the Events API, which is received
+// over HTTP or Socket Mode.
+func (h *Handler) handleCallbackEvent(ctx context.Context, eventsAPIEvent slackevents.EventsAPIEvent) {
+	innerEvent := eventsAPIEvent.InnerEvent
+	switch ev := innerEvent.Data.(type) {
+	case *slackevents.AppMentionEvent:
+		h.logger.Info("app mention event", "text", ev.Text)
+		var builder strings.Builder
+		if args := mentionArgs(ev.Text); len(args) > 0 && slices.Contains(monitorCommands, args[0]) {
+			builder.WriteString(h.monitorCommand(ctx, ev.User, args))
+		} else if !strings.Contains(ev.Text, "where") {
+			fmt.Fprintf(&builder, "I'm AWS Cost Anomaly Detection Reactor, If you need to running infomation, please mention me with `where`")
+		} else {
+			fmt.Fprintf(&builder, "AWS Cost Anomaly Detection Reactor running infomation\n")
+			if h.awsAccountID != "" {
+				fmt.Fprintf(&builder, "- aws_account_id: %s\n", h.awsAccountID)
+				fmt.Fprintf(&builder, "- region: %s\n", os.Getenv("AWS_REGION"))
+			}
+			if lambdacontext.FunctionName != "" {
+				fmt.Fprintf(&builder, "- lambda_function_name: %s\n", lambdacontext.FunctionName)
+				fmt.Fprintf(&builder, "- lambda_function_version: %s\n", lambdacontext.FunctionVersion)
+			}
+			if hostname, err := os.Hostname(); err == nil {
+				fmt.Fprintf(&builder, "- hostname: %s\n", hostname)
+			}
+		}
+		h.logger.Info("post message", "text", builder.String())
+		workspace := h.slackNotifierFor(ctx, eventsAPIEvent.TeamID)
+		_, err := workspace.postMessage(ctx, ev.Channel, slack.MsgOptionText(builder.String(), false))
+		if err != nil {
+			h.logger.Error("failed to post message", "error", err)
+		}
+	}
+}
+
+// mentionArgs splits the text of an app mention into words, dropping the
+// user mentions.
+func mentionArgs(text string) []string {
+	var args []string
+	for _, f := range strings.Fields(text) {
+		if strings.HasPrefix(f, "<@") && strings.HasSuffix(f, ">") {
+			continue
+		}
+		args = append(args, f)
+	}
+	return args
+}
+
 type reportedError struct {
 	Parent error
 }
@@ -732,114 +1216,257 @@ func (r *reportedError) Unwrap() error {
 	return r.Parent
 }
 
+func (h *Handler) postMessageToAll(ctx context.Context, text string) {
+	for _, n := range h.allNotifiers(ctx) {
+		if err := n.PostMessage(ctx, text); err != nil {
+			h.logger.ErrorContext(ctx, "failed to post message", "notifier_id", n.ID(), "error", err)
+		}
+	}
+}
+
 func (h *Handler) postAnomalyDetectedMessage(ctx context.Context, a Anomaly) error {
+	return h.postAnomaly(ctx, a, false)
+}
+
+// postAnomalyOnly posts the anomaly like postAnomalyDetectedMessage but
+// without the side effects of a newly detected anomaly: no escalation,
+// webhook or GitHub comment, and no reminders for the message. It is used to
+// post anomalies from the past.
+func (h *Handler) postAnomalyOnly(ctx context.Context, a Anomaly) error {
+	return h.postAnomaly(ctx, a, true)
+}
+
+func (h *Handler) postAnomaly(ctx context.Context, a Anomaly, postOnly bool) error {
 	data, err := h.newTemplateData(ctx, a)
 	if err != nil {
 		return fmt.Errorf("failed to create template data: %w", err)
 	}
-	opts, err := h.newDetectAnomalyMessageOptions(data)
+	lc, err := h.trackAnomaly(ctx, a)
 	if err != nil {
-		return fmt.Errorf("failed to create message: %w", err)
+		h.logger.WarnContext(ctx, "failed to track anomaly lifecycle", "anomaly_id", a.AnomalyID, "error", err)
+	}
+	if lc != nil {
+		data.Status = lc.Status
+	}
+	notifiers := h.allNotifiers(ctx)
+	var errs []error
+	var updated bool
+	threads := make([]string, len(notifiers))
+	updatedThreads := make([]string, len(notifiers))
+	for i, n := range notifiers {
+		ts, u, err := h.postAnomalyMessage(ctx, n, a, data, postOnly)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("notifier %s: %w", n.ID(), err))
+			continue
+		}
+		threads[i] = ts
+		kind := "new"
+		if u {
+			updatedThreads[i] = ts
+			kind = "update"
+		}
+		anomaliesPosted.WithLabelValues(n.ID(), kind).Inc()
+		updated = updated || u
+	}
+	if !postOnly {
+		if err := h.escalateAnomaly(ctx, a, lc); err != nil {
+			h.logger.ErrorContext(ctx, "failed to escalate anomaly", "anomaly_id", a.AnomalyID, "error", err)
+		}
+		if updated {
+			if err := h.commentAnomalyUpdate(ctx, a); err != nil {
+				h.logger.WarnContext(ctx, "failed to comment on github issue", "anomaly_id", a.AnomalyID, "error", err)
+			}
+		}
 	}
+	if len(errs) == len(notifiers) {
+		anomaliesSuppressed.WithLabelValues("notifier_error").Inc()
+		return errors.Join(errs...)
+	}
+	if !postOnly {
+		// sent once a message is posted, since the notification is retried
+		// when every notifier fails
+		h.sendAnomalyWebhook(ctx, a, data, updated)
+	}
+	if updated && lc != nil {
+		if err := h.postImpactHistory(ctx, notifiers, a, lc.Revisions, updatedThreads); err != nil {
+			h.logger.WarnContext(ctx, "failed to post impact history", "anomaly_id", a.AnomalyID, "error", err)
+		}
+	}
+	graphs, graphErr := h.graphGenerator.Generate(ctx, a)
+	reported := true
+	for i, n := range notifiers {
+		if threads[i] == "" {
+			reported = false
+			continue
+		}
+		if err := h.postAnomalyGraphs(ctx, n, threads[i], a, graphs, graphErr); err != nil {
+			var r *reportedError
+			if !errors.As(err, &r) {
+				reported = false
+			}
+			errs = append(errs, fmt.Errorf("notifier %s: %w", n.ID(), err))
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	err = errors.Join(errs...)
+	if reported {
+		return &reportedError{Parent: err}
+	}
+	return err
+}
+
+// postAnomalyMessage posts the anomaly to n, or updates the existing message
+// when one is recorded. It reports whether an existing message was updated.
+// A new message is not reminded when postOnly is set.
+func (h *Handler) postAnomalyMessage(ctx context.Context, n Notifier, a Anomaly, data TemplateData, postOnly bool) (string, bool, error) {
 	var posted bool
 	var ts string
+	var err error
 	if h.EnableDynamoDB() {
-		msg, ok, err := h.GetAnomalySlackMessage(ctx, a.AnomalyID)
+		msg, ok, err := h.getAnomalySlackMessage(ctx, a.AnomalyID, n.ID())
 		if err != nil {
 			h.logger.WarnContext(ctx, "failed to get anomaly slack message", "error", err)
 		}
 		if ok {
 			posted = true
 			ts = msg.SlackMessageTimestamp
-			updateText := fmt.Sprintf("Update Total Impact `%f` to `%f`", msg.TotalImpact, a.Impact.TotalImpact)
-			_, _, err = h.client.PostMessageContext(
-				ctx, h.channel,
-				slack.MsgOptionTS(ts),
-				slack.MsgOptionText(updateText, false),
-			)
-			if err != nil {
-				return fmt.Errorf("failed to post message: %w", err)
+			updateText := fmt.Sprintf("Total Impact updated: %s", formatImpactDelta(msg.TotalImpact, a.Impact.TotalImpact, h.currency))
+			if err := n.PostThreadReply(ctx, ts, updateText); err != nil {
+				return "", false, fmt.Errorf("failed to post message: %w", err)
 			}
-			_, _, _, err = h.client.UpdateMessageContext(ctx, h.channel, ts, opts...)
-			if err != nil {
-				return fmt.Errorf("failed to update message: %w", err)
+			if err := n.UpdateAnomaly(ctx, ts, data); err != nil {
+				return "", false, fmt.Errorf("failed to update message: %w", err)
 			}
 		}
 	}
 	if !posted {
-		_, ts, err = h.client.PostMessageContext(ctx, h.channel, opts...)
+		ts, err = n.PostAnomaly(ctx, data)
 		if err != nil {
-			return fmt.Errorf("failed to post message: %w", err)
+			return "", false, fmt.Errorf("failed to post message: %w", err)
 		}
 	}
-	if h.EnableDynamoDB() {
-		if err := h.SaveAnomalySlackMessage(ctx, &AnomalySlackMessage{
-			AnomalyID:             a.AnomalyID,
-			SlackMessageTimestamp: ts,
-			TotalImpact:           a.Impact.TotalImpact,
-		}); err != nil {
-			h.logger.WarnContext(ctx, "failed to save anomaly slack message", "error", err, "anomaly_id", a.AnomalyID)
-		}
+	postedAt := flextime.Now()
+	if postOnly {
+		postedAt = time.Time{}
 	}
-	h.logger.Info("post anomaly detected message", "anomaly_id", a.AnomalyID, "thread_ts", ts)
-	g := NewGraphGenerator(h.ce, h.org)
-	graphs, err := g.Generate(ctx, a)
-	if err != nil {
-		_, _, msgErr := h.client.PostMessage(
-			h.channel,
-			slack.MsgOptionTS(ts),
-			slack.MsgOptionText(fmt.Sprintf("[error] %s", err), false))
-		if msgErr != nil {
-			h.logger.Error("failed to post error message", "error", err)
-			return err
+	h.saveAnomalyMessage(ctx, n, a, ts, nil, postedAt)
+	h.logger.Info("post anomaly detected message", "anomaly_id", a.AnomalyID, "notifier_id", n.ID(), "thread_ts", ts)
+	return ts, posted, nil
+}
+
+// saveAnomalyMessage records the message of the anomaly posted to n.
+// postedAt starts the reminders of a new message, and is zero for a message
+// that is not reminded. An existing message keeps the time it was first
+// posted, and saving the graph permalinks never changes it.
+func (h *Handler) saveAnomalyMessage(ctx context.Context, n Notifier, a Anomaly, ts string, graphPermalinks []string, postedAt time.Time) {
+	if !h.EnableDynamoDB() {
+		return
+	}
+	m := &AnomalySlackMessage{
+		AnomalyID:             a.AnomalyID,
+		SlackTeamID:           n.ID(),
+		SlackMessageTimestamp: ts,
+		TotalImpact:           a.Impact.TotalImpact,
+		Anomaly:               &a,
+		GraphPermalinks:       graphPermalinks,
+	}
+	if !postedAt.IsZero() {
+		m.PostedAt = postedAt.Unix()
+	}
+	// keep the lifecycle of an already posted anomaly
+	if prev, ok, err := h.getAnomalySlackMessage(ctx, a.AnomalyID, n.ID()); err != nil {
+		h.logger.WarnContext(ctx, "failed to get anomaly slack message", "error", err, "anomaly_id", a.AnomalyID)
+	} else if ok && prev.SlackMessageTimestamp == ts {
+		if graphPermalinks == nil {
+			m.GraphPermalinks = prev.GraphPermalinks
 		}
-		return &reportedError{Parent: err}
+		if prev.PostedAt != 0 || graphPermalinks != nil {
+			m.PostedAt = prev.PostedAt
+		}
+		m.FeedbackStatus = prev.FeedbackStatus
+		m.FeedbackAt = prev.FeedbackAt
+		m.RemindedAt = prev.RemindedAt
+		m.EscalatedAt = prev.EscalatedAt
 	}
-	for i, g := range graphs {
-		name := fmt.Sprintf("anomaly-%s-root-cause%d.png", a.AnomalyID, i+1)
-		file, err := h.client.UploadFileContext(ctx, slack.UploadFileParameters{
-			Reader:          g.r,
-			Filename:        name,
-			FileSize:        int(g.size),
-			Channel:         h.channel,
-			ThreadTimestamp: ts,
-		})
+	if err := h.SaveAnomalySlackMessage(ctx, m); err != nil {
+		h.logger.WarnContext(ctx, "failed to save anomaly slack message", "error", err, "anomaly_id", a.AnomalyID)
+	}
+}
+
+func (h *Handler) postAnomalyGraphs(ctx context.Context, n Notifier, ts string, a Anomaly, graphs []*Graph, graphErr error) error {
+	var permalinks []string
+	for _, g := range graphs {
+		name := fmt.Sprintf("anomaly-%s-root-cause%d.png", a.AnomalyID, g.Index()+1)
+		permalink, err := n.UploadImage(ctx, ts, name, g)
 		if err != nil {
-			_, _, msgErr := h.client.PostMessage(
-				h.channel,
-				slack.MsgOptionTS(ts),
-				slack.MsgOptionText(fmt.Sprintf("[error] %s", err), false))
-			if msgErr != nil {
+			if msgErr := n.PostThreadReply(ctx, ts, fmt.Sprintf("[error] %s", err)); msgErr != nil {
 				h.logger.Error("failed to upload graph error message", "error", err)
 				return fmt.Errorf("failed to upload file: %w", err)
 			}
 			return &reportedError{Parent: err}
 		}
-		h.logger.Info("upload file", "file_id", file.ID, "file_name", name)
+		h.logger.Info("upload file", "notifier_id", n.ID(), "file_name", name)
+		if permalink != "" {
+			permalinks = append(permalinks, permalink)
+		}
+	}
+	if len(permalinks) > 0 {
+		h.saveAnomalyMessage(ctx, n, a, ts, permalinks, time.Time{})
+	}
+	if graphErr != nil {
+		msg := fmt.Sprintf("[error] failed to generate %d of %d root cause graphs:\n%s", len(a.RootCauses)-len(graphs), len(a.RootCauses), graphErr)
+		if msgErr := n.PostThreadReply(ctx, ts, msg); msgErr != nil {
+			h.logger.Error("failed to post error message", "error", msgErr)
+			return graphErr
+		}
+		return &reportedError{Parent: graphErr}
 	}
 	return nil
 }
 
-// ProvideFeedback forwards the Slack action ID as Cost Anomaly Detection
-// feedback (Yes / No / PlannedActivity) for the given anomaly.
-func (h *Handler) ProvideFeedback(ctx context.Context, annomalyID string, actionID string) error {
-	var feedbackType types.AnomalyFeedbackType
+// actionFeedbackType returns the Cost Anomaly Detection feedback of a Slack
+// action ID.
+func actionFeedbackType(actionID string) (types.AnomalyFeedbackType, error) {
 	switch actionID {
 	case actionsYesID:
-		feedbackType = types.AnomalyFeedbackTypeYes
+		return types.AnomalyFeedbackTypeYes, nil
 	case actionsNoID:
-		feedbackType = types.AnomalyFeedbackTypeNo
+		return types.AnomalyFeedbackTypeNo, nil
 	case actionsPlanedActivityID:
-		feedbackType = types.AnomalyFeedbackTypePlannedActivity
-	default:
-		return fmt.Errorf("invalid action id: %s", actionID)
+		return types.AnomalyFeedbackTypePlannedActivity, nil
+	}
+	return "", fmt.Errorf("invalid action id: %s", actionID)
+}
+
+// ProvideFeedback forwards the Slack action ID as Cost Anomaly Detection
+// feedback (Yes / No / PlannedActivity) for the given anomaly. No and
+// PlannedActivity also resolve the escalated incidents of the anomaly.
+func (h *Handler) ProvideFeedback(ctx context.Context, annomalyID string, actionID string) error {
+	feedbackType, err := actionFeedbackType(actionID)
+	if err != nil {
+		return err
 	}
-	_, err := h.ce.ProvideAnomalyFeedback(ctx, &costexplorer.ProvideAnomalyFeedbackInput{
-		AnomalyId: aws.String(annomalyID),
-		Feedback:  feedbackType,
+	err = h.retryPolicy.Do(ctx, func(ctx context.Context) error {
+		_, err := h.ce.ProvideAnomalyFeedback(ctx, &costexplorer.ProvideAnomalyFeedbackInput{
+			AnomalyId: aws.String(annomalyID),
+			Feedback:  feedbackType,
+		}, withoutSDKRetry)
+		return err
 	})
+	feedbackProvided.WithLabelValues(string(feedbackType), resultLabel(err)).Inc()
 	if err != nil {
 		return err
 	}
+	if err := h.recordFeedback(ctx, annomalyID, feedbackType); err != nil {
+		h.logger.WarnContext(ctx, "failed to record feedback", "anomaly_id", annomalyID, "error", err)
+	}
+	if feedbackType != types.AnomalyFeedbackTypeYes {
+		reason := fmt.Sprintf("Resolved by %s feedback.", feedbackType)
+		if err := h.resolveEscalation(ctx, annomalyID, reason); err != nil {
+			h.logger.ErrorContext(ctx, "failed to resolve escalation", "anomaly_id", annomalyID, "error", err)
+		}
+	}
 	return nil
 }
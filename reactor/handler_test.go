@@ -0,0 +1,467 @@
+package reactor
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer/types"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	ssmtypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/slack-go/slack"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type slackCall struct {
+	Method  string
+	Channel string
+	User    string
+	Thread  string
+	Text    string
+	Name    string
+}
+
+// fakeSlackClient records the Slack API calls of the Handler.
+type fakeSlackClient struct {
+	mu     sync.Mutex
+	calls  []slackCall
+	seq    int
+	groups map[string][]string
+}
+
+var _ SlackAPIClient = (*fakeSlackClient)(nil)
+
+func (c *fakeSlackClient) Calls() []slackCall {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]slackCall(nil), c.calls...)
+}
+
+func (c *fakeSlackClient) record(method string, channel string, options []slack.MsgOption) (string, error) {
+	_, values, err := slack.UnsafeApplyMsgOptions("", channel, "", options...)
+	if err != nil {
+		return "", err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.seq++
+	c.calls = append(c.calls, slackCall{
+		Method:  method,
+		Channel: channel,
+		Thread:  values.Get("thread_ts"),
+		Text:    values.Get("text"),
+	})
+	return fmt.Sprintf("1700000000.%06d", c.seq), nil
+}
+
+func (c *fakeSlackClient) AuthTestContext(context.Context) (*slack.AuthTestResponse, error) {
+	return &slack.AuthTestResponse{TeamID: "T0123", UserID: "U0123", BotID: "B0123"}, nil
+}
+
+func (c *fakeSlackClient) PostMessageContext(_ context.Context, channel string, options ...slack.MsgOption) (string, string, error) {
+	ts, err := c.record("chat.postMessage", channel, options)
+	return channel, ts, err
+}
+
+func (c *fakeSlackClient) UpdateMessageContext(_ context.Context, channel string, timestamp string, options ...slack.MsgOption) (string, string, string, error) {
+	_, err := c.record("chat.update", channel, append(options, slack.MsgOptionTS(timestamp)))
+	return channel, timestamp, "", err
+}
+
+func (c *fakeSlackClient) UploadFileContext(_ context.Context, params slack.UploadFileParameters) (*slack.FileSummary, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls = append(c.calls, slackCall{Method: "files.upload", Channel: params.Channel, Thread: params.ThreadTimestamp, Name: params.Filename})
+	return &slack.FileSummary{ID: "F0123", Title: params.Filename}, nil
+}
+
+func (c *fakeSlackClient) GetFileInfoContext(_ context.Context, fileID string, _ int, _ int) (*slack.File, []slack.Comment, *slack.Paging, error) {
+	return &slack.File{ID: fileID, Permalink: "https://example.slack.com/files/" + fileID}, nil, nil, nil
+}
+
+func (c *fakeSlackClient) PostEphemeralContext(_ context.Context, channel string, user string, options ...slack.MsgOption) (string, error) {
+	_, values, err := slack.UnsafeApplyMsgOptions("", channel, "", options...)
+	if err != nil {
+		return "", err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls = append(c.calls, slackCall{Method: "chat.postEphemeral", Channel: channel, User: user, Text: values.Get("text")})
+	return "", nil
+}
+
+func (c *fakeSlackClient) GetUserGroupMembersContext(_ context.Context, userGroup string, _ ...slack.GetUserGroupMembersOption) ([]string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	members, ok := c.groups[userGroup]
+	if !ok {
+		return nil, errors.New("no_such_subteam")
+	}
+	return members, nil
+}
+
+type mockSTSClient struct {
+	mock.Mock
+}
+
+func (m *mockSTSClient) GetCallerIdentity(ctx context.Context, params *sts.GetCallerIdentityInput, _ ...func(*sts.Options)) (*sts.GetCallerIdentityOutput, error) {
+	args := m.Called(ctx, params)
+	output, _ := args.Get(0).(*sts.GetCallerIdentityOutput)
+	return output, args.Error(1)
+}
+
+type mockSNSClient struct {
+	mock.Mock
+}
+
+func (m *mockSNSClient) ConfirmSubscription(ctx context.Context, params *sns.ConfirmSubscriptionInput, _ ...func(*sns.Options)) (*sns.ConfirmSubscriptionOutput, error) {
+	args := m.Called(ctx, params)
+	output, _ := args.Get(0).(*sns.ConfirmSubscriptionOutput)
+	return output, args.Error(1)
+}
+
+// fakeSSMClient keeps the SSM parameters in memory.
+type fakeSSMClient struct {
+	mu         sync.Mutex
+	parameters map[string]ssm.PutParameterInput
+}
+
+func (c *fakeSSMClient) PutParameter(_ context.Context, params *ssm.PutParameterInput, _ ...func(*ssm.Options)) (*ssm.PutParameterOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.parameters == nil {
+		c.parameters = make(map[string]ssm.PutParameterInput)
+	}
+	c.parameters[aws.ToString(params.Name)] = *params
+	return &ssm.PutParameterOutput{Version: 1}, nil
+}
+
+func (c *fakeSSMClient) GetParameter(_ context.Context, params *ssm.GetParameterInput, _ ...func(*ssm.Options)) (*ssm.GetParameterOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	p, ok := c.parameters[aws.ToString(params.Name)]
+	if !ok {
+		return nil, &ssmtypes.ParameterNotFound{}
+	}
+	return &ssm.GetParameterOutput{Parameter: &ssmtypes.Parameter{Name: p.Name, Type: p.Type, Value: p.Value}}, nil
+}
+
+const testSigningSecret = "signing-secret"
+
+type handlerTestSuite struct {
+	t      *testing.T
+	server *httptest.Server
+	slack  *fakeSlackClient
+	ce     *mockGetCostAndUsageAPIClient
+	sns    *mockSNSClient
+	ssm    *fakeSSMClient
+	h      *Handler
+}
+
+func newHandlerTestSuite(t *testing.T, opts ...Option) *handlerTestSuite {
+	t.Helper()
+	s := &handlerTestSuite{
+		t:     t,
+		slack: &fakeSlackClient{},
+		ce:    &mockGetCostAndUsageAPIClient{t: t},
+		sns:   &mockSNSClient{},
+		ssm:   &fakeSSMClient{},
+	}
+	s.ce.On("GetCostAndUsage", mock.Anything, mock.Anything).Return(&costexplorer.GetCostAndUsageOutput{
+		ResultsByTime: []types.ResultByTime{
+			{
+				TimePeriod: &types.DateInterval{
+					Start: aws.String("2021-05-20"),
+					End:   aws.String("2021-05-21"),
+				},
+				Total: map[string]types.MetricValue{
+					"NetUnblendedCost": {
+						Amount: aws.String("1.75"),
+						Unit:   aws.String("USD"),
+					},
+				},
+			},
+		},
+	}, nil)
+	stsClient := &mockSTSClient{}
+	stsClient.On("GetCallerIdentity", mock.Anything, mock.Anything).Return(&sts.GetCallerIdentityOutput{
+		Account: aws.String("123456789012"),
+	}, nil)
+	h, err := New(context.Background(), append([]Option{
+		WithAWSConfig(&aws.Config{Region: "us-east-1"}),
+		WithLogger(slog.Default()),
+		WithSlackBotToken("xoxb-test"),
+		WithSlackChannel("C0123"),
+		WithSlackSignalSecret(testSigningSecret),
+		WithDynamoDBTableName("test"),
+		WithDynamoDBClient(newMemoryDynamoDB()),
+		WithCostExplorerClient(s.ce),
+		WithOrganizationsClient(&mockDescribeAccountAPIClient{t: t}),
+		WithSTSClient(stsClient),
+		WithSNSClient(s.sns),
+		WithSSMClient(s.ssm),
+		WithSlackClient(s.slack),
+	}, opts...)...)
+	require.NoError(t, err)
+	require.Equal(t, "123456789012", h.awsAccountID)
+	require.Equal(t, "T0123", h.slackTeamID)
+	s.h = h
+	s.server = httptest.NewServer(h)
+	t.Cleanup(s.server.Close)
+	return s
+}
+
+// postAppMention posts an app mention of user in channel C0456.
+func (s *handlerTestSuite) postAppMention(user string, text string) *http.Response {
+	s.t.Helper()
+	return s.postSlack("application/json", s.appMention(user, text), testSigningSecret)
+}
+
+// appMention returns the event of an app mention of user in channel C0456.
+func (s *handlerTestSuite) appMention(user string, text string) string {
+	s.t.Helper()
+	bs, err := json.Marshal(map[string]any{
+		"type":    "event_callback",
+		"team_id": "T0123",
+		"event": map[string]any{
+			"type":    "app_mention",
+			"user":    user,
+			"text":    text,
+			"channel": "C0456",
+			"ts":      "1700000000.000100",
+		},
+	})
+	require.NoError(s.t, err)
+	return string(bs)
+}
+
+// postFeedback clicks the Yes button of the anomaly message as user.
+func (s *handlerTestSuite) postFeedback(userID string, userName string, anomalyID string) *http.Response {
+	s.t.Helper()
+	return s.postTeamFeedback("T0123", "1700000000.000001", userID, userName, anomalyID)
+}
+
+// postTeamFeedback clicks the Yes button of the anomaly message ts as user
+// of the Slack team.
+func (s *handlerTestSuite) postTeamFeedback(teamID string, ts string, userID string, userName string, anomalyID string) *http.Response {
+	s.t.Helper()
+	payload, err := json.Marshal(map[string]any{
+		"type":    "block_actions",
+		"team":    map[string]any{"id": teamID},
+		"user":    map[string]any{"id": userID, "name": userName},
+		"channel": map[string]any{"id": "C0123"},
+		"message": map[string]any{"ts": ts},
+		"actions": []map[string]any{{
+			"block_id":  actionsBlockID,
+			"action_id": actionsYesID,
+			"value":     url.Values{"anomaly_id": {anomalyID}}.Encode(),
+			"text":      map[string]any{"type": "plain_text", "text": "Yes"},
+		}},
+	})
+	require.NoError(s.t, err)
+	body := url.Values{"payload": {string(payload)}}.Encode()
+	return s.postSlack("application/x-www-form-urlencoded", body, testSigningSecret)
+}
+
+func (s *handlerTestSuite) post(path string, contentType string, body string, header http.Header) *http.Response {
+	s.t.Helper()
+	req, err := http.NewRequest(http.MethodPost, s.server.URL+path, strings.NewReader(body))
+	require.NoError(s.t, err)
+	req.Header.Set("Content-Type", contentType)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	resp, err := s.server.Client().Do(req)
+	require.NoError(s.t, err)
+	s.t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func (s *handlerTestSuite) postSNS(v any) *http.Response {
+	s.t.Helper()
+	bs, err := json.Marshal(v)
+	require.NoError(s.t, err)
+	return s.post("/amazon-sns", "text/plain; charset=UTF-8", string(bs), nil)
+}
+
+// postSlack posts body to /slack/events signed with secret.
+func (s *handlerTestSuite) postSlack(contentType string, body string, secret string) *http.Response {
+	s.t.Helper()
+	return s.postSlackWithHeader(contentType, body, secret, http.Header{})
+}
+
+// postSlackWithHeader posts a signed request with the additional header.
+func (s *handlerTestSuite) postSlackWithHeader(contentType string, body string, secret string, header http.Header) *http.Response {
+	s.t.Helper()
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "v0:%s:%s", ts, body)
+	header.Set("X-Slack-Request-Timestamp", ts)
+	header.Set("X-Slack-Signature", "v0="+hex.EncodeToString(mac.Sum(nil)))
+	return s.post("/slack/events", contentType, body, header)
+}
+
+func (s *handlerTestSuite) notification(a Anomaly) httpNotification {
+	s.t.Helper()
+	bs, err := json.Marshal(a)
+	require.NoError(s.t, err)
+	return httpNotification{
+		Type:      "Notification",
+		MessageId: "message-1",
+		TopicArn:  "arn:aws:sns:us-east-1:123456789012:cost-anomaly",
+		Message:   string(bs),
+	}
+}
+
+func TestHandlerSNSSubscriptionConfirmation(t *testing.T) {
+	s := newHandlerTestSuite(t)
+	s.sns.On("ConfirmSubscription", mock.Anything, &sns.ConfirmSubscriptionInput{
+		Token:                     aws.String("token"),
+		TopicArn:                  aws.String("arn:aws:sns:us-east-1:123456789012:cost-anomaly"),
+		AuthenticateOnUnsubscribe: aws.String("no"),
+	}).Return(&sns.ConfirmSubscriptionOutput{}, nil).Once()
+
+	resp := s.postSNS(httpNotification{
+		Type:         "SubscriptionConfirmation",
+		MessageId:    "message-1",
+		Token:        "token",
+		TopicArn:     "arn:aws:sns:us-east-1:123456789012:cost-anomaly",
+		SubscribeURL: "https://sns.us-east-1.amazonaws.com/?Action=ConfirmSubscription",
+	})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	s.sns.AssertExpectations(t)
+	require.Equal(t, []slackCall{{
+		Method:  "chat.postMessage",
+		Channel: "C0123",
+		Text:    "confirmed sns subscription for arn:aws:sns:us-east-1:123456789012:cost-anomaly",
+	}}, s.slack.Calls())
+}
+
+func TestHandlerSNSNotification(t *testing.T) {
+	s := newHandlerTestSuite(t)
+	a := loadTestAnomaly(t, "testdata/anomaly.json")
+
+	resp := s.postSNS(s.notification(a))
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	calls := s.slack.Calls()
+	require.Len(t, calls, 2)
+	require.Equal(t, "chat.postMessage", calls[0].Method)
+	require.Equal(t, "C0123", calls[0].Channel)
+	require.Equal(t, slackCall{
+		Method:  "files.upload",
+		Channel: "C0123",
+		Thread:  "1700000000.000001",
+		Name:    "anomaly-12345678-abcd-ef12-3456-987654321a12-root-cause1.png",
+	}, calls[1])
+
+	msg, ok, err := s.h.GetAnomalySlackMessage(context.Background(), a.AnomalyID)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, "1700000000.000001", msg.SlackMessageTimestamp)
+	require.Equal(t, []string{"https://example.slack.com/files/F0123"}, msg.GraphPermalinks)
+
+	resp = s.post("/amazon-sns", "text/plain", `{"Type": "Notification", "Message": "not json"}`, nil)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestHandlerSNSNotificationUpdate(t *testing.T) {
+	s := newHandlerTestSuite(t)
+	a := loadTestAnomaly(t, "testdata/anomaly.json")
+	require.Equal(t, http.StatusOK, s.postSNS(s.notification(a)).StatusCode)
+
+	a.Impact.TotalImpact = 1200
+	require.Equal(t, http.StatusOK, s.postSNS(s.notification(a)).StatusCode)
+	var methods []string
+	for _, c := range s.slack.Calls()[2:] {
+		methods = append(methods, c.Method)
+		if c.Method != "files.upload" {
+			require.Equal(t, "1700000000.000001", c.Thread)
+		}
+	}
+	require.Equal(t, []string{"chat.postMessage", "chat.update", "files.upload", "files.upload"}, methods)
+	require.True(t, strings.HasPrefix(s.slack.Calls()[2].Text, "Total Impact updated: "))
+
+	msg, ok, err := s.h.GetAnomalySlackMessage(context.Background(), a.AnomalyID)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, 1200.0, msg.TotalImpact)
+}
+
+func TestHandlerSlackVerification(t *testing.T) {
+	s := newHandlerTestSuite(t)
+	body := `{"type": "url_verification", "token": "token", "challenge": "challenge-value"}`
+
+	resp := s.postSlack("application/json", body, "wrong-secret")
+	require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	resp = s.post("/slack/events", "application/json", body, nil)
+	require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	resp = s.postSlack("application/json", body, testSigningSecret)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	bs, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, "challenge-value", string(bs))
+	require.Empty(t, s.slack.Calls())
+}
+
+func TestHandlerSlackInteractiveFeedback(t *testing.T) {
+	s := newHandlerTestSuite(t)
+	a := loadTestAnomaly(t, "testdata/anomaly.json")
+	require.Equal(t, http.StatusOK, s.postSNS(s.notification(a)).StatusCode)
+	s.ce.On("ProvideAnomalyFeedback", mock.Anything, &costexplorer.ProvideAnomalyFeedbackInput{
+		AnomalyId: aws.String(a.AnomalyID),
+		Feedback:  types.AnomalyFeedbackTypeYes,
+	}).Return(&costexplorer.ProvideAnomalyFeedbackOutput{AnomalyId: aws.String(a.AnomalyID)}, nil).Once()
+
+	resp := s.postFeedback("U0456", "alice", a.AnomalyID)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	s.ce.AssertExpectations(t)
+
+	calls := s.slack.Calls()
+	last := calls[len(calls)-1]
+	require.Equal(t, "chat.postMessage", last.Method)
+	require.Equal(t, "1700000000.000001", last.Thread)
+	require.Equal(t, "Feedback of `Yes` was provided for AnomalyID `12345678-abcd-ef12-3456-987654321a12` by user `alice` .", last.Text)
+	lc, ok, err := s.h.GetAnomalyLifecycle(context.Background(), a.AnomalyID)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, AnomalyStatusAcknowledged, lc.Status)
+}
+
+func TestHandlerSlackAppMention(t *testing.T) {
+	s := newHandlerTestSuite(t)
+	resp := s.postAppMention("U0456", "<@U0123> hello")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	resp = s.postAppMention("U0456", "<@U0123> where")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	calls := s.slack.Calls()
+	require.Len(t, calls, 2)
+	require.Equal(t, "C0456", calls[0].Channel)
+	require.True(t, strings.HasPrefix(calls[0].Text, "I'm AWS Cost Anomaly Detection Reactor"))
+	require.Equal(t, "C0456", calls[1].Channel)
+	require.Contains(t, calls[1].Text, "- aws_account_id: 123456789012\n")
+	if hostname, err := os.Hostname(); err == nil {
+		require.Contains(t, calls[1].Text, "- hostname: "+hostname+"\n")
+	}
+}
@@ -0,0 +1,103 @@
+package reactor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Songmu/flextime"
+)
+
+// httpStatusError is returned by the plain HTTP API clients of the reactor
+// (Teams, webhooks, ...) for non-2xx responses. RetryPolicy retries it on
+// 429 and 5xx, honouring the Retry-After header.
+type httpStatusError struct {
+	Method     string
+	URL        string
+	StatusCode int
+	RetryAfter time.Duration
+	Body       string
+}
+
+func (e *httpStatusError) Error() string {
+	return fmt.Sprintf("%s %s: unexpected status %d: %s", e.Method, e.URL, e.StatusCode, e.Body)
+}
+
+func (e *httpStatusError) retryable() bool {
+	return e.throttled() || e.StatusCode >= 500
+}
+
+func (e *httpStatusError) throttled() bool {
+	return e.StatusCode == http.StatusTooManyRequests
+}
+
+// doJSONRequest sends in (if not nil) as a JSON body with the given extra
+// headers and decodes a JSON response into out (if not nil).
+func doJSONRequest(ctx context.Context, client *http.Client, method string, url string, header http.Header, in any, out any) error {
+	var body io.Reader
+	if in != nil {
+		bs, err := json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("failed to marshal request: %w", err)
+		}
+		body = bytes.NewReader(bs)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	for k, vs := range header {
+		for _, v := range vs {
+			req.Header.Add(k, v)
+		}
+	}
+	if in != nil && req.Header.Get("Content-Type") == "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	bs, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return &httpStatusError{
+			Method:     method,
+			URL:        url,
+			StatusCode: resp.StatusCode,
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+			Body:       string(bs),
+		}
+	}
+	if out == nil || len(bytes.TrimSpace(bs)) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(bs, out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+func parseRetryAfter(v string) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if sec, err := strconv.Atoi(v); err == nil {
+		return time.Duration(sec) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := t.Sub(flextime.Now()); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
@@ -0,0 +1,158 @@
+package reactor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/vg"
+)
+
+// DefaultCurrency is the currency impacts are formatted in. Cost Anomaly
+// Detection reports impacts in USD.
+const DefaultCurrency = "USD"
+
+var currencySymbols = map[string]string{
+	"USD": "$",
+	"EUR": "€",
+	"GBP": "£",
+	"JPY": "¥",
+	"CNY": "¥",
+	"INR": "₹",
+	"KRW": "₩",
+}
+
+// zero-decimal currencies
+var currencyDecimals = map[string]int{
+	"JPY": 0,
+	"KRW": 0,
+}
+
+// formatAmount formats amount in currency with thousands separators, e.g.
+// "$1,234.50" or "¥1,235". Unknown currencies are suffixed with their code.
+func formatAmount(amount float64, currency string) string {
+	if currency == "" {
+		currency = DefaultCurrency
+	}
+	decimals, ok := currencyDecimals[currency]
+	if !ok {
+		decimals = 2
+	}
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+	str := strconv.FormatFloat(amount, 'f', decimals, 64)
+	intPart, fracPart, _ := strings.Cut(str, ".")
+	var b strings.Builder
+	for i, r := range intPart {
+		if i > 0 && (len(intPart)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(r)
+	}
+	if fracPart != "" {
+		b.WriteString("." + fracPart)
+	}
+	if symbol, ok := currencySymbols[currency]; ok {
+		return sign + symbol + b.String()
+	}
+	return sign + b.String() + " " + currency
+}
+
+// formatImpactDelta describes the change of the total impact from prev to
+// cur, e.g. "$1,001.00 → $1,200.00 (+$199.00, +19.88%)".
+func formatImpactDelta(prev float64, cur float64, currency string) string {
+	delta := cur - prev
+	sign := "+"
+	if delta < 0 {
+		sign = "-"
+	}
+	str := fmt.Sprintf("%s → %s (%s%s", formatAmount(prev, currency), formatAmount(cur, currency), sign, formatAmount(math.Abs(delta), currency))
+	if prev != 0 {
+		str += fmt.Sprintf(", %s%.2f%%", sign, math.Abs(delta/prev*100))
+	}
+	return str + ")"
+}
+
+// renderImpactHistory renders the total impact of each revision as a line
+// chart.
+func renderImpactHistory(title string, revisions []ImpactRevision, currency string) (*Graph, error) {
+	if len(revisions) == 0 {
+		return nil, errors.New("no impact revisions")
+	}
+	if currency == "" {
+		currency = DefaultCurrency
+	}
+	pts := make(plotter.XYs, len(revisions))
+	for i, r := range revisions {
+		pts[i].X = float64(r.At.Unix())
+		pts[i].Y = r.TotalImpact
+	}
+	p := plot.New()
+	p.Title.Text = title
+	p.Title.Padding = vg.Points(10)
+	p.X.Label.Text = "Notified At (UTC)"
+	p.X.Tick.Marker = plot.TimeTicks{Format: "01-02 15:04"}
+	p.Y.Label.Text = fmt.Sprintf("Total Impact (%s)", currency)
+	p.Y.Min = 0
+	line, points, err := plotter.NewLinePoints(pts)
+	if err != nil {
+		return nil, err
+	}
+	line.Color = graphColors[1]
+	line.Width = vg.Points(2)
+	points.Color = graphColors[1]
+	points.Radius = vg.Points(3)
+	p.Add(plotter.NewGrid(), line, points)
+	if len(revisions) == 1 {
+		// keep a single point off the axes
+		p.X.Min -= 3600
+		p.X.Max += 3600
+	}
+	w, err := p.WriterTo(vg.Points(800), vg.Points(400), "png")
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	n, err := w.WriteTo(&buf)
+	if err != nil {
+		return nil, fmt.Errorf("failed to write graph: %w", err)
+	}
+	return &Graph{r: bytes.NewReader(buf.Bytes()), size: n}, nil
+}
+
+// postImpactHistory uploads the chart of how the total impact of a evolved
+// across updates to threads, which are indexed like notifiers. Notifiers
+// with an empty thread are skipped.
+func (h *Handler) postImpactHistory(ctx context.Context, notifiers []Notifier, a Anomaly, revisions []ImpactRevision, threads []string) error {
+	if len(revisions) < 2 {
+		return nil
+	}
+	title := fmt.Sprintf("Total Impact History of %s", a.AnomalyID)
+	g, err := renderImpactHistory(title, revisions, h.currency)
+	if err != nil {
+		return fmt.Errorf("failed to render impact history: %w", err)
+	}
+	name := fmt.Sprintf("anomaly-%s-impact-history.png", a.AnomalyID)
+	var errs []error
+	for i, n := range notifiers {
+		ts := threads[i]
+		if ts == "" {
+			continue
+		}
+		if _, err := n.UploadImage(ctx, ts, name, g); err != nil {
+			errs = append(errs, fmt.Errorf("notifier %s: %w", n.ID(), err))
+			continue
+		}
+		h.logger.InfoContext(ctx, "upload impact history", "notifier_id", n.ID(), "file_name", name)
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,70 @@
+package reactor
+
+import (
+	"bytes"
+	"context"
+	"image/png"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/Songmu/flextime"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatImpactDelta(t *testing.T) {
+	cases := []struct {
+		prev, cur float64
+		currency  string
+		expected  string
+	}{
+		{prev: 1001, cur: 1200, currency: "USD", expected: "$1,001.00 → $1,200.00 (+$199.00, +19.88%)"},
+		{prev: 1200, cur: 1001.5, currency: "", expected: "$1,200.00 → $1,001.50 (-$198.50, -16.54%)"},
+		{prev: 0, cur: 10, currency: "USD", expected: "$0.00 → $10.00 (+$10.00)"},
+		{prev: 123456, cur: 1234567, currency: "JPY", expected: "¥123,456 → ¥1,234,567 (+¥1,111,111, +900.01%)"},
+		{prev: 10, cur: 15, currency: "CHF", expected: "10.00 CHF → 15.00 CHF (+5.00 CHF, +50.00%)"},
+	}
+	for _, c := range cases {
+		t.Run(c.expected, func(t *testing.T) {
+			require.Equal(t, c.expected, formatImpactDelta(c.prev, c.cur, c.currency))
+		})
+	}
+}
+
+func TestHandlerPostImpactHistory(t *testing.T) {
+	now := time.Date(2021, 5, 25, 9, 0, 0, 0, time.UTC)
+	restore := flextime.Fix(now)
+	defer restore()
+
+	notifier := &recordingNotifier{id: "T0123"}
+	h := &Handler{
+		notifiers:         []Notifier{notifier},
+		logger:            slog.Default(),
+		graphGenerator:    newTestGraphGenerator(t),
+		ddb:               newMemoryDynamoDB(),
+		dynamodbTableName: "test",
+		currency:          DefaultCurrency,
+	}
+	ctx := context.Background()
+	a := loadTestAnomaly(t, "testdata/anomaly.json")
+	require.NoError(t, h.postAnomalyDetectedMessage(ctx, a))
+	flextime.Fix(now.Add(24 * time.Hour))
+	a.Impact.TotalImpact = 1200
+	require.NoError(t, h.postAnomalyDetectedMessage(ctx, a))
+
+	var replies []string
+	var history []recordedNotification
+	for _, r := range notifier.Records() {
+		switch {
+		case r.Method == "PostThreadReply":
+			replies = append(replies, r.Text)
+		case r.Method == "UploadImage" && r.Name == "anomaly-"+a.AnomalyID+"-impact-history.png":
+			history = append(history, r)
+		}
+	}
+	require.Equal(t, []string{"Total Impact updated: $1,001.00 → $1,200.00 (+$199.00, +19.88%)"}, replies)
+	require.Len(t, history, 1)
+	require.Equal(t, "1700000000.000001", history[0].Thread)
+	_, err := png.Decode(bytes.NewReader(history[0].Image))
+	require.NoError(t, err)
+}
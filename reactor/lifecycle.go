@@ -0,0 +1,317 @@
+package reactor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Songmu/flextime"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer/types"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	ddbtypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+const (
+	// lifecycleStateID is the range key of the AnomalyLifecycle items.
+	lifecycleStateID = "lifecycle"
+	// lifecycleCloseGrace is how long after the end of its end date an
+	// anomaly without updates is left open.
+	lifecycleCloseGrace = 24 * time.Hour
+)
+
+// AnomalyStatus is the status of an anomaly in its lifecycle.
+type AnomalyStatus string
+
+const (
+	// AnomalyStatusOpen is the status of a detected anomaly without feedback.
+	AnomalyStatusOpen AnomalyStatus = "open"
+	// AnomalyStatusAcknowledged is the status of an anomaly confirmed as
+	// accurate.
+	AnomalyStatusAcknowledged AnomalyStatus = "acknowledged"
+	// AnomalyStatusResolved is the status of an anomaly dismissed by
+	// feedback, or closed because it ended without further updates.
+	AnomalyStatusResolved AnomalyStatus = "resolved"
+)
+
+const (
+	resolvedByFeedback = "feedback"
+	resolvedByEnded    = "ended"
+)
+
+// AnomalyLifecycle is the DynamoDB record of the history of an anomaly. It
+// shares the table of AnomalySlackMessage with the fixed range key
+// "lifecycle".
+type AnomalyLifecycle struct {
+	AnomalyID      string           `json:"anomalyId"`
+	SlackTeamID    string           `json:"-"`
+	Status         AnomalyStatus    `json:"status"`
+	FirstSeenAt    time.Time        `json:"firstSeenAt"`
+	LastUpdatedAt  time.Time        `json:"lastUpdatedAt"`
+	ResolvedAt     time.Time        `json:"resolvedAt,omitzero" dynamodbav:",omitempty"`
+	ResolvedReason string           `json:"resolvedReason,omitempty" dynamodbav:",omitempty"`
+	Anomaly        *Anomaly         `json:"anomaly,omitempty" dynamodbav:",omitempty"`
+	Revisions      []ImpactRevision `json:"revisions"`
+	Feedback       []FeedbackEvent  `json:"feedback"`
+	// DeniedFeedback are the feedback refused by FeedbackAuthConfig.
+	DeniedFeedback []FeedbackEvent `json:"deniedFeedback,omitempty" dynamodbav:",omitempty"`
+	TTL            int64           `json:"-"`
+}
+
+// dismissed reports whether the last feedback of the anomaly was No or
+// PlannedActivity.
+func (lc *AnomalyLifecycle) dismissed() bool {
+	n := len(lc.Feedback)
+	if n == 0 {
+		return false
+	}
+	switch lc.Feedback[n-1].Type {
+	case types.AnomalyFeedbackTypeNo, types.AnomalyFeedbackTypePlannedActivity:
+		return true
+	default:
+		return false
+	}
+}
+
+// ImpactRevision is the impact of an anomaly as notified at a point in time.
+type ImpactRevision struct {
+	At                    time.Time `json:"at"`
+	TotalImpact           float64   `json:"totalImpact"`
+	TotalImpactPercentage float64   `json:"totalImpactPercentage"`
+	MaxImpact             float64   `json:"maxImpact"`
+}
+
+// FeedbackEvent is a feedback provided for an anomaly.
+type FeedbackEvent struct {
+	At     time.Time                 `json:"at"`
+	Type   types.AnomalyFeedbackType `json:"type"`
+	User   string                    `json:"user,omitempty"`
+	UserID string                    `json:"userId,omitempty"`
+	Source string                    `json:"source,omitempty"`
+}
+
+// GetAnomalyLifecycle looks up the lifecycle record of an anomaly. The
+// boolean return is false when no record is found.
+func (h *Handler) GetAnomalyLifecycle(ctx context.Context, anomalyID string) (*AnomalyLifecycle, bool, error) {
+	var lc AnomalyLifecycle
+	if ok, err := h.getStateItem(ctx, anomalyID, lifecycleStateID, &lc); err != nil || !ok {
+		return nil, false, err
+	}
+	if lc.Status == "" {
+		return nil, false, nil
+	}
+	return &lc, true, nil
+}
+
+func (h *Handler) saveAnomalyLifecycle(ctx context.Context, lc *AnomalyLifecycle) error {
+	lc.SlackTeamID = lifecycleStateID
+	lc.TTL = time.Now().AddDate(0, 1, 0).Unix()
+	return h.putStateItem(ctx, lc)
+}
+
+// trackAnomaly records a notification of a in its lifecycle and returns the
+// updated record, or nil when the DynamoDB table is not configured. An
+// anomaly closed because it had ended is reopened, as acknowledged when its
+// last feedback was Yes.
+func (h *Handler) trackAnomaly(ctx context.Context, a Anomaly) (*AnomalyLifecycle, error) {
+	if !h.EnableDynamoDB() {
+		return nil, nil
+	}
+	now := flextime.Now()
+	lc, ok, err := h.GetAnomalyLifecycle(ctx, a.AnomalyID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		lc = &AnomalyLifecycle{
+			AnomalyID:   a.AnomalyID,
+			Status:      AnomalyStatusOpen,
+			FirstSeenAt: now,
+		}
+	}
+	if lc.Status == AnomalyStatusResolved && lc.ResolvedReason == resolvedByEnded {
+		lc.Status = AnomalyStatusOpen
+		if n := len(lc.Feedback); n > 0 && lc.Feedback[n-1].Type == types.AnomalyFeedbackTypeYes {
+			lc.Status = AnomalyStatusAcknowledged
+		}
+		lc.ResolvedAt = time.Time{}
+		lc.ResolvedReason = ""
+	}
+	lc.LastUpdatedAt = now
+	lc.Anomaly = &a
+	if n := len(lc.Revisions); n == 0 || lc.Revisions[n-1].TotalImpact != a.Impact.TotalImpact {
+		lc.Revisions = append(lc.Revisions, ImpactRevision{
+			At:                    now,
+			TotalImpact:           a.Impact.TotalImpact,
+			TotalImpactPercentage: a.Impact.TotalImpactPercentage,
+			MaxImpact:             a.Impact.MaxImpact,
+		})
+	}
+	if err := h.saveAnomalyLifecycle(ctx, lc); err != nil {
+		return nil, err
+	}
+	return lc, nil
+}
+
+// trackFeedback records a feedback in the lifecycle of the anomaly, updates
+// its status and shows the new status in the posted messages. userID is the
+// ID of the user on the source, if any.
+func (h *Handler) trackFeedback(ctx context.Context, anomalyID string, actionID string, user string, userID string, source string) error {
+	if !h.EnableDynamoDB() {
+		return nil
+	}
+	lc, ok, err := h.GetAnomalyLifecycle(ctx, anomalyID)
+	if err != nil || !ok {
+		return err
+	}
+	now := flextime.Now()
+	ev := FeedbackEvent{At: now, User: user, UserID: userID, Source: source}
+	switch actionID {
+	case actionsYesID:
+		ev.Type = types.AnomalyFeedbackTypeYes
+		lc.Status = AnomalyStatusAcknowledged
+		lc.ResolvedAt = time.Time{}
+		lc.ResolvedReason = ""
+	case actionsNoID, actionsPlanedActivityID:
+		ev.Type = types.AnomalyFeedbackTypeNo
+		if actionID == actionsPlanedActivityID {
+			ev.Type = types.AnomalyFeedbackTypePlannedActivity
+		}
+		lc.Status = AnomalyStatusResolved
+		lc.ResolvedAt = now
+		lc.ResolvedReason = resolvedByFeedback
+	default:
+		return fmt.Errorf("invalid action id: %s", actionID)
+	}
+	lc.Feedback = append(lc.Feedback, ev)
+	if err := h.saveAnomalyLifecycle(ctx, lc); err != nil {
+		return err
+	}
+	return h.updateAnomalyStatus(ctx, lc)
+}
+
+// trackDeniedFeedback records a feedback refused by FeedbackAuthConfig in the
+// lifecycle of the anomaly. The status is left unchanged.
+func (h *Handler) trackDeniedFeedback(ctx context.Context, anomalyID string, actionID string, user string, userID string, source string) error {
+	if !h.EnableDynamoDB() {
+		return nil
+	}
+	lc, ok, err := h.GetAnomalyLifecycle(ctx, anomalyID)
+	if err != nil || !ok {
+		return err
+	}
+	feedback, err := actionFeedbackType(actionID)
+	if err != nil {
+		return err
+	}
+	lc.DeniedFeedback = append(lc.DeniedFeedback, FeedbackEvent{
+		At:     flextime.Now(),
+		Type:   feedback,
+		User:   user,
+		UserID: userID,
+		Source: source,
+	})
+	return h.saveAnomalyLifecycle(ctx, lc)
+}
+
+// updateAnomalyStatus re-renders the posted messages of an anomaly with its
+// current status.
+func (h *Handler) updateAnomalyStatus(ctx context.Context, lc *AnomalyLifecycle) error {
+	if lc.Anomaly == nil {
+		return nil
+	}
+	data, err := h.newTemplateData(ctx, *lc.Anomaly)
+	if err != nil {
+		return fmt.Errorf("failed to create template data: %w", err)
+	}
+	data.Status = lc.Status
+	var errs []error
+	for _, n := range h.allNotifiers(ctx) {
+		msg, ok, err := h.getAnomalySlackMessage(ctx, lc.AnomalyID, n.ID())
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if err := n.UpdateAnomaly(ctx, msg.SlackMessageTimestamp, data); err != nil {
+			errs = append(errs, fmt.Errorf("notifier %s: %w", n.ID(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ListAnomalyLifecycles returns the lifecycle records of all anomalies in
+// the state store.
+func (h *Handler) ListAnomalyLifecycles(ctx context.Context) ([]*AnomalyLifecycle, error) {
+	paginator := dynamodb.NewScanPaginator(h.ddb, &dynamodb.ScanInput{
+		TableName:        aws.String(h.dynamodbTableName),
+		FilterExpression: aws.String("SlackTeamID = :id"),
+		ExpressionAttributeValues: map[string]ddbtypes.AttributeValue{
+			":id": &ddbtypes.AttributeValueMemberS{Value: lifecycleStateID},
+		},
+	})
+	var lifecycles []*AnomalyLifecycle
+	for paginator.HasMorePages() {
+		out, err := paginator.NextPage(ctx)
+		stateStoreOperations.WithLabelValues("scan", resultLabel(err)).Inc()
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan anomaly lifecycles: %w", err)
+		}
+		for _, item := range out.Items {
+			var lc AnomalyLifecycle
+			if err := attributevalue.UnmarshalMap(item, &lc); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal item: %w", err)
+			}
+			if lc.SlackTeamID != lifecycleStateID || lc.Status == "" {
+				continue
+			}
+			lifecycles = append(lifecycles, &lc)
+		}
+	}
+	return lifecycles, nil
+}
+
+// CloseEndedAnomalies resolves the open and acknowledged anomalies whose end
+// date has passed without further updates.
+func (h *Handler) CloseEndedAnomalies(ctx context.Context) error {
+	if !h.EnableDynamoDB() {
+		return nil
+	}
+	lifecycles, err := h.ListAnomalyLifecycles(ctx)
+	if err != nil {
+		return err
+	}
+	now := flextime.Now()
+	var errs []error
+	for _, lc := range lifecycles {
+		if lc.Status == AnomalyStatusResolved || lc.Anomaly == nil {
+			continue
+		}
+		ended := lc.Anomaly.AnomalyEndDate.AddDate(0, 0, 1).Add(lifecycleCloseGrace)
+		if now.Before(ended) || now.Sub(lc.LastUpdatedAt) < lifecycleCloseGrace {
+			continue
+		}
+		if err := h.closeAnomaly(ctx, lc, now); err != nil {
+			errs = append(errs, fmt.Errorf("anomaly %s: %w", lc.AnomalyID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (h *Handler) closeAnomaly(ctx context.Context, lc *AnomalyLifecycle, now time.Time) error {
+	lc.Status = AnomalyStatusResolved
+	lc.ResolvedAt = now
+	lc.ResolvedReason = resolvedByEnded
+	if err := h.saveAnomalyLifecycle(ctx, lc); err != nil {
+		return err
+	}
+	h.logger.InfoContext(ctx, "closed ended anomaly", "anomaly_id", lc.AnomalyID)
+	if err := h.resolveEscalation(ctx, lc.AnomalyID, "Resolved because the anomaly ended."); err != nil {
+		h.logger.ErrorContext(ctx, "failed to resolve escalation", "anomaly_id", lc.AnomalyID, "error", err)
+	}
+	return h.updateAnomalyStatus(ctx, lc)
+}
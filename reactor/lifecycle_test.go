@@ -0,0 +1,99 @@
+package reactor
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Songmu/flextime"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer/types"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandlerAnomalyLifecycle(t *testing.T) {
+	now := time.Date(2021, 5, 25, 9, 0, 0, 0, time.UTC)
+	restore := flextime.Fix(now)
+	defer restore()
+
+	notifier := &recordingNotifier{id: "T0123"}
+	h := &Handler{
+		notifiers:         []Notifier{notifier},
+		logger:            slog.Default(),
+		graphGenerator:    newTestGraphGenerator(t),
+		ddb:               newMemoryDynamoDB(),
+		dynamodbTableName: "test",
+		apiToken:          "secret",
+	}
+	ctx := context.Background()
+	a := loadTestAnomaly(t, "testdata/anomaly.json")
+	require.NoError(t, h.postAnomalyDetectedMessage(ctx, a))
+	records := notifier.Records()
+	require.Equal(t, "PostAnomaly", records[0].Method)
+	require.Equal(t, AnomalyStatusOpen, records[0].Data.Status)
+
+	flextime.Fix(now.Add(time.Hour))
+	require.NoError(t, h.postAnomalyDetectedMessage(ctx, a))
+	a.Impact.TotalImpact = 1200
+	require.NoError(t, h.postAnomalyDetectedMessage(ctx, a))
+	require.NoError(t, h.trackFeedback(ctx, a.AnomalyID, actionsYesID, "alice", "U0ALICE", "slack"))
+	records = notifier.Records()
+	last := records[len(records)-1]
+	require.Equal(t, "UpdateAnomaly", last.Method)
+	require.Equal(t, AnomalyStatusAcknowledged, last.Data.Status)
+
+	// still within the grace period after the end date
+	flextime.Fix(now.Add(30 * time.Hour))
+	require.NoError(t, h.RunScheduledTasks(ctx))
+	lc, ok, err := h.GetAnomalyLifecycle(ctx, a.AnomalyID)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, AnomalyStatusAcknowledged, lc.Status)
+
+	flextime.Fix(now.Add(48 * time.Hour))
+	require.NoError(t, h.RunScheduledTasks(ctx))
+	lc, ok, err = h.GetAnomalyLifecycle(ctx, a.AnomalyID)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, AnomalyStatusResolved, lc.Status)
+	require.Equal(t, resolvedByEnded, lc.ResolvedReason)
+	records = notifier.Records()
+	require.Equal(t, AnomalyStatusResolved, records[len(records)-1].Data.Status)
+
+	get := func(token string, id string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/anomalies/"+id, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		req = mux.SetURLVars(req, map[string]string{"id": id})
+		w := httptest.NewRecorder()
+		h.requireAPIToken(h.handleGetAnomaly)(w, req)
+		return w
+	}
+	require.Equal(t, http.StatusUnauthorized, get("wrong", a.AnomalyID).Code)
+	require.Equal(t, http.StatusNotFound, get("secret", "unknown").Code)
+	w := get("secret", a.AnomalyID)
+	require.Equal(t, http.StatusOK, w.Code)
+	var got AnomalyLifecycle
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+	require.Equal(t, AnomalyStatusResolved, got.Status)
+	require.True(t, now.Equal(got.FirstSeenAt))
+	require.Len(t, got.Revisions, 2)
+	require.Equal(t, 1001.0, got.Revisions[0].TotalImpact)
+	require.Equal(t, 1200.0, got.Revisions[1].TotalImpact)
+	require.Equal(t, []FeedbackEvent{{
+		At:     now.Add(time.Hour),
+		Type:   types.AnomalyFeedbackTypeYes,
+		User:   "alice",
+		UserID: "U0ALICE",
+		Source: "slack",
+	}}, got.Feedback)
+
+	// an update reopens an anomaly closed because it ended
+	require.NoError(t, h.postAnomalyDetectedMessage(ctx, a))
+	lc, _, err = h.GetAnomalyLifecycle(ctx, a.AnomalyID)
+	require.NoError(t, err)
+	require.Equal(t, AnomalyStatusAcknowledged, lc.Status)
+}
@@ -0,0 +1,87 @@
+package reactor
+
+import (
+	"context"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	ddbtypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// memoryDynamoDB is an in-memory stand-in for the state table, used in
+// development mode and tests. Items are lost when the process exits.
+type memoryDynamoDB struct {
+	mu    sync.Mutex
+	items map[string]map[string]ddbtypes.AttributeValue
+}
+
+var _ DynamoDBAPIClient = (*memoryDynamoDB)(nil)
+
+func newMemoryDynamoDB() *memoryDynamoDB {
+	return &memoryDynamoDB{items: make(map[string]map[string]ddbtypes.AttributeValue)}
+}
+
+func memoryDynamoDBKey(item map[string]ddbtypes.AttributeValue) string {
+	var key string
+	for _, name := range []string{"AnomalyID", "SlackTeamID"} {
+		if v, ok := item[name].(*ddbtypes.AttributeValueMemberS); ok {
+			key += v.Value + "/"
+		}
+	}
+	return key
+}
+
+func (m *memoryDynamoDB) DescribeTable(_ context.Context, params *dynamodb.DescribeTableInput, _ ...func(*dynamodb.Options)) (*dynamodb.DescribeTableOutput, error) {
+	return &dynamodb.DescribeTableOutput{
+		Table: &ddbtypes.TableDescription{
+			TableName:   params.TableName,
+			TableStatus: ddbtypes.TableStatusActive,
+		},
+	}, nil
+}
+
+func (m *memoryDynamoDB) CreateTable(_ context.Context, params *dynamodb.CreateTableInput, _ ...func(*dynamodb.Options)) (*dynamodb.CreateTableOutput, error) {
+	return &dynamodb.CreateTableOutput{
+		TableDescription: &ddbtypes.TableDescription{
+			TableName:   params.TableName,
+			TableStatus: ddbtypes.TableStatusActive,
+		},
+	}, nil
+}
+
+func (m *memoryDynamoDB) DescribeTimeToLive(_ context.Context, _ *dynamodb.DescribeTimeToLiveInput, _ ...func(*dynamodb.Options)) (*dynamodb.DescribeTimeToLiveOutput, error) {
+	return &dynamodb.DescribeTimeToLiveOutput{
+		TimeToLiveDescription: &ddbtypes.TimeToLiveDescription{
+			AttributeName:    aws.String("TTL"),
+			TimeToLiveStatus: ddbtypes.TimeToLiveStatusEnabled,
+		},
+	}, nil
+}
+
+func (m *memoryDynamoDB) UpdateTimeToLive(_ context.Context, params *dynamodb.UpdateTimeToLiveInput, _ ...func(*dynamodb.Options)) (*dynamodb.UpdateTimeToLiveOutput, error) {
+	return &dynamodb.UpdateTimeToLiveOutput{TimeToLiveSpecification: params.TimeToLiveSpecification}, nil
+}
+
+func (m *memoryDynamoDB) GetItem(_ context.Context, params *dynamodb.GetItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.GetItemOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return &dynamodb.GetItemOutput{Item: m.items[memoryDynamoDBKey(params.Key)]}, nil
+}
+
+func (m *memoryDynamoDB) Scan(_ context.Context, _ *dynamodb.ScanInput, _ ...func(*dynamodb.Options)) (*dynamodb.ScanOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := &dynamodb.ScanOutput{}
+	for _, item := range m.items {
+		out.Items = append(out.Items, item)
+	}
+	return out, nil
+}
+
+func (m *memoryDynamoDB) PutItem(_ context.Context, params *dynamodb.PutItemInput, _ ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.items[memoryDynamoDBKey(params.Item)] = params.Item
+	return &dynamodb.PutItemOutput{}, nil
+}
@@ -0,0 +1,235 @@
+package reactor
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/smithy-go/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+)
+
+const metricsNamespace = "aws_cost_anomaly_reactor"
+
+// metricsRegistry holds the metrics of all Handlers in the process. They are
+// exposed by MetricsHandler in server mode and by EMFEmitter under Lambda.
+var metricsRegistry = prometheus.NewRegistry()
+
+var (
+	notificationsReceived = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "notifications_received_total",
+		Help:      "Amazon SNS messages received, by message type.",
+	}, []string{"type"})
+	anomaliesPosted = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "anomalies_posted_total",
+		Help:      "Anomaly messages posted to notifiers, by notifier and kind (new or update).",
+	}, []string{"notifier", "kind"})
+	anomaliesSuppressed = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "anomalies_suppressed_total",
+		Help:      "Anomalies that were not posted, by reason.",
+	}, []string{"reason"})
+	feedbackProvided = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "feedback_total",
+		Help:      "Feedback submitted to Cost Anomaly Detection, by feedback type and result.",
+	}, []string{"type", "result"})
+	awsAPICalls = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "aws_api_calls_total",
+		Help:      "AWS API calls, by service, operation and result. The DynamoDB calls are the state store operations.",
+	}, []string{"service", "operation", "result"})
+	awsAPIDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "aws_api_call_duration_seconds",
+		Help:      "Latency of AWS API calls including retries, by service and operation.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"service", "operation"})
+	slackAPIErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "slack_api_errors_total",
+		Help:      "Failed Slack API calls, by method. Each retry attempt is counted.",
+	}, []string{"method"})
+	graphRenderDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "graph_render_duration_seconds",
+		Help:      "Time to render a root-cause graph, by result.",
+		Buckets:   []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30},
+	}, []string{"result"})
+	stateStoreOperations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "state_store_operations_total",
+		Help:      "State store reads and writes, by operation and result.",
+	}, []string{"operation", "result"})
+)
+
+func init() {
+	metricsRegistry.MustRegister(
+		notificationsReceived,
+		anomaliesPosted,
+		anomaliesSuppressed,
+		feedbackProvided,
+		awsAPICalls,
+		awsAPIDuration,
+		slackAPIErrors,
+		graphRenderDuration,
+		stateStoreOperations,
+	)
+}
+
+func resultLabel(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "success"
+}
+
+// countSlackAPIError counts err, if any, as a failed call of the Slack API
+// method and returns it.
+func countSlackAPIError(method string, err error) error {
+	if err != nil {
+		slackAPIErrors.WithLabelValues(method).Inc()
+	}
+	return err
+}
+
+// MetricsHandler serves the metrics in the Prometheus exposition format.
+func MetricsHandler() http.Handler {
+	return promhttp.HandlerFor(metricsRegistry, promhttp.HandlerOpts{})
+}
+
+// instrumentAWSConfig returns a copy of cfg whose clients record the count
+// and latency of every API call.
+func instrumentAWSConfig(cfg aws.Config) aws.Config {
+	cfg = cfg.Copy()
+	cfg.APIOptions = append(cfg.APIOptions, func(stack *middleware.Stack) error {
+		return stack.Initialize.Add(middleware.InitializeMiddlewareFunc("ReactorMetrics", func(ctx context.Context, in middleware.InitializeInput, next middleware.InitializeHandler) (middleware.InitializeOutput, middleware.Metadata, error) {
+			start := time.Now()
+			out, metadata, err := next.HandleInitialize(ctx, in)
+			service := awsmiddleware.GetServiceID(ctx)
+			operation := awsmiddleware.GetOperationName(ctx)
+			awsAPICalls.WithLabelValues(service, operation, resultLabel(err)).Inc()
+			awsAPIDuration.WithLabelValues(service, operation).Observe(time.Since(start).Seconds())
+			return out, metadata, err
+		}), middleware.Before)
+	})
+	return cfg
+}
+
+// DefaultEMFNamespace is the CloudWatch namespace of the metrics emitted by
+// EMFEmitter.
+const DefaultEMFNamespace = "AWSCostAnomalySlackReactor"
+
+// EMFEmitter writes the metrics accumulated since its previous emission as
+// CloudWatch Embedded Metric Format log lines. Counters are emitted as
+// deltas, histograms as the deltas of their sum and count.
+type EMFEmitter struct {
+	Namespace string
+
+	logger *slog.Logger
+	mu     sync.Mutex
+	last   map[string]float64
+}
+
+// NewEMFEmitter returns an EMFEmitter that writes to logger, which must
+// format records as JSON, such as a slog.JSONHandler.
+func NewEMFEmitter(logger *slog.Logger) *EMFEmitter {
+	return &EMFEmitter{
+		Namespace: DefaultEMFNamespace,
+		logger:    logger,
+		last:      make(map[string]float64),
+	}
+}
+
+// Middleware emits the metrics after every request served by next. Under
+// Lambda, a request is an invocation.
+func (e *EMFEmitter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer e.Emit(r.Context())
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Emit writes one log line per metric and label set that changed since the
+// previous call.
+func (e *EMFEmitter) Emit(ctx context.Context) {
+	families, err := metricsRegistry.Gather()
+	if err != nil {
+		e.logger.WarnContext(ctx, "failed to gather metrics", "error", err)
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	now := time.Now().UnixMilli()
+	for _, mf := range families {
+		name := strings.TrimPrefix(mf.GetName(), metricsNamespace+"_")
+		for _, m := range mf.GetMetric() {
+			var values map[string]float64
+			switch mf.GetType() {
+			case dto.MetricType_COUNTER:
+				values = map[string]float64{name: m.GetCounter().GetValue()}
+			case dto.MetricType_HISTOGRAM:
+				values = map[string]float64{
+					name + "_sum":   m.GetHistogram().GetSampleSum(),
+					name + "_count": float64(m.GetHistogram().GetSampleCount()),
+				}
+			default:
+				continue
+			}
+			e.emit(ctx, now, m.GetLabel(), values)
+		}
+	}
+}
+
+func (e *EMFEmitter) emit(ctx context.Context, timestamp int64, labels []*dto.LabelPair, values map[string]float64) {
+	var key strings.Builder
+	dimensions := make([]string, 0, len(labels))
+	attrs := make([]any, 0, len(labels)+len(values)+1)
+	for _, l := range labels {
+		key.WriteString(l.GetName() + "=" + l.GetValue() + ",")
+		dimensions = append(dimensions, l.GetName())
+		attrs = append(attrs, slog.String(l.GetName(), l.GetValue()))
+	}
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var metrics []map[string]string
+	for _, name := range names {
+		k := name + "{" + key.String() + "}"
+		delta := values[name] - e.last[k]
+		e.last[k] = values[name]
+		if delta <= 0 {
+			continue
+		}
+		unit := "Count"
+		if strings.HasSuffix(name, "_seconds_sum") {
+			unit = "Seconds"
+		}
+		metrics = append(metrics, map[string]string{"Name": name, "Unit": unit})
+		attrs = append(attrs, slog.Float64(name, delta))
+	}
+	if len(metrics) == 0 {
+		return
+	}
+	attrs = append(attrs, slog.Any("_aws", map[string]any{
+		"Timestamp": timestamp,
+		"CloudWatchMetrics": []map[string]any{{
+			"Namespace":  e.Namespace,
+			"Dimensions": [][]string{dimensions},
+			"Metrics":    metrics,
+		}},
+	}))
+	e.logger.InfoContext(ctx, "metrics", attrs...)
+}
@@ -0,0 +1,68 @@
+package reactor
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEMFEmitter(t *testing.T) {
+	var buf bytes.Buffer
+	e := NewEMFEmitter(slog.New(slog.NewJSONHandler(&buf, nil)))
+	ctx := context.Background()
+	e.Emit(ctx)
+
+	feedbackLines := func() []map[string]any {
+		t.Helper()
+		var lines []map[string]any
+		s := bufio.NewScanner(&buf)
+		for s.Scan() {
+			var line map[string]any
+			require.NoError(t, json.Unmarshal(s.Bytes(), &line))
+			if line["type"] == "EMF_TEST" {
+				lines = append(lines, line)
+			}
+		}
+		buf.Reset()
+		return lines
+	}
+	require.Empty(t, feedbackLines())
+
+	feedbackProvided.WithLabelValues("EMF_TEST", "success").Add(2)
+	e.Emit(ctx)
+	lines := feedbackLines()
+	require.Len(t, lines, 1)
+	require.Equal(t, 2.0, lines[0]["feedback_total"])
+	require.Equal(t, "success", lines[0]["result"])
+	aws := lines[0]["_aws"].(map[string]any)
+	require.NotZero(t, aws["Timestamp"])
+	require.Equal(t, []any{map[string]any{
+		"Namespace":  DefaultEMFNamespace,
+		"Dimensions": []any{[]any{"result", "type"}},
+		"Metrics":    []any{map[string]any{"Name": "feedback_total", "Unit": "Count"}},
+	}}, aws["CloudWatchMetrics"])
+
+	e.Emit(ctx)
+	require.Empty(t, feedbackLines())
+
+	feedbackProvided.WithLabelValues("EMF_TEST", "success").Inc()
+	e.Emit(ctx)
+	lines = feedbackLines()
+	require.Len(t, lines, 1)
+	require.Equal(t, 1.0, lines[0]["feedback_total"])
+}
+
+func TestMetricsHandler(t *testing.T) {
+	require.Error(t, countSlackAPIError("chat.postMessage", context.Canceled))
+	w := httptest.NewRecorder()
+	MetricsHandler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Contains(t, w.Body.String(), `aws_cost_anomaly_reactor_slack_api_errors_total{method="chat.postMessage"}`)
+}
@@ -0,0 +1,292 @@
+package reactor
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer/types"
+
+	"github.com/mashiike/aws-cost-anomaly-slack-reactor/internal/costexplorerx"
+)
+
+// AnomalyMonitorsAPIClient is the subset of the Cost Explorer client used to
+// manage anomaly monitors and subscriptions from Slack. The Cost Explorer
+// client of the Handler is used for it when it implements this interface.
+type AnomalyMonitorsAPIClient interface {
+	costexplorerx.GetAnomalyMonitorsAPIClient
+	costexplorerx.GetAnomalySubscriptionsAPIClient
+	UpdateAnomalySubscription(ctx context.Context, params *costexplorer.UpdateAnomalySubscriptionInput, optFns ...func(*costexplorer.Options)) (*costexplorer.UpdateAnomalySubscriptionOutput, error)
+}
+
+var _ AnomalyMonitorsAPIClient = (*costexplorer.Client)(nil)
+
+// monitorCommands are the app mention commands that manage monitors and
+// subscriptions.
+var monitorCommands = []string{"monitors", "subscriptions", "subscription"}
+
+const monitorCommandsUsage = "Usage:\n" +
+	"- `monitors`: list the anomaly monitors\n" +
+	"- `subscriptions`: list the anomaly subscriptions and their thresholds\n" +
+	"- `subscription <name|arn> threshold <threshold>`: update the threshold, e.g. `100`, `20%`, `100 and 20%` or `100 or 20%`\n" +
+	"- `subscription <name|arn> frequency <DAILY|IMMEDIATE|WEEKLY>`: update the frequency"
+
+// isSlackAdmin reports whether the Slack user may manage monitors and
+// subscriptions.
+func (h *Handler) isSlackAdmin(userID string) bool {
+	return slices.Contains(h.slackAdmins, userID)
+}
+
+func (h *Handler) anomalyMonitorsClient() (AnomalyMonitorsAPIClient, error) {
+	client, ok := h.ce.(AnomalyMonitorsAPIClient)
+	if !ok {
+		return nil, errors.New("the cost explorer client does not support anomaly monitors")
+	}
+	return client, nil
+}
+
+// monitorCommand runs a monitor management command of userID and returns
+// the reply.
+func (h *Handler) monitorCommand(ctx context.Context, userID string, args []string) string {
+	if len(h.slackAdmins) == 0 {
+		return "Managing anomaly monitors is disabled. Set the Slack admin users to enable it."
+	}
+	if !h.isSlackAdmin(userID) {
+		h.logger.WarnContext(ctx, "monitor command by non admin user", "user_id", userID, "args", args)
+		return fmt.Sprintf("<@%s> is not allowed to manage anomaly monitors.", userID)
+	}
+	var text string
+	var err error
+	switch {
+	case args[0] == "monitors" && len(args) == 1:
+		text, err = h.describeAnomalyMonitors(ctx)
+	case args[0] == "subscriptions" && len(args) == 1:
+		text, err = h.describeAnomalySubscriptions(ctx)
+	case args[0] == "subscription" && len(args) >= 4:
+		text, err = h.updateAnomalySubscription(ctx, userID, args[1], args[2], strings.Join(args[3:], " "))
+	default:
+		return monitorCommandsUsage
+	}
+	if err != nil {
+		h.logger.ErrorContext(ctx, "failed to run monitor command", "user_id", userID, "args", args, "error", err)
+		return fmt.Sprintf("[error] %s", err)
+	}
+	return text
+}
+
+func (h *Handler) listAnomalyMonitors(ctx context.Context) ([]types.AnomalyMonitor, error) {
+	client, err := h.anomalyMonitorsClient()
+	if err != nil {
+		return nil, err
+	}
+	var monitors []types.AnomalyMonitor
+	paginator := costexplorerx.NewGetAnomalyMonitorsPaginator(client, &costexplorer.GetAnomalyMonitorsInput{})
+	for paginator.HasMorePages() {
+		out, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get anomaly monitors: %w", err)
+		}
+		monitors = append(monitors, out.AnomalyMonitors...)
+	}
+	return monitors, nil
+}
+
+func (h *Handler) listAnomalySubscriptions(ctx context.Context) ([]types.AnomalySubscription, error) {
+	client, err := h.anomalyMonitorsClient()
+	if err != nil {
+		return nil, err
+	}
+	var subscriptions []types.AnomalySubscription
+	paginator := costexplorerx.NewGetAnomalySubscriptionsPaginator(client, &costexplorer.GetAnomalySubscriptionsInput{})
+	for paginator.HasMorePages() {
+		out, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get anomaly subscriptions: %w", err)
+		}
+		subscriptions = append(subscriptions, out.AnomalySubscriptions...)
+	}
+	return subscriptions, nil
+}
+
+func (h *Handler) describeAnomalyMonitors(ctx context.Context) (string, error) {
+	monitors, err := h.listAnomalyMonitors(ctx)
+	if err != nil {
+		return "", err
+	}
+	if len(monitors) == 0 {
+		return "No anomaly monitors found.", nil
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d anomaly monitors:\n", len(monitors))
+	for _, m := range monitors {
+		kind := string(m.MonitorType)
+		if m.MonitorDimension != "" {
+			kind += "/" + string(m.MonitorDimension)
+		}
+		fmt.Fprintf(&b, "- *%s* (%s, %d dimension values, last evaluated %s)\n  `%s`\n",
+			aws.ToString(m.MonitorName), kind, m.DimensionalValueCount,
+			cmp.Or(aws.ToString(m.LastEvaluatedDate), "-"), aws.ToString(m.MonitorArn))
+	}
+	return b.String(), nil
+}
+
+func (h *Handler) describeAnomalySubscriptions(ctx context.Context) (string, error) {
+	subscriptions, err := h.listAnomalySubscriptions(ctx)
+	if err != nil {
+		return "", err
+	}
+	if len(subscriptions) == 0 {
+		return "No anomaly subscriptions found.", nil
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d anomaly subscriptions:\n", len(subscriptions))
+	for _, s := range subscriptions {
+		fmt.Fprintf(&b, "- *%s*: threshold `%s`, frequency `%s`, %d monitors, %d subscribers\n  `%s`\n",
+			aws.ToString(s.SubscriptionName), formatThreshold(s), s.Frequency,
+			len(s.MonitorArnList), len(s.Subscribers), aws.ToString(s.SubscriptionArn))
+	}
+	return b.String(), nil
+}
+
+func (h *Handler) updateAnomalySubscription(ctx context.Context, userID string, nameOrArn string, field string, value string) (string, error) {
+	client, err := h.anomalyMonitorsClient()
+	if err != nil {
+		return "", err
+	}
+	subscriptions, err := h.listAnomalySubscriptions(ctx)
+	if err != nil {
+		return "", err
+	}
+	i := slices.IndexFunc(subscriptions, func(s types.AnomalySubscription) bool {
+		return aws.ToString(s.SubscriptionArn) == nameOrArn || aws.ToString(s.SubscriptionName) == nameOrArn
+	})
+	if i < 0 {
+		return "", fmt.Errorf("subscription %q is not found", nameOrArn)
+	}
+	s := subscriptions[i]
+	input := &costexplorer.UpdateAnomalySubscriptionInput{
+		SubscriptionArn: s.SubscriptionArn,
+	}
+	var before, after string
+	switch field {
+	case "threshold":
+		expr, err := parseThresholdExpression(value)
+		if err != nil {
+			return "", err
+		}
+		input.ThresholdExpression = expr
+		before = formatThreshold(s)
+		after = formatThresholdExpression(expr)
+	case "frequency":
+		freq := types.AnomalySubscriptionFrequency(strings.ToUpper(value))
+		if !slices.Contains(freq.Values(), freq) {
+			return "", fmt.Errorf("frequency must be one of %v", freq.Values())
+		}
+		input.Frequency = freq
+		before = string(s.Frequency)
+		after = string(freq)
+	default:
+		return monitorCommandsUsage, nil
+	}
+	if _, err := client.UpdateAnomalySubscription(ctx, input); err != nil {
+		return "", fmt.Errorf("failed to update anomaly subscription: %w", err)
+	}
+	h.logger.InfoContext(ctx, "updated anomaly subscription",
+		"subscription_arn", aws.ToString(s.SubscriptionArn),
+		"field", field, "before", before, "after", after, "user_id", userID)
+	return fmt.Sprintf("<@%s> updated the %s of *%s*: `%s` → `%s`", userID, field, aws.ToString(s.SubscriptionName), before, after), nil
+}
+
+// parseThresholdExpression parses a subscription threshold: an amount such
+// as "100" for the absolute total impact, a percentage such as "20%" for the
+// total impact percentage, or two or more of them joined by "and" or "or".
+func parseThresholdExpression(s string) (*types.Expression, error) {
+	fields := strings.Fields(strings.ToLower(s))
+	if len(fields) == 0 {
+		return nil, errors.New("threshold is empty")
+	}
+	var op string
+	var exprs []types.Expression
+	for i, f := range fields {
+		if i%2 == 1 {
+			if f != "and" && f != "or" {
+				return nil, fmt.Errorf("threshold must be joined by and/or: %q", f)
+			}
+			if op != "" && op != f {
+				return nil, errors.New("threshold can not mix and/or")
+			}
+			op = f
+			continue
+		}
+		key := types.DimensionAnomalyTotalImpactAbsolute
+		f = strings.TrimPrefix(f, ">=")
+		f = strings.TrimPrefix(f, "$")
+		if v, ok := strings.CutSuffix(f, "%"); ok {
+			key = types.DimensionAnomalyTotalImpactPercentage
+			f = v
+		}
+		v, err := strconv.ParseFloat(f, 64)
+		if err != nil || v < 0 {
+			return nil, fmt.Errorf("invalid threshold: %q", fields[i])
+		}
+		exprs = append(exprs, types.Expression{
+			Dimensions: &types.DimensionValues{
+				Key:          key,
+				MatchOptions: []types.MatchOption{types.MatchOptionGreaterThanOrEqual},
+				Values:       []string{strconv.FormatFloat(v, 'f', -1, 64)},
+			},
+		})
+	}
+	if len(fields)%2 == 0 {
+		return nil, fmt.Errorf("threshold ends with %q", fields[len(fields)-1])
+	}
+	switch op {
+	case "and":
+		return &types.Expression{And: exprs}, nil
+	case "or":
+		return &types.Expression{Or: exprs}, nil
+	}
+	return &exprs[0], nil
+}
+
+// formatThreshold formats the threshold of s in the syntax of
+// parseThresholdExpression.
+func formatThreshold(s types.AnomalySubscription) string {
+	if s.ThresholdExpression != nil {
+		return formatThresholdExpression(s.ThresholdExpression)
+	}
+	// subscriptions created before threshold expressions
+	if s.Threshold != nil {
+		return strconv.FormatFloat(*s.Threshold, 'f', -1, 64)
+	}
+	return "-"
+}
+
+func formatThresholdExpression(expr *types.Expression) string {
+	join := func(sep string, exprs []types.Expression) string {
+		parts := make([]string, 0, len(exprs))
+		for _, e := range exprs {
+			parts = append(parts, formatThresholdExpression(&e))
+		}
+		return strings.Join(parts, sep)
+	}
+	switch {
+	case len(expr.And) > 0:
+		return join(" and ", expr.And)
+	case len(expr.Or) > 0:
+		return join(" or ", expr.Or)
+	case expr.Dimensions != nil:
+		v := strings.Join(expr.Dimensions.Values, ",")
+		if expr.Dimensions.Key == types.DimensionAnomalyTotalImpactPercentage {
+			v += "%"
+		}
+		return v
+	}
+	return "-"
+}
@@ -0,0 +1,99 @@
+package reactor
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer/types"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseThresholdExpression(t *testing.T) {
+	cases := []struct {
+		str     string
+		want    string
+		wantErr bool
+	}{
+		{str: "100", want: "100"},
+		{str: "$100.5", want: "100.5"},
+		{str: ">=20%", want: "20%"},
+		{str: "100 and 20%", want: "100 and 20%"},
+		{str: "100 OR 20% or 50", want: "100 or 20% or 50"},
+		{str: "", wantErr: true},
+		{str: "abc", wantErr: true},
+		{str: "-1", wantErr: true},
+		{str: "100 and", wantErr: true},
+		{str: "100 20%", wantErr: true},
+		{str: "100 and 20% or 50", wantErr: true},
+	}
+	for _, c := range cases {
+		t.Run(c.str, func(t *testing.T) {
+			expr, err := parseThresholdExpression(c.str)
+			if c.wantErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, c.want, formatThresholdExpression(expr))
+		})
+	}
+}
+
+func TestFormatThreshold(t *testing.T) {
+	require.Equal(t, "-", formatThreshold(types.AnomalySubscription{}))
+	require.Equal(t, "50", formatThreshold(types.AnomalySubscription{Threshold: aws.Float64(50)}))
+	expr, err := parseThresholdExpression("100 and 20%")
+	require.NoError(t, err)
+	require.Equal(t, "100 and 20%", formatThreshold(types.AnomalySubscription{Threshold: aws.Float64(50), ThresholdExpression: expr}))
+}
+
+func TestHandlerMonitorCommands(t *testing.T) {
+	s := newHandlerTestSuite(t, WithSlackAdmins("U0ADMIN"))
+	subscriptionArn := "arn:aws:ce::123456789012:anomalysubscription/abcd"
+	s.ce.On("GetAnomalySubscriptions", mock.Anything, mock.Anything).Return(&costexplorer.GetAnomalySubscriptionsOutput{
+		AnomalySubscriptions: []types.AnomalySubscription{
+			{
+				SubscriptionArn:  aws.String(subscriptionArn),
+				SubscriptionName: aws.String("daily-cost"),
+				Frequency:        types.AnomalySubscriptionFrequencyDaily,
+				MonitorArnList:   []string{"arn:aws:ce::123456789012:anomalymonitor/abcd"},
+				Threshold:        aws.Float64(100),
+			},
+		},
+	}, nil)
+	want, err := parseThresholdExpression("200 and 10%")
+	require.NoError(t, err)
+	s.ce.On("UpdateAnomalySubscription", mock.Anything, &costexplorer.UpdateAnomalySubscriptionInput{
+		SubscriptionArn:     aws.String(subscriptionArn),
+		ThresholdExpression: want,
+	}).Return(&costexplorer.UpdateAnomalySubscriptionOutput{SubscriptionArn: aws.String(subscriptionArn)}, nil).Once()
+
+	for _, text := range []string{
+		"<@U0123> subscriptions",
+		"<@U0123> subscription daily-cost threshold 200 and 10%",
+		"<@U0123> subscription unknown frequency DAILY",
+	} {
+		resp := s.postAppMention("U0ADMIN", text)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+	resp := s.postAppMention("U0456", "<@U0123> subscription daily-cost threshold 1")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	// a retry of Slack, sent while the first delivery was still running, is
+	// dropped
+	resp = s.postSlackWithHeader("application/json", s.appMention("U0ADMIN", "<@U0123> subscription daily-cost threshold 200 and 10%"), testSigningSecret, http.Header{
+		"X-Slack-Retry-Num":    {"1"},
+		"X-Slack-Retry-Reason": {"http_timeout"},
+	})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	calls := s.slack.Calls()
+	require.Len(t, calls, 4)
+	require.Contains(t, calls[0].Text, "- *daily-cost*: threshold `100`, frequency `DAILY`, 1 monitors, 0 subscribers\n")
+	require.Equal(t, "<@U0ADMIN> updated the threshold of *daily-cost*: `100` → `200 and 10%`", calls[1].Text)
+	require.Equal(t, `[error] subscription "unknown" is not found`, calls[2].Text)
+	require.Equal(t, "<@U0456> is not allowed to manage anomaly monitors.", calls[3].Text)
+	s.ce.AssertNumberOfCalls(t, "UpdateAnomalySubscription", 1)
+}
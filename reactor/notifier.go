@@ -0,0 +1,26 @@
+package reactor
+
+import "context"
+
+// Notifier delivers anomaly notifications to a chat platform. The Slack
+// implementation is SlackNotifier; other platforms implement the same methods
+// so that the anomaly pipeline of the Handler can be reused as is.
+type Notifier interface {
+	// ID identifies the destination. It is persisted as the range key of
+	// AnomalySlackMessage so that every destination keeps its own thread.
+	ID() string
+	// PostAnomaly posts a new anomaly message rendered from data and returns
+	// a reference to the thread it started.
+	PostAnomaly(ctx context.Context, data TemplateData) (string, error)
+	// UpdateAnomaly replaces the anomaly message at the head of thread.
+	UpdateAnomaly(ctx context.Context, thread string, data TemplateData) error
+	// PostThreadReply posts a plain text reply into thread.
+	PostThreadReply(ctx context.Context, thread string, text string) error
+	// UploadImage attaches the graph as an image named name to thread and
+	// returns a permalink to the uploaded image, or "" when the platform
+	// does not provide one.
+	UploadImage(ctx context.Context, thread string, name string, g *Graph) (string, error)
+	// PostMessage posts a plain text message outside of any anomaly thread,
+	// e.g. a subscription confirmation or an error report.
+	PostMessage(ctx context.Context, text string) error
+}
@@ -0,0 +1,192 @@
+package reactor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer/types"
+	"github.com/slack-go/slack"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type recordedNotification struct {
+	Method string
+	Thread string
+	Text   string
+	Data   TemplateData
+	Name   string
+	Image  []byte
+}
+
+type recordingNotifier struct {
+	id      string
+	mu      sync.Mutex
+	records []recordedNotification
+	nextTS  int
+}
+
+func (n *recordingNotifier) record(r recordedNotification) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.records = append(n.records, r)
+}
+
+func (n *recordingNotifier) Records() []recordedNotification {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return append([]recordedNotification(nil), n.records...)
+}
+
+func (n *recordingNotifier) ID() string {
+	return n.id
+}
+
+func (n *recordingNotifier) PostAnomaly(_ context.Context, data TemplateData) (string, error) {
+	n.mu.Lock()
+	n.nextTS++
+	thread := fmt.Sprintf("1700000000.%06d", n.nextTS)
+	n.mu.Unlock()
+	n.record(recordedNotification{Method: "PostAnomaly", Thread: thread, Data: data})
+	return thread, nil
+}
+
+func (n *recordingNotifier) UpdateAnomaly(_ context.Context, thread string, data TemplateData) error {
+	n.record(recordedNotification{Method: "UpdateAnomaly", Thread: thread, Data: data})
+	return nil
+}
+
+func (n *recordingNotifier) PostThreadReply(_ context.Context, thread string, text string) error {
+	n.record(recordedNotification{Method: "PostThreadReply", Thread: thread, Text: text})
+	return nil
+}
+
+func (n *recordingNotifier) UploadImage(_ context.Context, thread string, name string, g *Graph) (string, error) {
+	bs, err := io.ReadAll(g.NewReader())
+	if err != nil {
+		return "", err
+	}
+	n.record(recordedNotification{Method: "UploadImage", Thread: thread, Name: name, Image: bs})
+	return "https://files.example.com/" + name, nil
+}
+
+func (n *recordingNotifier) PostMessage(_ context.Context, text string) error {
+	n.record(recordedNotification{Method: "PostMessage", Text: text})
+	return nil
+}
+
+func loadTestAnomaly(t *testing.T, name string) Anomaly {
+	t.Helper()
+	bs, err := os.ReadFile(name)
+	require.NoError(t, err)
+	var a Anomaly
+	require.NoError(t, json.Unmarshal(bs, &a))
+	return a
+}
+
+func newTestGraphGenerator(t *testing.T) *GraphGenerator {
+	t.Helper()
+	mockClient := mockGetCostAndUsageAPIClient{t: t}
+	mockOrgClient := mockDescribeAccountAPIClient{t: t}
+	mockClient.On("GetCostAndUsage", mock.Anything, mock.Anything).Return(&costexplorer.GetCostAndUsageOutput{
+		ResultsByTime: []types.ResultByTime{
+			{
+				TimePeriod: &types.DateInterval{
+					Start: aws.String("2021-05-20"),
+					End:   aws.String("2021-05-21"),
+				},
+				Total: map[string]types.MetricValue{
+					"NetUnblendedCost": {
+						Amount: aws.String("1.75"),
+						Unit:   aws.String("USD"),
+					},
+				},
+			},
+		},
+	}, nil)
+	gen := NewGraphGenerator(&mockClient, &mockOrgClient)
+	gen.RateLimiter = nil
+	return gen
+}
+
+func TestHandlerPostAnomalyDetectedMessage(t *testing.T) {
+	a := loadTestAnomaly(t, "testdata/anomaly.json")
+	gen := newTestGraphGenerator(t)
+	notifier := &recordingNotifier{}
+	h := &Handler{
+		notifiers:      []Notifier{notifier},
+		logger:         slog.Default(),
+		graphGenerator: gen,
+	}
+	err := h.postAnomalyDetectedMessage(context.Background(), a)
+	require.NoError(t, err)
+
+	records := notifier.Records()
+	require.Len(t, records, 2)
+	require.Equal(t, "PostAnomaly", records[0].Method)
+	require.Equal(t, a, records[0].Data.Anomaly)
+	require.Equal(t, "abcdef12-1234-4ea0-84cc-918a97d736ef", records[0].Data.MonitorID)
+	require.Equal(t, "UploadImage", records[1].Method)
+	require.Equal(t, records[0].Thread, records[1].Thread)
+	require.Equal(t, "anomaly-12345678-abcd-ef12-3456-987654321a12-root-cause1.png", records[1].Name)
+	require.NotEmpty(t, records[1].Image)
+}
+
+func TestSlackNotifier(t *testing.T) {
+	var mu sync.Mutex
+	var calls []string
+	var postedBlocks string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/chat.postMessage", func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		mu.Lock()
+		calls = append(calls, "chat.postMessage:"+r.FormValue("channel")+":"+r.FormValue("thread_ts"))
+		if r.FormValue("blocks") != "" {
+			postedBlocks = r.FormValue("blocks")
+		}
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"ok":true,"channel":"C0123","ts":"1700000000.000100"}`)
+	})
+	mux.HandleFunc("/chat.update", func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		mu.Lock()
+		calls = append(calls, "chat.update:"+r.FormValue("channel")+":"+r.FormValue("ts"))
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"ok":true,"channel":"C0123","ts":"1700000000.000100"}`)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	tpl, err := parseTemplate("default", defaultTemplate)
+	require.NoError(t, err)
+	client := slack.New("xoxb-dummy", slack.OptionAPIURL(srv.URL+"/"))
+	n := NewSlackNotifier(client, "T0123", "C0123", tpl, RetryPolicy{})
+	h := &Handler{}
+	data, err := h.newTemplateData(context.Background(), loadTestAnomaly(t, "testdata/anomaly.json"))
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	ts, err := n.PostAnomaly(ctx, data)
+	require.NoError(t, err)
+	require.Equal(t, "1700000000.000100", ts)
+	require.NoError(t, n.UpdateAnomaly(ctx, ts, data))
+	require.NoError(t, n.PostThreadReply(ctx, ts, "hello"))
+	require.Equal(t, []string{
+		"chat.postMessage:C0123:",
+		"chat.update:C0123:1700000000.000100",
+		"chat.postMessage:C0123:1700000000.000100",
+	}, calls)
+	require.Contains(t, postedBlocks, `"block_id":"aws-cost-anomaly-detection-reactor"`)
+}
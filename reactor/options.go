@@ -4,6 +4,7 @@ import (
 	"log/slog"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	"golang.org/x/time/rate"
 )
 
 type optionParams struct {
@@ -12,9 +13,37 @@ type optionParams struct {
 	slackBotToken     string
 	slackChannel      string
 	slackSignalSecret string
+	slackAppToken     string
+	slackAdmins       []string
+	slackOAuth        SlackOAuthConfig
 	templateStr       string
 	dynamodbTableName string
 	noErrorReport     bool
+	graphConcurrency  int
+	ceRateLimit       rate.Limit
+	retryPolicy       RetryPolicy
+	teams             TeamsConfig
+	teamsTemplateStr  string
+	webhookEndpoints  []WebhookEndpoint
+	github            GitHubConfig
+	githubTemplateStr string
+	escalation        EscalationConfig
+	reminder          ReminderConfig
+	owners            OwnerConfig
+	feedbackAuth      FeedbackAuthConfig
+	apiToken          string
+	currency          string
+	metricsEndpoint   bool
+	dryRunDir         string
+	devDir            string
+	ceClient          CostExplorerAPIClient
+	orgClient         DescribeAccountAPIClient
+	ddbClient         DynamoDBAPIClient
+	slackClient       SlackAPIClient
+	stsClient         STSAPIClient
+	snsClient         SNSAPIClient
+	sqsClient         SQSAPIClient
+	ssmClient         SSMAPIClient
 }
 
 // Option configures a Handler created by New.
@@ -56,6 +85,32 @@ func WithSlackSignalSecret(secret string) Option {
 	}
 }
 
+// WithSlackAppToken sets the app-level token (xapp-...) with which the
+// Handler connects to Slack in Socket Mode. The signing secret is not
+// required then, as events are not received over HTTP.
+func WithSlackAppToken(token string) Option {
+	return func(args *optionParams) {
+		args.slackAppToken = token
+	}
+}
+
+// WithSlackAdmins sets the Slack user IDs allowed to manage anomaly monitors
+// and subscriptions with app mention commands.
+func WithSlackAdmins(userIDs ...string) Option {
+	return func(args *optionParams) {
+		args.slackAdmins = userIDs
+	}
+}
+
+// WithSlackOAuth enables installing the Slack app into other workspaces with
+// OAuth. It requires the DynamoDB table, where the bot tokens of the
+// workspaces are stored.
+func WithSlackOAuth(cfg SlackOAuthConfig) Option {
+	return func(args *optionParams) {
+		args.slackOAuth = cfg
+	}
+}
+
 // WithTemplate sets the message template used by the Handler.
 func WithTemplate(template string) Option {
 	return func(args *optionParams) {
@@ -76,3 +131,208 @@ func WithDynamoDBTableName(tableName string) Option {
 		args.dynamodbTableName = tableName
 	}
 }
+
+// WithGraphConcurrency sets the maximum number of root-cause graphs rendered
+// in parallel for a single anomaly.
+func WithGraphConcurrency(n int) Option {
+	return func(args *optionParams) {
+		args.graphConcurrency = n
+	}
+}
+
+// WithCostExplorerRateLimit sets the maximum number of Cost Explorer
+// GetCostAndUsage requests per second issued while rendering graphs.
+func WithCostExplorerRateLimit(requestsPerSecond float64) Option {
+	return func(args *optionParams) {
+		args.ceRateLimit = rate.Limit(requestsPerSecond)
+	}
+}
+
+// WithRetryPolicy sets the RetryPolicy applied to outbound Slack and Cost
+// Explorer calls.
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(args *optionParams) {
+		args.retryPolicy = policy
+	}
+}
+
+// WithTeams enables posting anomalies to Microsoft Teams through the given bot.
+func WithTeams(cfg TeamsConfig) Option {
+	return func(args *optionParams) {
+		args.teams = cfg
+	}
+}
+
+// WithTeamsTemplate sets the Adaptive Card template used for Microsoft Teams.
+func WithTeamsTemplate(template string) Option {
+	return func(args *optionParams) {
+		args.teamsTemplateStr = template
+	}
+}
+
+// WithWebhookEndpoints adds HTTP endpoints that receive every processed
+// anomaly and feedback as a signed JSON WebhookEvent.
+func WithWebhookEndpoints(endpoints ...WebhookEndpoint) Option {
+	return func(args *optionParams) {
+		args.webhookEndpoints = append(args.webhookEndpoints, endpoints...)
+	}
+}
+
+// WithGitHub enables opening a GitHub issue when an anomaly is confirmed as
+// accurate. It requires the DynamoDB state store.
+func WithGitHub(cfg GitHubConfig) Option {
+	return func(args *optionParams) {
+		args.github = cfg
+	}
+}
+
+// WithGitHubIssueTemplate sets the Markdown template of the GitHub issue body.
+func WithGitHubIssueTemplate(template string) Option {
+	return func(args *optionParams) {
+		args.githubTemplateStr = template
+	}
+}
+
+// WithEscalation enables escalating high-impact anomalies to PagerDuty and/or
+// Opsgenie.
+func WithEscalation(cfg EscalationConfig) Option {
+	return func(args *optionParams) {
+		args.escalation = cfg
+	}
+}
+
+// WithReminder enables reminders for anomalies without feedback. It requires
+// the DynamoDB state store and a schedule calling Handler.RunReminders.
+func WithReminder(cfg ReminderConfig) Option {
+	return func(args *optionParams) {
+		args.reminder = cfg
+	}
+}
+
+// WithOwners sets the owner mapping whose mentions are exposed to the
+// message template as .Owners.
+func WithOwners(cfg OwnerConfig) Option {
+	return func(args *optionParams) {
+		args.owners = cfg
+	}
+}
+
+// WithFeedbackAuth restricts who may submit anomaly feedback from Slack.
+func WithFeedbackAuth(cfg FeedbackAuthConfig) Option {
+	return func(args *optionParams) {
+		args.feedbackAuth = cfg
+	}
+}
+
+// WithAPIToken sets the bearer token required by the /api endpoints. The
+// endpoints are disabled without it.
+func WithAPIToken(token string) Option {
+	return func(args *optionParams) {
+		args.apiToken = token
+	}
+}
+
+// WithCurrency sets the ISO 4217 currency code impacts are labelled with.
+// It is a display label only: amounts are shown as Cost Explorer returns
+// them, without any conversion. Defaults to USD.
+func WithCurrency(currency string) Option {
+	return func(args *optionParams) {
+		args.currency = currency
+	}
+}
+
+// WithMetricsEndpoint serves the Prometheus metrics on GET /metrics. Under
+// Lambda, use EMFEmitter instead.
+func WithMetricsEndpoint() Option {
+	return func(args *optionParams) {
+		args.metricsEndpoint = true
+	}
+}
+
+// WithDryRun writes the anomaly messages and graphs to files in dir with a
+// FileNotifier instead of posting them. Slack is not called, and the state
+// store, Teams, GitHub, escalation, reminders and webhooks are disabled, so
+// only read-only AWS calls are made.
+func WithDryRun(dir string) Option {
+	return func(args *optionParams) {
+		args.dryRunDir = dir
+	}
+}
+
+// WithDevMode runs the Handler against in-process fakes for local
+// development: Cost Explorer returns synthetic cost series, Organizations
+// names every account "dev-<account id>", the state store is kept in memory,
+// and Slack messages and uploaded graphs are rendered into dir/index.html.
+// No AWS credentials or Slack token are needed. Teams, GitHub, escalation and
+// webhooks are disabled.
+func WithDevMode(dir string) Option {
+	return func(args *optionParams) {
+		args.devDir = dir
+	}
+}
+
+// WithCostExplorerClient sets the Cost Explorer client used for graphs,
+// feedback and backfills instead of one created from the AWS config.
+func WithCostExplorerClient(client CostExplorerAPIClient) Option {
+	return func(args *optionParams) {
+		args.ceClient = client
+	}
+}
+
+// WithOrganizationsClient sets the Organizations client used to look up
+// account names, and account tags when it implements
+// ListTagsForResourceAPIClient, instead of one created from the AWS config.
+func WithOrganizationsClient(client DescribeAccountAPIClient) Option {
+	return func(args *optionParams) {
+		args.orgClient = client
+	}
+}
+
+// WithDynamoDBClient sets the DynamoDB client of the state store instead of
+// one created from the AWS config. The state store is enabled only with
+// WithDynamoDBTableName.
+func WithDynamoDBClient(client DynamoDBAPIClient) Option {
+	return func(args *optionParams) {
+		args.ddbClient = client
+	}
+}
+
+// WithSlackClient sets the Slack client instead of one created from the
+// Slack bot token. AuthTest is still called when the token is set.
+func WithSlackClient(client SlackAPIClient) Option {
+	return func(args *optionParams) {
+		args.slackClient = client
+	}
+}
+
+// WithSTSClient sets the STS client used to look up the AWS account ID
+// instead of one created from the AWS config.
+func WithSTSClient(client STSAPIClient) Option {
+	return func(args *optionParams) {
+		args.stsClient = client
+	}
+}
+
+// WithSNSClient sets the SNS client used to confirm subscriptions instead of
+// one created for the region of the topic.
+func WithSNSClient(client SNSAPIClient) Option {
+	return func(args *optionParams) {
+		args.snsClient = client
+	}
+}
+
+// WithSQSClient sets the SQS client used by PollEventQueue instead of one
+// created from the AWS config.
+func WithSQSClient(client SQSAPIClient) Option {
+	return func(args *optionParams) {
+		args.sqsClient = client
+	}
+}
+
+// WithSSMClient sets the SSM client that keeps the bot tokens of the
+// installed workspaces instead of one created from the AWS config.
+func WithSSMClient(client SSMAPIClient) Option {
+	return func(args *optionParams) {
+		args.ssmClient = client
+	}
+}
@@ -0,0 +1,125 @@
+package reactor
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// OwnerConfig maps anomalies to the Slack users or user groups responsible
+// for them. Values are user group IDs (S0123ABCDE), user IDs (U0123ABCDE) or
+// preformatted mentions such as <!subteam^S0123ABCDE>.
+type OwnerConfig struct {
+	// Accounts maps an account ID (the anomaly account or a root-cause
+	// linked account) to its owner.
+	Accounts map[string]string `json:"accounts,omitempty"`
+	// Services maps a root-cause service name, e.g. "Amazon Elastic Compute
+	// Cloud - Compute", to its owner.
+	Services map[string]string `json:"services,omitempty"`
+	// TagKey is the AWS Organizations account tag looked up with
+	// ListTagsForResource. Empty disables the lookup.
+	TagKey string `json:"tagKey,omitempty"`
+	// Tags maps a value of the TagKey tag to its owner. A tag value missing
+	// from Tags is used as the owner itself.
+	Tags map[string]string `json:"tags,omitempty"`
+}
+
+// Enabled reports whether any owner mapping is configured.
+func (cfg OwnerConfig) Enabled() bool {
+	return len(cfg.Accounts) > 0 || len(cfg.Services) > 0 || cfg.TagKey != ""
+}
+
+// ParseOwnerMap parses "key=owner" pairs separated by commas, as given in the
+// OWNER_ACCOUNTS, OWNER_SERVICES and OWNER_TAGS environment variables.
+func ParseOwnerMap(str string) (map[string]string, error) {
+	m := make(map[string]string)
+	for _, pair := range strings.Split(str, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		key, owner, ok := strings.Cut(pair, "=")
+		key, owner = strings.TrimSpace(key), strings.TrimSpace(owner)
+		if !ok || key == "" || owner == "" {
+			return nil, fmt.Errorf("invalid owner mapping: %q", pair)
+		}
+		m[key] = owner
+	}
+	return m, nil
+}
+
+// slackIDPattern matches the ID of a Slack user group (S), user (U) or
+// Enterprise Grid user (W).
+var slackIDPattern = regexp.MustCompile(`^[SUW][A-Z0-9]{8,}$`)
+
+// OwnerMention formats owner as a Slack mention. Owners that are neither a
+// mention nor a Slack ID, e.g. a team name, are rendered as plain text.
+func OwnerMention(owner string) string {
+	switch {
+	case strings.HasPrefix(owner, "<"):
+		return owner
+	case !slackIDPattern.MatchString(owner):
+		return owner
+	case strings.HasPrefix(owner, "S"):
+		return "<!subteam^" + owner + ">"
+	default:
+		return "<@" + owner + ">"
+	}
+}
+
+// resolveOwners returns the mentions of the owners of a, without duplicates,
+// in the order: accounts, services, account tags. Failed tag lookups are
+// logged and skipped.
+func (h *Handler) resolveOwners(ctx context.Context, a Anomaly) []string {
+	cfg := h.owners
+	if !cfg.Enabled() {
+		return nil
+	}
+	accountIDs := []string{a.AccountID}
+	for _, rc := range a.RootCauses {
+		accountIDs = append(accountIDs, rc.LinkedAccount)
+	}
+	var owners []string
+	seen := make(map[string]bool)
+	add := func(owner string) {
+		if owner == "" {
+			return
+		}
+		mention := OwnerMention(owner)
+		if seen[mention] {
+			return
+		}
+		seen[mention] = true
+		owners = append(owners, mention)
+	}
+	for _, id := range accountIDs {
+		add(cfg.Accounts[id])
+	}
+	for _, rc := range a.RootCauses {
+		add(cfg.Services[rc.Service])
+	}
+	if cfg.TagKey == "" || h.graphGenerator == nil {
+		return owners
+	}
+	for _, id := range accountIDs {
+		if id == "" {
+			continue
+		}
+		tags, err := h.graphGenerator.listAccountTags(ctx, id)
+		if err != nil {
+			h.logger.WarnContext(ctx, "failed to list account tags", "account_id", id, "error", err)
+			continue
+		}
+		value, ok := tags[cfg.TagKey]
+		if !ok {
+			continue
+		}
+		if owner, ok := cfg.Tags[value]; ok {
+			add(owner)
+		} else {
+			add(value)
+		}
+	}
+	return owners
+}
@@ -0,0 +1,109 @@
+package reactor
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/organizations"
+	organizationstypes "github.com/aws/aws-sdk-go-v2/service/organizations/types"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type mockOrganizationsAPIClient struct {
+	mockDescribeAccountAPIClient
+}
+
+func (m *mockOrganizationsAPIClient) ListTagsForResource(ctx context.Context, params *organizations.ListTagsForResourceInput, _ ...func(*organizations.Options)) (*organizations.ListTagsForResourceOutput, error) {
+	args := m.Called(ctx, params)
+	output := args.Get(0)
+	err := args.Error(1)
+	if output == nil {
+		return nil, err
+	}
+	ret, ok := output.(*organizations.ListTagsForResourceOutput)
+	if !ok {
+		m.t.Fatalf("unexpected type: %T", output)
+	}
+	return ret, err
+}
+
+func TestHandlerResolveOwners(t *testing.T) {
+	mockOrgClient := &mockOrganizationsAPIClient{mockDescribeAccountAPIClient{t: t}}
+	defer mockOrgClient.AssertExpectations(t)
+	mockOrgClient.On("ListTagsForResource", mock.Anything, &organizations.ListTagsForResourceInput{
+		ResourceId: aws.String("123456789012"),
+	}).Return(&organizations.ListTagsForResourceOutput{
+		Tags: []organizationstypes.Tag{
+			{Key: aws.String("team"), Value: aws.String("platform")},
+		},
+	}, nil).Once()
+	mockOrgClient.On("ListTagsForResource", mock.Anything, &organizations.ListTagsForResourceInput{
+		ResourceId: aws.String("210987654321"),
+	}).Return(&organizations.ListTagsForResourceOutput{
+		Tags: []organizationstypes.Tag{
+			{Key: aws.String("team"), Value: aws.String("S0000DATA")},
+		},
+	}, nil).Once()
+	h := &Handler{
+		logger:         slog.Default(),
+		graphGenerator: NewGraphGenerator(nil, mockOrgClient),
+		owners: OwnerConfig{
+			Accounts: map[string]string{"123456789012": "S0000ACCT"},
+			Services: map[string]string{
+				"Amazon Relational Database Service": "U00000DBA",
+				"Amazon Simple Storage Service":      "S0000ACCT",
+			},
+			TagKey: "team",
+			Tags:   map[string]string{"platform": "<!subteam^S0000PLAT|platform>"},
+		},
+	}
+	a := loadTestAnomaly(t, "testdata/anomaly.json")
+	a.RootCauses = append(a.RootCauses, RootCause{LinkedAccount: "210987654321", Service: "Amazon Simple Storage Service"})
+	expected := []string{
+		"<!subteam^S0000ACCT>",
+		"<@U00000DBA>",
+		"<!subteam^S0000PLAT|platform>",
+		"<!subteam^S0000DATA>",
+	}
+	ctx := context.Background()
+	require.Equal(t, expected, h.resolveOwners(ctx, a))
+	// account tags are cached
+	data, err := h.newTemplateData(ctx, a)
+	require.NoError(t, err)
+	require.Equal(t, expected, data.Owners)
+}
+
+func TestOwnerMention(t *testing.T) {
+	cases := []struct {
+		owner string
+		want  string
+	}{
+		{owner: "S0123ABCDE", want: "<!subteam^S0123ABCDE>"},
+		{owner: "U0123ABCDE", want: "<@U0123ABCDE>"},
+		{owner: "W0123ABCDE", want: "<@W0123ABCDE>"},
+		{owner: "<!subteam^S0123ABCDE|sales>", want: "<!subteam^S0123ABCDE|sales>"},
+		{owner: "Sales", want: "Sales"},
+		{owner: "Unknown", want: "Unknown"},
+		{owner: "Web", want: "Web"},
+		{owner: "S0123", want: "S0123"},
+	}
+	for _, c := range cases {
+		t.Run(c.owner, func(t *testing.T) {
+			require.Equal(t, c.want, OwnerMention(c.owner))
+		})
+	}
+}
+
+func TestParseOwnerMap(t *testing.T) {
+	m, err := ParseOwnerMap("123456789012=S0123ABC, Amazon Relational Database Service = U0123ABC")
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{
+		"123456789012":                       "S0123ABC",
+		"Amazon Relational Database Service": "U0123ABC",
+	}, m)
+	_, err = ParseOwnerMap("123456789012")
+	require.Error(t, err)
+}
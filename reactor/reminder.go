@@ -0,0 +1,181 @@
+package reactor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Songmu/flextime"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer/types"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+)
+
+// ReminderConfig configures the reminders posted in the thread of anomalies
+// that got no feedback. Reminders are sent by RunReminders, which is meant to
+// be called on a schedule.
+type ReminderConfig struct {
+	// RemindAfter is the time after the first post at which a reminder is
+	// posted in the thread. Zero disables the reminder.
+	RemindAfter time.Duration
+	// EscalateAfter is the time after the first post at which
+	// EscalationUserGroup is mentioned in the thread. Zero disables it.
+	EscalateAfter time.Duration
+	// EscalationUserGroup is the ID of the Slack user group (S0123ABC)
+	// mentioned on escalation.
+	EscalationUserGroup string
+}
+
+// Enabled reports whether any reminder is configured.
+func (cfg ReminderConfig) Enabled() bool {
+	return cfg.RemindAfter > 0 || cfg.EscalateAfter > 0
+}
+
+// IsScheduledEvent reports whether a Lambda event is an EventBridge
+// scheduled event, which triggers RunScheduledTasks.
+func IsScheduledEvent(event json.RawMessage) bool {
+	var ev struct {
+		Source     string `json:"source"`
+		DetailType string `json:"detail-type"`
+	}
+	if err := json.Unmarshal(event, &ev); err != nil {
+		return false
+	}
+	return ev.DetailType == "Scheduled Event" || ev.Source == "aws.scheduler"
+}
+
+// HandleLambdaEvent handles Lambda events that are neither HTTP requests nor
+// SQS messages of canyon. Scheduled events run RunScheduledTasks and anomaly
+// events are handled by HandleAnomalyEvent; others are rejected.
+func (h *Handler) HandleLambdaEvent(ctx context.Context, event json.RawMessage) (any, error) {
+	switch {
+	case IsScheduledEvent(event):
+		return nil, h.RunScheduledTasks(ctx)
+	case IsAnomalyEvent(event):
+		return h.HandleAnomalyEvent(ctx, event)
+	default:
+		return nil, errors.New("unsupported lambda event")
+	}
+}
+
+// RunScheduledTasks runs the periodic tasks: reminders and closing ended
+// anomalies.
+func (h *Handler) RunScheduledTasks(ctx context.Context) error {
+	var errs []error
+	if err := h.RunReminders(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("failed to run reminders: %w", err))
+	}
+	if err := h.CloseEndedAnomalies(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("failed to close ended anomalies: %w", err))
+	}
+	return errors.Join(errs...)
+}
+
+func (h *Handler) handleReminders(w http.ResponseWriter, r *http.Request) {
+	if err := h.RunScheduledTasks(r.Context()); err != nil {
+		h.logger.Error("failed to run scheduled tasks", "error", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// RunReminders posts the reminders and escalations that are due for
+// anomalies without feedback. It is idempotent: every stage is posted only
+// once per anomaly and notifier.
+func (h *Handler) RunReminders(ctx context.Context) error {
+	if !h.reminder.Enabled() || !h.EnableDynamoDB() {
+		return nil
+	}
+	all := h.allNotifiers(ctx)
+	notifiers := make(map[string]Notifier, len(all))
+	for _, n := range all {
+		notifiers[n.ID()] = n
+	}
+	paginator := dynamodb.NewScanPaginator(h.ddb, &dynamodb.ScanInput{
+		TableName:        aws.String(h.dynamodbTableName),
+		FilterExpression: aws.String("attribute_exists(PostedAt) AND attribute_not_exists(FeedbackStatus) AND attribute_not_exists(EscalatedAt)"),
+	})
+	var errs []error
+	for paginator.HasMorePages() {
+		out, err := paginator.NextPage(ctx)
+		stateStoreOperations.WithLabelValues("scan", resultLabel(err)).Inc()
+		if err != nil {
+			return fmt.Errorf("failed to scan anomaly slack messages: %w", err)
+		}
+		for _, item := range out.Items {
+			var m AnomalySlackMessage
+			if err := attributevalue.UnmarshalMap(item, &m); err != nil {
+				errs = append(errs, fmt.Errorf("failed to unmarshal item: %w", err))
+				continue
+			}
+			n, ok := notifiers[m.SlackTeamID]
+			if !ok {
+				continue
+			}
+			if err := h.remind(ctx, n, &m); err != nil {
+				errs = append(errs, fmt.Errorf("anomaly %s notifier %s: %w", m.AnomalyID, n.ID(), err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (h *Handler) remind(ctx context.Context, n Notifier, m *AnomalySlackMessage) error {
+	if m.PostedAt == 0 || m.FeedbackStatus != "" || m.EscalatedAt != 0 {
+		return nil
+	}
+	now := flextime.Now()
+	age := now.Sub(time.Unix(m.PostedAt, 0))
+	var text string
+	switch {
+	case h.reminder.EscalateAfter > 0 && age >= h.reminder.EscalateAfter:
+		text = fmt.Sprintf("AnomalyID `%s` has had no feedback for %s. Please review it and provide feedback.", m.AnomalyID, h.reminder.EscalateAfter)
+		if h.reminder.EscalationUserGroup != "" && h.slack != nil && n.ID() == h.slack.ID() {
+			text = fmt.Sprintf("<!subteam^%s> %s", h.reminder.EscalationUserGroup, text)
+		}
+		m.EscalatedAt = now.Unix()
+		if m.RemindedAt == 0 {
+			m.RemindedAt = m.EscalatedAt
+		}
+	case h.reminder.RemindAfter > 0 && age >= h.reminder.RemindAfter && m.RemindedAt == 0:
+		text = fmt.Sprintf("Reminder: AnomalyID `%s` has had no feedback for %s. Is this an accurate anomaly?", m.AnomalyID, h.reminder.RemindAfter)
+		m.RemindedAt = now.Unix()
+	default:
+		return nil
+	}
+	if err := n.PostThreadReply(ctx, m.SlackMessageTimestamp, text); err != nil {
+		return fmt.Errorf("failed to post reminder: %w", err)
+	}
+	h.logger.InfoContext(ctx, "posted reminder", "anomaly_id", m.AnomalyID, "notifier_id", n.ID(), "escalated", m.EscalatedAt != 0)
+	return h.SaveAnomalySlackMessage(ctx, m)
+}
+
+// recordFeedback stores the feedback status on every notifier record of the
+// anomaly, which stops its reminders.
+func (h *Handler) recordFeedback(ctx context.Context, anomalyID string, feedbackType types.AnomalyFeedbackType) error {
+	if !h.EnableDynamoDB() {
+		return nil
+	}
+	var errs []error
+	for _, n := range h.allNotifiers(ctx) {
+		m, ok, err := h.getAnomalySlackMessage(ctx, anomalyID, n.ID())
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		m.FeedbackStatus = string(feedbackType)
+		m.FeedbackAt = flextime.Now().Unix()
+		if err := h.SaveAnomalySlackMessage(ctx, m); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,110 @@
+package reactor
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Songmu/flextime"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer/types"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandlerRunReminders(t *testing.T) {
+	now := time.Date(2021, 5, 25, 9, 0, 0, 0, time.UTC)
+	restore := flextime.Fix(now)
+	defer restore()
+
+	mockClient := mockGetCostAndUsageAPIClient{t: t}
+	defer mockClient.AssertExpectations(t)
+	mockClient.On("ProvideAnomalyFeedback", mock.Anything, &costexplorer.ProvideAnomalyFeedbackInput{
+		AnomalyId: aws.String("second"),
+		Feedback:  types.AnomalyFeedbackTypeYes,
+	}).Return(&costexplorer.ProvideAnomalyFeedbackOutput{}, nil).Once()
+	notifier := &recordingNotifier{id: "T0123"}
+	h := &Handler{
+		ce:                &mockClient,
+		notifiers:         []Notifier{notifier},
+		logger:            slog.Default(),
+		graphGenerator:    newTestGraphGenerator(t),
+		ddb:               newMemoryDynamoDB(),
+		dynamodbTableName: "test",
+		reminder: ReminderConfig{
+			RemindAfter:   6 * time.Hour,
+			EscalateAfter: 24 * time.Hour,
+		},
+	}
+	ctx := context.Background()
+	first := loadTestAnomaly(t, "testdata/anomaly.json")
+	second := first
+	second.AnomalyID = "second"
+	require.NoError(t, h.postAnomalyDetectedMessage(ctx, first))
+	require.NoError(t, h.postAnomalyDetectedMessage(ctx, second))
+	require.NoError(t, h.ProvideFeedback(ctx, "second", actionsYesID))
+
+	countReplies := func() []string {
+		var replies []string
+		for _, r := range notifier.Records() {
+			if r.Method == "PostThreadReply" {
+				replies = append(replies, r.Text)
+			}
+		}
+		return replies
+	}
+	require.NoError(t, h.RunReminders(ctx))
+	require.Empty(t, countReplies())
+
+	flextime.Fix(now.Add(7 * time.Hour))
+	require.NoError(t, h.RunReminders(ctx))
+	require.NoError(t, h.RunReminders(ctx))
+	replies := countReplies()
+	require.Len(t, replies, 1)
+	require.Contains(t, replies[0], "Reminder: AnomalyID `"+first.AnomalyID+"`")
+
+	flextime.Fix(now.Add(25 * time.Hour))
+	require.NoError(t, h.RunReminders(ctx))
+	require.NoError(t, h.RunReminders(ctx))
+	replies = countReplies()
+	require.Len(t, replies, 2)
+	require.Contains(t, replies[1], "has had no feedback for 24h0m0s")
+
+	// an update keeps the lifecycle of the anomaly
+	first.Impact.TotalImpact += 1
+	require.NoError(t, h.postAnomalyDetectedMessage(ctx, first))
+	m, ok, err := h.getAnomalySlackMessage(ctx, first.AnomalyID, "T0123")
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, now.Unix(), m.PostedAt)
+	require.Equal(t, now.Add(25*time.Hour).Unix(), m.EscalatedAt)
+}
+
+func TestIsScheduledEvent(t *testing.T) {
+	require.True(t, IsScheduledEvent([]byte(`{"version":"0","detail-type":"Scheduled Event","source":"aws.events","detail":{}}`)))
+	require.True(t, IsScheduledEvent([]byte(`{"source":"aws.scheduler"}`)))
+	require.False(t, IsScheduledEvent([]byte(`{"Records":[]}`)))
+	require.False(t, IsScheduledEvent([]byte(`not json`)))
+}
+
+func TestHandlerRemindersRoute(t *testing.T) {
+	// without the API token the route is not registered
+	s := newHandlerTestSuite(t)
+	resp, err := s.server.Client().Post(s.server.URL+"/reminders", "application/json", nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	s = newHandlerTestSuite(t, WithAPIToken("secret"))
+	resp, err = s.server.Client().Post(s.server.URL+"/reminders", "application/json", nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	req, err := http.NewRequest(http.MethodPost, s.server.URL+"/reminders", nil)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer secret")
+	resp, err = s.server.Client().Do(req)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+}
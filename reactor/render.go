@@ -0,0 +1,194 @@
+package reactor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"unicode/utf8"
+
+	"github.com/slack-go/slack"
+)
+
+// BlockKitBuilderURL is the base URL of the Slack Block Kit Builder.
+const BlockKitBuilderURL = "https://app.slack.com/block-kit-builder/"
+
+// RenderMessage renders the message template text, or the default template
+// when text is empty, for a as it would be posted to Slack.
+func RenderMessage(ctx context.Context, text string, a Anomaly) (slack.Msg, error) {
+	if text == "" {
+		text = defaultTemplate
+	}
+	tpl, err := parseTemplate("message", text)
+	if err != nil {
+		return slack.Msg{}, fmt.Errorf("failed to parse template: %w", err)
+	}
+	data, err := (&Handler{}).newTemplateData(ctx, a)
+	if err != nil {
+		return slack.Msg{}, fmt.Errorf("failed to create template data: %w", err)
+	}
+	return NewSlackNotifier(nil, "", "", tpl, RetryPolicy{}).renderMessage(data)
+}
+
+// BlockKitBuilderLink returns a Block Kit Builder URL that previews the blocks
+// of msg.
+func BlockKitBuilderLink(msg slack.Msg) (string, error) {
+	bs, err := json.Marshal(struct {
+		Blocks slack.Blocks `json:"blocks"`
+	}{msg.Blocks})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal blocks: %w", err)
+	}
+	return BlockKitBuilderURL + "#" + url.PathEscape(string(bs)), nil
+}
+
+// Block Kit limits, see https://api.slack.com/reference/block-kit/blocks
+const (
+	maxMessageBlocks     = 50
+	maxMessageText       = 40000
+	maxBlockIDLength     = 255
+	maxSectionText       = 3000
+	maxSectionFields     = 10
+	maxSectionFieldText  = 2000
+	maxHeaderText        = 150
+	maxContextElements   = 10
+	maxActionsElements   = 25
+	maxURLLength         = 3000
+	maxAltTextLength     = 2000
+	maxActionIDLength    = 255
+	maxButtonText        = 75
+	maxButtonValueLength = 2000
+)
+
+// ValidateBlockKit checks msg against the Block Kit limits that Slack
+// enforces when posting, and checks that the feedback buttons use the action
+// IDs the Handler responds to. All violations are returned joined.
+func ValidateBlockKit(msg slack.Msg) error {
+	bs, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message: %w", err)
+	}
+	var m struct {
+		Text   string           `json:"text"`
+		Blocks []map[string]any `json:"blocks"`
+	}
+	if err := json.Unmarshal(bs, &m); err != nil {
+		return fmt.Errorf("failed to unmarshal message: %w", err)
+	}
+	v := &blockKitValidator{}
+	v.maxLength("text", m.Text, maxMessageText)
+	if len(m.Text) == 0 && len(m.Blocks) == 0 && len(msg.Attachments) == 0 {
+		v.errorf("message", "has no text, blocks or attachments")
+	}
+	if len(m.Blocks) > maxMessageBlocks {
+		v.errorf("blocks", "must have at most %d blocks (got %d)", maxMessageBlocks, len(m.Blocks))
+	}
+	blockIDs := make(map[string]bool)
+	for i, b := range m.Blocks {
+		path := fmt.Sprintf("blocks[%d]", i)
+		if id := stringField(b, "block_id"); id != "" {
+			v.maxLength(path+".block_id", id, maxBlockIDLength)
+			if blockIDs[id] {
+				v.errorf(path+".block_id", "is duplicated: %q", id)
+			}
+			blockIDs[id] = true
+		}
+		v.block(path, b)
+	}
+	return errors.Join(v.errs...)
+}
+
+type blockKitValidator struct {
+	errs []error
+}
+
+func (v *blockKitValidator) errorf(path string, format string, args ...any) {
+	v.errs = append(v.errs, fmt.Errorf("%s: %s", path, fmt.Sprintf(format, args...)))
+}
+
+func (v *blockKitValidator) maxLength(path string, s string, limit int) {
+	if n := utf8.RuneCountInString(s); n > limit {
+		v.errorf(path, "must be at most %d characters (got %d)", limit, n)
+	}
+}
+
+func (v *blockKitValidator) block(path string, b map[string]any) {
+	switch stringField(b, "type") {
+	case "section":
+		text := textField(b, "text")
+		fields, _ := b["fields"].([]any)
+		if text == "" && len(fields) == 0 {
+			v.errorf(path, "section needs text or fields")
+		}
+		v.maxLength(path+".text", text, maxSectionText)
+		if len(fields) > maxSectionFields {
+			v.errorf(path+".fields", "must have at most %d fields (got %d)", maxSectionFields, len(fields))
+		}
+		for i, f := range fields {
+			if f, ok := f.(map[string]any); ok {
+				v.maxLength(fmt.Sprintf("%s.fields[%d]", path, i), stringField(f, "text"), maxSectionFieldText)
+			}
+		}
+		if accessory, ok := b["accessory"].(map[string]any); ok {
+			v.element(path+".accessory", stringField(b, "block_id"), accessory, make(map[string]bool))
+		}
+	case "header":
+		v.maxLength(path+".text", textField(b, "text"), maxHeaderText)
+	case "context":
+		elements, _ := b["elements"].([]any)
+		if len(elements) > maxContextElements {
+			v.errorf(path+".elements", "must have at most %d elements (got %d)", maxContextElements, len(elements))
+		}
+	case "actions":
+		elements, _ := b["elements"].([]any)
+		if len(elements) > maxActionsElements {
+			v.errorf(path+".elements", "must have at most %d elements (got %d)", maxActionsElements, len(elements))
+		}
+		v.elements(path, stringField(b, "block_id"), elements)
+	case "image":
+		v.maxLength(path+".image_url", stringField(b, "image_url"), maxURLLength)
+		v.maxLength(path+".alt_text", stringField(b, "alt_text"), maxAltTextLength)
+	}
+}
+
+var feedbackActionIDs = []string{actionsYesID, actionsNoID, actionsPlanedActivityID}
+
+func (v *blockKitValidator) elements(path string, blockID string, elements []any) {
+	actionIDs := make(map[string]bool)
+	for i, e := range elements {
+		if e, ok := e.(map[string]any); ok {
+			v.element(fmt.Sprintf("%s.elements[%d]", path, i), blockID, e, actionIDs)
+		}
+	}
+}
+
+func (v *blockKitValidator) element(path string, blockID string, e map[string]any, actionIDs map[string]bool) {
+	if id := stringField(e, "action_id"); id != "" {
+		v.maxLength(path+".action_id", id, maxActionIDLength)
+		if actionIDs[id] {
+			v.errorf(path+".action_id", "is duplicated in the block: %q", id)
+		}
+		actionIDs[id] = true
+		if blockID == actionsBlockID && !slices.Contains(feedbackActionIDs, id) {
+			v.errorf(path+".action_id", "must be one of %v in the feedback block (got %q)", feedbackActionIDs, id)
+		}
+	}
+	if stringField(e, "type") == "button" {
+		v.maxLength(path+".text", textField(e, "text"), maxButtonText)
+		v.maxLength(path+".value", stringField(e, "value"), maxButtonValueLength)
+		v.maxLength(path+".url", stringField(e, "url"), maxURLLength)
+	}
+}
+
+func stringField(m map[string]any, key string) string {
+	s, _ := m[key].(string)
+	return s
+}
+
+// textField returns the text of the text object m[key].
+func textField(m map[string]any, key string) string {
+	obj, _ := m[key].(map[string]any)
+	return stringField(obj, "text")
+}
@@ -0,0 +1,51 @@
+package reactor
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderMessage(t *testing.T) {
+	a := loadTestAnomaly(t, "testdata/anomaly.json")
+	msg, err := RenderMessage(context.Background(), "", a)
+	require.NoError(t, err)
+	require.Len(t, msg.Blocks.BlockSet, 7)
+	require.NoError(t, ValidateBlockKit(msg))
+
+	link, err := BlockKitBuilderLink(msg)
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(link, BlockKitBuilderURL+"#"))
+	blocks, err := url.PathUnescape(strings.TrimPrefix(link, BlockKitBuilderURL+"#"))
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(blocks, `{"blocks":[{"type":"section"`))
+
+	_, err = RenderMessage(context.Background(), `{"blocks": [{{ .Unknown }}]}`, a)
+	require.ErrorContains(t, err, "can't evaluate field Unknown")
+}
+
+func TestValidateBlockKit(t *testing.T) {
+	a := loadTestAnomaly(t, "testdata/anomaly.json")
+	tpl := `{"blocks": [
+		{"type": "header", "block_id": "title", "text": {"type": "plain_text", "text": "` + strings.Repeat("x", 151) + `"}},
+		{"type": "section", "block_id": "title", "text": {"type": "mrkdwn", "text": "{{ .Anomaly.AnomalyID }}"}},
+		{"type": "actions", "block_id": "{{ .ActionsBlockID }}", "elements": [
+			{"type": "button", "text": {"type": "plain_text", "text": "OK"}, "value": "{{ .ActionsYesValue }}", "action_id": "{{ .ActionsYesID }}"},
+			{"type": "button", "text": {"type": "plain_text", "text": "OK"}, "value": "{{ .ActionsYesValue }}", "action_id": "{{ .ActionsYesID }}"},
+			{"type": "button", "text": {"type": "plain_text", "text": "Ignore"}, "value": "ignore", "action_id": "ignore"}
+		]}
+	]}`
+	msg, err := RenderMessage(context.Background(), tpl, a)
+	require.NoError(t, err)
+	err = ValidateBlockKit(msg)
+	require.Error(t, err)
+	require.Equal(t, strings.Join([]string{
+		"blocks[0].text: must be at most 150 characters (got 151)",
+		`blocks[1].block_id: is duplicated: "title"`,
+		`blocks[2].elements[1].action_id: is duplicated in the block: "yes"`,
+		`blocks[2].elements[2].action_id: must be one of [yes no planed_activity] in the feedback block (got "ignore")`,
+	}, "\n"), err.Error())
+}
@@ -0,0 +1,48 @@
+package reactor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ParseAnomalyNotification decodes an anomaly from an Amazon SNS
+// notification envelope, or from the raw anomaly JSON as delivered with raw
+// message delivery.
+func ParseAnomalyNotification(payload []byte) (Anomaly, error) {
+	var n httpNotification
+	if err := json.Unmarshal(payload, &n); err != nil {
+		return Anomaly{}, fmt.Errorf("failed to decode notification: %w", err)
+	}
+	msg := payload
+	if n.Type != "" {
+		if n.Type != "Notification" {
+			return Anomaly{}, fmt.Errorf("unsupported notification type: %s", n.Type)
+		}
+		msg = []byte(n.Message)
+	}
+	var a Anomaly
+	if err := json.Unmarshal(msg, &a); err != nil {
+		return Anomaly{}, fmt.Errorf("failed to decode anomaly: %w", err)
+	}
+	if a.AnomalyID == "" {
+		return Anomaly{}, errors.New("anomaly id is missing")
+	}
+	return a, nil
+}
+
+// Replay posts the anomaly of a saved notification like a notification
+// received on /amazon-sns, and returns the anomaly. Like Backfill, the anomaly
+// is only posted: it is not escalated, sent to the webhooks or reminded.
+func (h *Handler) Replay(ctx context.Context, payload []byte) (Anomaly, error) {
+	a, err := ParseAnomalyNotification(payload)
+	if err != nil {
+		return Anomaly{}, err
+	}
+	h.logger.InfoContext(ctx, "replay anomaly", "anomaly_id", a.AnomalyID)
+	if err := h.postAnomalyOnly(ctx, a); err != nil {
+		return a, err
+	}
+	return a, nil
+}
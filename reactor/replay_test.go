@@ -0,0 +1,69 @@
+package reactor
+
+import (
+	"context"
+	"encoding/json"
+	"image/png"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAnomalyNotification(t *testing.T) {
+	raw, err := os.ReadFile("testdata/anomaly.json")
+	require.NoError(t, err)
+	want := loadTestAnomaly(t, "testdata/anomaly.json")
+
+	a, err := ParseAnomalyNotification(raw)
+	require.NoError(t, err)
+	require.Equal(t, want, a)
+
+	envelope, err := json.Marshal(httpNotification{
+		Type:      "Notification",
+		MessageId: "message-1",
+		TopicArn:  "arn:aws:sns:us-east-1:123456789012:cost-anomaly",
+		Message:   string(raw),
+	})
+	require.NoError(t, err)
+	a, err = ParseAnomalyNotification(envelope)
+	require.NoError(t, err)
+	require.Equal(t, want, a)
+
+	_, err = ParseAnomalyNotification([]byte(`{"Type": "SubscriptionConfirmation"}`))
+	require.ErrorContains(t, err, "unsupported notification type")
+	_, err = ParseAnomalyNotification([]byte(`{}`))
+	require.ErrorContains(t, err, "anomaly id is missing")
+}
+
+func TestHandlerReplayDryRun(t *testing.T) {
+	raw, err := os.ReadFile("testdata/anomaly.json")
+	require.NoError(t, err)
+	tpl, err := parseTemplate("default", defaultTemplate)
+	require.NoError(t, err)
+	dir := filepath.Join(t.TempDir(), "replay")
+	h := &Handler{
+		notifiers:      []Notifier{NewFileNotifier(dir, tpl)},
+		logger:         slog.Default(),
+		graphGenerator: newTestGraphGenerator(t),
+	}
+	a, err := h.Replay(context.Background(), raw)
+	require.NoError(t, err)
+	require.Equal(t, "12345678-abcd-ef12-3456-987654321a12", a.AnomalyID)
+
+	bs, err := os.ReadFile(filepath.Join(dir, "anomaly-12345678-abcd-ef12-3456-987654321a12.json"))
+	require.NoError(t, err)
+	var msg struct {
+		Blocks []map[string]any `json:"blocks"`
+	}
+	require.NoError(t, json.Unmarshal(bs, &msg))
+	require.Len(t, msg.Blocks, 7)
+
+	f, err := os.Open(filepath.Join(dir, "anomaly-12345678-abcd-ef12-3456-987654321a12-root-cause1.png"))
+	require.NoError(t, err)
+	defer f.Close()
+	_, err = png.Decode(f)
+	require.NoError(t, err)
+}
@@ -0,0 +1,167 @@
+package reactor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"time"
+
+	"github.com/Songmu/flextime"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer/types"
+	"github.com/aws/smithy-go"
+	"github.com/slack-go/slack"
+)
+
+// RetryPolicy describes how outbound calls to Slack and AWS are retried when
+// they are throttled. The zero value performs a single attempt without retry.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first one.
+	MaxAttempts int
+	// BaseDelay is the backoff delay before the second attempt. It doubles
+	// on every subsequent attempt.
+	BaseDelay time.Duration
+	// MaxDelay caps the computed backoff delay. A Retry-After duration
+	// returned by Slack or an HTTP API is always honoured, even when it
+	// exceeds MaxDelay.
+	MaxDelay time.Duration
+	// Jitter is the fraction (0.0-1.0) of the backoff delay that is
+	// randomised to avoid synchronised retries.
+	Jitter float64
+
+	logger        *slog.Logger
+	throttledOnly bool
+}
+
+// DefaultRetryPolicy is the RetryPolicy used by New when WithRetryPolicy is
+// not given.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 5,
+	BaseDelay:   500 * time.Millisecond,
+	MaxDelay:    30 * time.Second,
+	Jitter:      0.5,
+}
+
+// withLogger returns a copy of p that logs retries to logger.
+func (p RetryPolicy) withLogger(logger *slog.Logger) RetryPolicy {
+	p.logger = logger
+	return p
+}
+
+// onlyThrottled returns a copy of p that retries rate limited errors only.
+// It is used for calls that create something, such as chat.postMessage,
+// where a 5xx response does not tell whether it was created.
+func (p RetryPolicy) onlyThrottled() RetryPolicy {
+	p.throttledOnly = true
+	return p
+}
+
+// withoutSDKRetry disables the retryer of the AWS SDK for a call wrapped in
+// RetryPolicy.Do, so that a throttled call is not retried by both of them.
+func withoutSDKRetry(o *costexplorer.Options) {
+	o.Retryer = retry.AddWithMaxAttempts(o.Retryer, 1)
+}
+
+// Do calls fn until it succeeds, returns a non-retryable error, the attempts
+// are exhausted or ctx is done. The last error is returned.
+func (p RetryPolicy) Do(ctx context.Context, fn func(context.Context) error) error {
+	maxAttempts := p.MaxAttempts
+	if maxAttempts < 1 {
+		maxAttempts = 1
+	}
+	logger := p.logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = fn(ctx)
+		if err == nil {
+			return nil
+		}
+		delay, retryable := p.retryDelay(err, attempt)
+		if !retryable {
+			return err
+		}
+		if attempt >= maxAttempts {
+			if attempt == 1 {
+				return err
+			}
+			return fmt.Errorf("giving up after %d attempts: %w", attempt, err)
+		}
+		logger.WarnContext(ctx, "retry after throttled", "attempt", attempt, "delay", delay, "error", err)
+		timer := flextime.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return errors.Join(err, ctx.Err())
+		case <-timer.C:
+		}
+	}
+}
+
+func (p RetryPolicy) retryDelay(err error, attempt int) (time.Duration, bool) {
+	var rateLimited *slack.RateLimitedError
+	if errors.As(err, &rateLimited) {
+		if rateLimited.RetryAfter > 0 {
+			return rateLimited.RetryAfter, true
+		}
+		return p.backoff(attempt), true
+	}
+	var statusErr *httpStatusError
+	if errors.As(err, &statusErr) {
+		if !statusErr.retryable() || (p.throttledOnly && !statusErr.throttled()) {
+			return 0, false
+		}
+		if statusErr.RetryAfter > 0 {
+			return statusErr.RetryAfter, true
+		}
+		return p.backoff(attempt), true
+	}
+	if p.throttledOnly || !isRetryableError(err) {
+		return 0, false
+	}
+	return p.backoff(attempt), true
+}
+
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	delay := p.BaseDelay
+	for i := 1; i < attempt && (p.MaxDelay <= 0 || delay < p.MaxDelay); i++ {
+		delay *= 2
+	}
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	if p.Jitter > 0 && delay > 0 {
+		jitter := time.Duration(float64(delay) * min(p.Jitter, 1.0))
+		delay = delay - jitter + rand.N(jitter+1)
+	}
+	return delay
+}
+
+var retryableAPIErrorCodes = map[string]struct{}{
+	"LimitExceededException":   {},
+	"ThrottlingException":      {},
+	"TooManyRequestsException": {},
+	"RequestLimitExceeded":     {},
+}
+
+func isRetryableError(err error) bool {
+	var limitExceeded *types.LimitExceededException
+	if errors.As(err, &limitExceeded) {
+		return true
+	}
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		_, ok := retryableAPIErrorCodes[apiErr.ErrorCode()]
+		return ok
+	}
+	var statusErr slack.StatusCodeError
+	if errors.As(err, &statusErr) {
+		return statusErr.Code >= 500
+	}
+	return false
+}
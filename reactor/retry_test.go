@@ -0,0 +1,203 @@
+package reactor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Songmu/flextime"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer/types"
+	"github.com/slack-go/slack"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryPolicyDo(t *testing.T) {
+	policy := RetryPolicy{
+		MaxAttempts: 4,
+		BaseDelay:   time.Second,
+		MaxDelay:    5 * time.Second,
+	}
+	cases := []struct {
+		name         string
+		errs         []error
+		wantErr      bool
+		wantAttempts int
+		wantElapsed  time.Duration
+	}{
+		{
+			name:         "success",
+			errs:         []error{nil},
+			wantAttempts: 1,
+		},
+		{
+			name: "slack retry after",
+			errs: []error{
+				&slack.RateLimitedError{RetryAfter: 7 * time.Second},
+				&slack.RateLimitedError{RetryAfter: 3 * time.Second},
+				nil,
+			},
+			wantAttempts: 3,
+			wantElapsed:  10 * time.Second,
+		},
+		{
+			name: "cost explorer limit exceeded with exponential backoff",
+			errs: []error{
+				&types.LimitExceededException{},
+				&types.LimitExceededException{},
+				&types.LimitExceededException{},
+				nil,
+			},
+			wantAttempts: 4,
+			wantElapsed:  (1 + 2 + 4) * time.Second,
+		},
+		{
+			name: "giving up",
+			errs: []error{
+				slack.StatusCodeError{Code: 503},
+				slack.StatusCodeError{Code: 503},
+				slack.StatusCodeError{Code: 503},
+				slack.StatusCodeError{Code: 503},
+			},
+			wantErr:      true,
+			wantAttempts: 4,
+			wantElapsed:  (1 + 2 + 4) * time.Second,
+		},
+		{
+			name:         "not retryable",
+			errs:         []error{errors.New("channel_not_found")},
+			wantErr:      true,
+			wantAttempts: 1,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+			restore := flextime.Fix(start)
+			defer restore()
+			var attempts int
+			err := policy.Do(context.Background(), func(_ context.Context) error {
+				err := c.errs[attempts]
+				attempts++
+				return err
+			})
+			if c.wantErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+			require.Equal(t, c.wantAttempts, attempts)
+			require.Equal(t, c.wantElapsed, flextime.Since(start))
+		})
+	}
+}
+
+func TestRetryPolicyBackoffJitter(t *testing.T) {
+	policy := RetryPolicy{
+		BaseDelay: time.Second,
+		MaxDelay:  10 * time.Second,
+		Jitter:    0.5,
+	}
+	for attempt := 1; attempt <= 6; attempt++ {
+		want := min(time.Second<<(attempt-1), 10*time.Second)
+		for range 100 {
+			d := policy.backoff(attempt)
+			require.GreaterOrEqual(t, d, want/2)
+			require.LessOrEqual(t, d, want)
+		}
+	}
+}
+
+func TestRetryPolicyDoCanceled(t *testing.T) {
+	policy := RetryPolicy{
+		MaxAttempts: 3,
+		BaseDelay:   time.Hour,
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	var attempts int
+	err := policy.Do(ctx, func(_ context.Context) error {
+		attempts++
+		cancel()
+		return &types.LimitExceededException{}
+	})
+	require.ErrorIs(t, err, context.Canceled)
+	require.Equal(t, 1, attempts)
+}
+
+func TestRetryPolicyOnlyThrottled(t *testing.T) {
+	restore := flextime.Fix(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	defer restore()
+	policy := RetryPolicy{
+		MaxAttempts: 3,
+		BaseDelay:   time.Second,
+	}.onlyThrottled()
+
+	var attempts int
+	err := policy.Do(context.Background(), func(_ context.Context) error {
+		attempts++
+		return slack.StatusCodeError{Code: 503}
+	})
+	require.Error(t, err)
+	require.Equal(t, 1, attempts, "5xx must not be retried")
+
+	attempts = 0
+	err = policy.Do(context.Background(), func(_ context.Context) error {
+		attempts++
+		return &httpStatusError{StatusCode: http.StatusBadGateway}
+	})
+	require.Error(t, err)
+	require.Equal(t, 1, attempts, "5xx must not be retried")
+
+	attempts = 0
+	err = policy.Do(context.Background(), func(_ context.Context) error {
+		attempts++
+		if attempts == 1 {
+			return &httpStatusError{StatusCode: http.StatusTooManyRequests}
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, 2, attempts)
+
+	attempts = 0
+	err = policy.Do(context.Background(), func(_ context.Context) error {
+		attempts++
+		if attempts == 1 {
+			return &slack.RateLimitedError{RetryAfter: time.Second}
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, 2, attempts)
+}
+
+func TestRetryPolicyWithLogger(t *testing.T) {
+	restore := flextime.Fix(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	defer restore()
+	var buf bytes.Buffer
+	policy := RetryPolicy{
+		MaxAttempts: 2,
+		BaseDelay:   time.Second,
+	}.withLogger(slog.New(slog.NewTextHandler(&buf, nil)))
+	var attempts int
+	err := policy.Do(context.Background(), func(_ context.Context) error {
+		attempts++
+		if attempts == 1 {
+			return &types.LimitExceededException{}
+		}
+		return nil
+	})
+	require.NoError(t, err)
+	require.Contains(t, buf.String(), "retry after throttled")
+}
+
+func TestWithoutSDKRetry(t *testing.T) {
+	o := costexplorer.Options{Retryer: retry.NewStandard()}
+	withoutSDKRetry(&o)
+	require.Equal(t, 1, o.Retryer.MaxAttempts())
+}
@@ -0,0 +1,179 @@
+package reactor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"text/template"
+
+	"github.com/slack-go/slack"
+)
+
+// SlackNotifier is the Notifier that posts anomaly messages rendered from a
+// Block Kit JSON template to a Slack channel.
+type SlackNotifier struct {
+	client      SlackAPIClient
+	teamID      string
+	channel     string
+	tpl         *template.Template
+	retryPolicy RetryPolicy
+}
+
+var _ Notifier = (*SlackNotifier)(nil)
+
+// NewSlackNotifier returns a SlackNotifier posting to channel of the Slack
+// team teamID with the given message template. Every Slack API call is
+// retried according to policy.
+func NewSlackNotifier(client SlackAPIClient, teamID string, channel string, tpl *template.Template, policy RetryPolicy) *SlackNotifier {
+	return &SlackNotifier{
+		client:      client,
+		teamID:      teamID,
+		channel:     channel,
+		tpl:         tpl,
+		retryPolicy: policy,
+	}
+}
+
+// ID implements Notifier. It returns the Slack team ID.
+func (n *SlackNotifier) ID() string {
+	return n.teamID
+}
+
+// Channel returns the Slack channel anomaly messages are posted to.
+func (n *SlackNotifier) Channel() string {
+	return n.channel
+}
+
+// PostAnomaly implements Notifier. The returned thread is the message
+// timestamp.
+func (n *SlackNotifier) PostAnomaly(ctx context.Context, data TemplateData) (string, error) {
+	opts, err := n.newDetectAnomalyMessageOptions(data)
+	if err != nil {
+		return "", fmt.Errorf("failed to create message: %w", err)
+	}
+	return n.postMessage(ctx, n.channel, opts...)
+}
+
+// UpdateAnomaly implements Notifier.
+func (n *SlackNotifier) UpdateAnomaly(ctx context.Context, thread string, data TemplateData) error {
+	opts, err := n.newDetectAnomalyMessageOptions(data)
+	if err != nil {
+		return fmt.Errorf("failed to create message: %w", err)
+	}
+	return n.retryPolicy.Do(ctx, func(ctx context.Context) error {
+		_, _, _, err := n.client.UpdateMessageContext(ctx, n.channel, thread, opts...)
+		return countSlackAPIError("chat.update", err)
+	})
+}
+
+// PostThreadReply implements Notifier.
+func (n *SlackNotifier) PostThreadReply(ctx context.Context, thread string, text string) error {
+	_, err := n.postMessage(ctx, n.channel, slack.MsgOptionTS(thread), slack.MsgOptionText(text, false))
+	return err
+}
+
+// UploadImage implements Notifier. The permalink is looked up with
+// files.info, which needs the files:read scope; without it the upload still
+// succeeds and "" is returned.
+func (n *SlackNotifier) UploadImage(ctx context.Context, thread string, name string, g *Graph) (string, error) {
+	bs, err := io.ReadAll(g.NewReader())
+	if err != nil {
+		return "", fmt.Errorf("failed to read graph: %w", err)
+	}
+	var summary *slack.FileSummary
+	err = n.retryPolicy.onlyThrottled().Do(ctx, func(ctx context.Context) error {
+		var err error
+		summary, err = n.client.UploadFileContext(ctx, slack.UploadFileParameters{
+			Reader:          bytes.NewReader(bs),
+			Filename:        name,
+			FileSize:        len(bs),
+			Channel:         n.channel,
+			ThreadTimestamp: thread,
+		})
+		return countSlackAPIError("files.upload", err)
+	})
+	if err != nil {
+		return "", err
+	}
+	var file *slack.File
+	err = n.retryPolicy.Do(ctx, func(ctx context.Context) error {
+		var err error
+		file, _, _, err = n.client.GetFileInfoContext(ctx, summary.ID, 0, 0)
+		return countSlackAPIError("files.info", err)
+	})
+	if err != nil {
+		slog.WarnContext(ctx, "failed to get file permalink", "file_id", summary.ID, "error", err)
+		return "", nil
+	}
+	return file.Permalink, nil
+}
+
+// PostMessage implements Notifier.
+func (n *SlackNotifier) PostMessage(ctx context.Context, text string) error {
+	_, err := n.postMessage(ctx, n.channel, slack.MsgOptionText(text, false))
+	return err
+}
+
+func (n *SlackNotifier) postMessage(ctx context.Context, channel string, options ...slack.MsgOption) (string, error) {
+	var ts string
+	err := n.retryPolicy.onlyThrottled().Do(ctx, func(ctx context.Context) error {
+		var err error
+		_, ts, err = n.client.PostMessageContext(ctx, channel, options...)
+		return countSlackAPIError("chat.postMessage", err)
+	})
+	return ts, err
+}
+
+func (n *SlackNotifier) postEphemeral(ctx context.Context, channel string, user string, options ...slack.MsgOption) error {
+	return n.retryPolicy.Do(ctx, func(ctx context.Context) error {
+		_, err := n.client.PostEphemeralContext(ctx, channel, user, options...)
+		return countSlackAPIError("chat.postEphemeral", err)
+	})
+}
+
+func (n *SlackNotifier) userGroupMembers(ctx context.Context, userGroup string) ([]string, error) {
+	var members []string
+	err := n.retryPolicy.Do(ctx, func(ctx context.Context) error {
+		var err error
+		members, err = n.client.GetUserGroupMembersContext(ctx, userGroup)
+		return countSlackAPIError("usergroups.users.list", err)
+	})
+	return members, err
+}
+
+// renderMessage executes the template with data and decodes the result as a
+// Slack message.
+func (n *SlackNotifier) renderMessage(data TemplateData) (slack.Msg, error) {
+	var buf bytes.Buffer
+	if err := n.tpl.Execute(&buf, data); err != nil {
+		return slack.Msg{}, fmt.Errorf("failed to execute template: %w", err)
+	}
+	var msg slack.Msg
+	dec := json.NewDecoder(&buf)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&msg); err != nil {
+		return slack.Msg{}, fmt.Errorf("failed to decode template: %w", err)
+	}
+	return msg, nil
+}
+
+func (n *SlackNotifier) newDetectAnomalyMessageOptions(data TemplateData) ([]slack.MsgOption, error) {
+	msg, err := n.renderMessage(data)
+	if err != nil {
+		return nil, err
+	}
+	opts := make([]slack.MsgOption, 0, 3)
+	if msg.Text != "" {
+		opts = append(opts, slack.MsgOptionText(msg.Text, false))
+	}
+	if len(msg.Attachments) > 0 {
+		opts = append(opts, slack.MsgOptionAttachments(msg.Attachments...))
+	}
+	if len(msg.Blocks.BlockSet) > 0 {
+		opts = append(opts, slack.MsgOptionBlocks(msg.Blocks.BlockSet...))
+	}
+	return opts, nil
+}
@@ -0,0 +1,477 @@
+package reactor
+
+import (
+	"cmp"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Songmu/flextime"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	ddbtypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	ssmtypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"github.com/slack-go/slack"
+)
+
+const (
+	// slackInstallationStateID is the hash key of the SlackInstallation
+	// items.
+	slackInstallationStateID = "slack-installation"
+	// slackOAuthStateTTL is how long an install link stays valid.
+	slackOAuthStateTTL = 10 * time.Minute
+	slackAuthorizeURL  = "https://slack.com/oauth/v2/authorize"
+)
+
+// slackWorkspacesTTL is how long the installed workspaces are cached.
+var slackWorkspacesTTL = 5 * time.Minute
+
+// DefaultSlackTokenParameterPrefix is the path of the SSM parameters that
+// hold the bot tokens of the installed workspaces.
+const DefaultSlackTokenParameterPrefix = "/aws-cost-anomaly-slack-reactor/slack-installations"
+
+// SSMAPIClient is the subset of the SSM client used to keep the bot tokens
+// of the installed workspaces.
+type SSMAPIClient interface {
+	PutParameter(ctx context.Context, params *ssm.PutParameterInput, optFns ...func(*ssm.Options)) (*ssm.PutParameterOutput, error)
+	GetParameter(ctx context.Context, params *ssm.GetParameterInput, optFns ...func(*ssm.Options)) (*ssm.GetParameterOutput, error)
+}
+
+var _ SSMAPIClient = (*ssm.Client)(nil)
+
+// DefaultSlackOAuthScopes are the bot scopes requested when a workspace
+// installs the app. incoming-webhook lets the installer pick the channel
+// anomalies are posted to.
+var DefaultSlackOAuthScopes = []string{
+	"app_mentions:read",
+	"chat:write",
+	"files:read",
+	"files:write",
+	"incoming-webhook",
+	"usergroups:read",
+}
+
+// SlackOAuthConfig enables installing the Slack app into other workspaces
+// with OAuth. Every installed workspace receives the anomalies in the
+// channel chosen at installation, in addition to the workspace of the bot
+// token.
+type SlackOAuthConfig struct {
+	ClientID     string `json:"clientId,omitempty"`
+	ClientSecret string `json:"clientSecret,omitempty"`
+	// RedirectURL is the /slack/oauth endpoint of the reactor, as registered
+	// in the Redirect URLs of the app.
+	RedirectURL string `json:"redirectUrl,omitempty"`
+	// Scopes defaults to DefaultSlackOAuthScopes.
+	Scopes []string `json:"scopes,omitempty"`
+	// AllowedTeams are the team IDs (T0123ABCDE) and Enterprise Grid
+	// organization IDs (E0123ABCDE) allowed to install the app. Installations
+	// into any other workspace are rejected before the token is stored.
+	AllowedTeams []string `json:"allowedTeams,omitempty"`
+	// TokenParameterPrefix is the path of the SecureString SSM parameters
+	// the bot tokens are stored in. It defaults to
+	// DefaultSlackTokenParameterPrefix.
+	TokenParameterPrefix string `json:"tokenParameterPrefix,omitempty"`
+	// TokenKMSKeyID is the KMS key that encrypts the bot tokens. The AWS
+	// managed key of SSM is used when empty.
+	TokenKMSKeyID string `json:"tokenKmsKeyId,omitempty"`
+	// APIURL overrides the Slack Web API URL used for the OAuth exchange and
+	// the installed workspaces. It is meant for tests against a local
+	// stand-in.
+	APIURL string `json:"apiUrl,omitempty"`
+}
+
+// Enabled reports whether OAuth installation is configured.
+func (cfg SlackOAuthConfig) Enabled() bool {
+	return cfg.ClientID != ""
+}
+
+func (cfg SlackOAuthConfig) validate() error {
+	if cfg.ClientSecret == "" {
+		return errors.New("slack oauth client secret is required")
+	}
+	if cfg.RedirectURL == "" {
+		return errors.New("slack oauth redirect url is required")
+	}
+	if len(cfg.AllowedTeams) == 0 {
+		return errors.New("slack oauth allowed teams are required")
+	}
+	return nil
+}
+
+// allows reports whether the workspace of the team, or of the Enterprise
+// Grid organization, may install the app.
+func (cfg SlackOAuthConfig) allows(teamID string, enterpriseID string) bool {
+	for _, id := range cfg.AllowedTeams {
+		id = strings.TrimSpace(id)
+		if id == "" {
+			continue
+		}
+		if id == teamID || id == enterpriseID {
+			return true
+		}
+	}
+	return false
+}
+
+func (cfg SlackOAuthConfig) scopes() []string {
+	if len(cfg.Scopes) == 0 {
+		return DefaultSlackOAuthScopes
+	}
+	return cfg.Scopes
+}
+
+// tokenParameterName returns the name of the SSM parameter that holds the
+// bot token of the team.
+func (cfg SlackOAuthConfig) tokenParameterName(teamID string) string {
+	prefix := cmp.Or(cfg.TokenParameterPrefix, DefaultSlackTokenParameterPrefix)
+	return strings.TrimSuffix(prefix, "/") + "/" + teamID
+}
+
+func (cfg SlackOAuthConfig) newClient(token string) *slack.Client {
+	opts := []slack.Option{slack.OptionHTTPClient(newSlackHTTPClient())}
+	if cfg.APIURL != "" {
+		opts = append(opts, slack.OptionAPIURL(cfg.APIURL))
+	}
+	return slack.New(token, opts...)
+}
+
+// SlackInstallation is the DynamoDB record of a workspace that installed the
+// app with OAuth. It shares the table of AnomalySlackMessage with the fixed
+// hash key "slack-installation" and the team ID as the range key. The bot
+// token is not stored in the table but in the SecureString SSM parameter
+// named by BotTokenParameter.
+type SlackInstallation struct {
+	AnomalyID         string
+	SlackTeamID       string
+	TeamName          string
+	EnterpriseID      string `dynamodbav:",omitempty"`
+	BotUserID         string
+	BotToken          string `dynamodbav:"-"`
+	BotTokenParameter string
+	Channel           string
+	InstalledBy       string
+	InstalledAt       int64
+}
+
+// SaveSlackInstallation stores the installation of a workspace, replacing
+// the previous one of the team. The bot token is put in the SSM parameter
+// first, so that the record never refers to a missing token.
+func (h *Handler) SaveSlackInstallation(ctx context.Context, inst *SlackInstallation) error {
+	name := h.slackOAuth.tokenParameterName(inst.SlackTeamID)
+	input := &ssm.PutParameterInput{
+		Name:        aws.String(name),
+		Value:       aws.String(inst.BotToken),
+		Type:        ssmtypes.ParameterTypeSecureString,
+		Overwrite:   aws.Bool(true),
+		Description: aws.String("Slack bot token of " + inst.SlackTeamID),
+	}
+	if h.slackOAuth.TokenKMSKeyID != "" {
+		input.KeyId = aws.String(h.slackOAuth.TokenKMSKeyID)
+	}
+	if _, err := h.ssm.PutParameter(ctx, input); err != nil {
+		return fmt.Errorf("failed to put bot token parameter %s: %w", name, err)
+	}
+	inst.AnomalyID = slackInstallationStateID
+	inst.BotTokenParameter = name
+	return h.putStateItem(ctx, inst)
+}
+
+// GetSlackInstallation looks up the installation of a workspace. The
+// boolean return is false when the team has not installed the app.
+func (h *Handler) GetSlackInstallation(ctx context.Context, teamID string) (*SlackInstallation, bool, error) {
+	var inst SlackInstallation
+	if ok, err := h.getStateItem(ctx, slackInstallationStateID, teamID, &inst); err != nil || !ok {
+		return nil, false, err
+	}
+	if inst.BotTokenParameter == "" {
+		return nil, false, nil
+	}
+	if err := h.loadSlackBotToken(ctx, &inst); err != nil {
+		return nil, false, err
+	}
+	return &inst, true, nil
+}
+
+// loadSlackBotToken reads the bot token of the installation from its SSM
+// parameter.
+func (h *Handler) loadSlackBotToken(ctx context.Context, inst *SlackInstallation) error {
+	out, err := h.ssm.GetParameter(ctx, &ssm.GetParameterInput{
+		Name:           aws.String(inst.BotTokenParameter),
+		WithDecryption: aws.Bool(true),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to get bot token parameter %s: %w", inst.BotTokenParameter, err)
+	}
+	if out.Parameter == nil || aws.ToString(out.Parameter.Value) == "" {
+		return fmt.Errorf("bot token parameter %s is empty", inst.BotTokenParameter)
+	}
+	inst.BotToken = aws.ToString(out.Parameter.Value)
+	return nil
+}
+
+// ListSlackInstallations returns the installations of all workspaces.
+func (h *Handler) ListSlackInstallations(ctx context.Context) ([]*SlackInstallation, error) {
+	paginator := dynamodb.NewScanPaginator(h.ddb, &dynamodb.ScanInput{
+		TableName:        aws.String(h.dynamodbTableName),
+		FilterExpression: aws.String("AnomalyID = :id"),
+		ExpressionAttributeValues: map[string]ddbtypes.AttributeValue{
+			":id": &ddbtypes.AttributeValueMemberS{Value: slackInstallationStateID},
+		},
+	})
+	var installations []*SlackInstallation
+	for paginator.HasMorePages() {
+		out, err := paginator.NextPage(ctx)
+		stateStoreOperations.WithLabelValues("scan", resultLabel(err)).Inc()
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan slack installations: %w", err)
+		}
+		for _, item := range out.Items {
+			var inst SlackInstallation
+			if err := attributevalue.UnmarshalMap(item, &inst); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal item: %w", err)
+			}
+			if inst.AnomalyID != slackInstallationStateID || inst.BotTokenParameter == "" {
+				continue
+			}
+			if err := h.loadSlackBotToken(ctx, &inst); err != nil {
+				h.logger.WarnContext(ctx, "failed to load slack installation", "slack_team_id", inst.SlackTeamID, "error", err)
+				continue
+			}
+			installations = append(installations, &inst)
+		}
+	}
+	return installations, nil
+}
+
+// allNotifiers returns the notifiers of the Handler followed by the Slack
+// notifiers of the installed workspaces. The installed workspaces are loaded
+// again once slackWorkspacesTTL has passed, so that installations completed
+// on other instances are picked up.
+func (h *Handler) allNotifiers(ctx context.Context) []Notifier {
+	h.refreshSlackWorkspaces(ctx)
+	h.workspacesMu.RLock()
+	defer h.workspacesMu.RUnlock()
+	notifiers := slices.Clip(h.notifiers)
+	for _, n := range h.workspaces {
+		notifiers = append(notifiers, n)
+	}
+	return notifiers
+}
+
+// newSlackWorkspaceNotifier returns the SlackNotifier of an installed
+// workspace.
+func (h *Handler) newSlackWorkspaceNotifier(inst *SlackInstallation) *SlackNotifier {
+	return NewSlackNotifier(h.slackOAuth.newClient(inst.BotToken), inst.SlackTeamID, inst.Channel, h.slack.tpl, h.retryPolicy)
+}
+
+// addSlackWorkspace posts the anomalies to the workspace of inst from now
+// on. The workspace of the bot token is already notified and is skipped.
+func (h *Handler) addSlackWorkspace(inst *SlackInstallation) *SlackNotifier {
+	if inst.SlackTeamID == h.slackTeamID {
+		return h.slack
+	}
+	n := h.newSlackWorkspaceNotifier(inst)
+	h.workspacesMu.Lock()
+	defer h.workspacesMu.Unlock()
+	i := slices.IndexFunc(h.workspaces, func(w *SlackNotifier) bool { return w.ID() == inst.SlackTeamID })
+	if i < 0 {
+		h.workspaces = append(h.workspaces, n)
+	} else {
+		h.workspaces[i] = n
+	}
+	return n
+}
+
+// loadSlackWorkspaces replaces the installed workspaces with the ones in the
+// state store.
+func (h *Handler) loadSlackWorkspaces(ctx context.Context) error {
+	installations, err := h.ListSlackInstallations(ctx)
+	if err != nil {
+		return err
+	}
+	workspaces := make([]*SlackNotifier, 0, len(installations))
+	for _, inst := range installations {
+		if inst.SlackTeamID == h.slackTeamID {
+			continue
+		}
+		workspaces = append(workspaces, h.newSlackWorkspaceNotifier(inst))
+	}
+	h.workspacesMu.Lock()
+	defer h.workspacesMu.Unlock()
+	h.workspaces = workspaces
+	h.workspacesLoaded = flextime.Now()
+	return nil
+}
+
+// refreshSlackWorkspaces loads the installed workspaces again when they were
+// loaded slackWorkspacesTTL ago or earlier. The loaded workspaces are kept
+// when it fails.
+func (h *Handler) refreshSlackWorkspaces(ctx context.Context) {
+	if !h.slackOAuth.Enabled() {
+		return
+	}
+	h.workspacesMu.RLock()
+	loadedAt := h.workspacesLoaded
+	h.workspacesMu.RUnlock()
+	if flextime.Since(loadedAt) < slackWorkspacesTTL {
+		return
+	}
+	if err := h.loadSlackWorkspaces(ctx); err != nil {
+		h.logger.WarnContext(ctx, "failed to refresh slack workspaces", "error", err)
+	}
+}
+
+// slackNotifierFor returns the SlackNotifier of the team an interaction or
+// event came from. The installation is looked up when the team was
+// installed after the workspaces were loaded, and the notifier of the bot
+// token is returned for an unknown team.
+func (h *Handler) slackNotifierFor(ctx context.Context, teamID string) *SlackNotifier {
+	if teamID == "" || teamID == h.slackTeamID {
+		return h.slack
+	}
+	h.workspacesMu.RLock()
+	i := slices.IndexFunc(h.workspaces, func(w *SlackNotifier) bool { return w.ID() == teamID })
+	var n *SlackNotifier
+	if i >= 0 {
+		n = h.workspaces[i]
+	}
+	h.workspacesMu.RUnlock()
+	if n != nil {
+		return n
+	}
+	if h.slackOAuth.Enabled() && h.EnableDynamoDB() {
+		inst, ok, err := h.GetSlackInstallation(ctx, teamID)
+		if err != nil {
+			h.logger.WarnContext(ctx, "failed to get slack installation", "slack_team_id", teamID, "error", err)
+		} else if ok {
+			return h.addSlackWorkspace(inst)
+		}
+	}
+	h.logger.WarnContext(ctx, "unknown slack team, using the bot token", "slack_team_id", teamID)
+	return h.slack
+}
+
+// newSlackOAuthState returns the state parameter of an install link: the
+// issue time signed with the client secret.
+func newSlackOAuthState(secret string, now time.Time) string {
+	return signSlackOAuthState(secret, strconv.FormatInt(now.Unix(), 10))
+}
+
+func signSlackOAuthState(secret string, ts string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts))
+	return ts + "." + hex.EncodeToString(mac.Sum(nil))
+}
+
+func verifySlackOAuthState(secret string, state string, now time.Time) error {
+	ts, _, ok := strings.Cut(state, ".")
+	if !ok {
+		return errors.New("malformed state")
+	}
+	if !hmac.Equal([]byte(state), []byte(signSlackOAuthState(secret, ts))) {
+		return errors.New("invalid state")
+	}
+	sec, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return errors.New("malformed state")
+	}
+	if now.Sub(time.Unix(sec, 0)) > slackOAuthStateTTL {
+		return errors.New("state expired")
+	}
+	return nil
+}
+
+// handleSlackInstall redirects to the Slack authorization page of the app.
+func (h *Handler) handleSlackInstall(w http.ResponseWriter, r *http.Request) {
+	cfg := h.slackOAuth
+	v := url.Values{
+		"client_id":    {cfg.ClientID},
+		"scope":        {strings.Join(cfg.scopes(), ",")},
+		"redirect_uri": {cfg.RedirectURL},
+		"state":        {newSlackOAuthState(cfg.ClientSecret, flextime.Now())},
+	}
+	http.Redirect(w, r, slackAuthorizeURL+"?"+v.Encode(), http.StatusFound)
+}
+
+// handleSlackOAuth completes an installation: it exchanges the code for the
+// bot token of the workspace, stores it and starts posting anomalies there.
+func (h *Handler) handleSlackOAuth(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	cfg := h.slackOAuth
+	q := r.URL.Query()
+	if e := q.Get("error"); e != "" {
+		h.logger.WarnContext(ctx, "slack installation canceled", "error", e)
+		http.Error(w, "installation canceled: "+e, http.StatusBadRequest)
+		return
+	}
+	if err := verifySlackOAuthState(cfg.ClientSecret, q.Get("state"), flextime.Now()); err != nil {
+		h.logger.WarnContext(ctx, "invalid slack oauth state", "error", err)
+		http.Error(w, "invalid state, please retry the installation", http.StatusBadRequest)
+		return
+	}
+	var opts []slack.OAuthOption
+	if cfg.APIURL != "" {
+		opts = append(opts, slack.OAuthOptionAPIURL(cfg.APIURL))
+	}
+	resp, err := slack.GetOAuthV2ResponseContext(ctx, newSlackHTTPClient(), cfg.ClientID, cfg.ClientSecret, q.Get("code"), cfg.RedirectURL, opts...)
+	if err != nil {
+		h.logger.ErrorContext(ctx, "failed to exchange slack oauth code", "error", err)
+		http.Error(w, "failed to install the app", http.StatusBadGateway)
+		return
+	}
+	if resp.IsEnterpriseInstall || resp.Team.ID == "" {
+		h.logger.WarnContext(ctx, "org wide slack installation is not supported", "enterprise_id", resp.Enterprise.ID)
+		http.Error(w, "org-wide installation is not supported, please install the app to each workspace", http.StatusBadRequest)
+		return
+	}
+	if !cfg.allows(resp.Team.ID, resp.Enterprise.ID) {
+		h.logger.WarnContext(ctx, "slack installation is not allowed",
+			"slack_team_id", resp.Team.ID,
+			"team", resp.Team.Name,
+			"enterprise_id", resp.Enterprise.ID,
+			"installed_by", resp.AuthedUser.ID,
+		)
+		http.Error(w, "this workspace is not allowed to install the app", http.StatusForbidden)
+		return
+	}
+	if resp.IncomingWebhook.ChannelID == "" {
+		http.Error(w, "no channel was chosen, please install the app with the incoming-webhook scope", http.StatusBadRequest)
+		return
+	}
+	inst := &SlackInstallation{
+		SlackTeamID:  resp.Team.ID,
+		TeamName:     resp.Team.Name,
+		EnterpriseID: resp.Enterprise.ID,
+		BotUserID:    resp.BotUserID,
+		BotToken:     resp.AccessToken,
+		Channel:      resp.IncomingWebhook.ChannelID,
+		InstalledBy:  resp.AuthedUser.ID,
+		InstalledAt:  flextime.Now().Unix(),
+	}
+	if err := h.SaveSlackInstallation(ctx, inst); err != nil {
+		h.logger.ErrorContext(ctx, "failed to save slack installation", "slack_team_id", inst.SlackTeamID, "error", err)
+		http.Error(w, "failed to install the app", http.StatusInternalServerError)
+		return
+	}
+	h.addSlackWorkspace(inst)
+	h.logger.InfoContext(ctx, "slack app installed",
+		"slack_team_id", inst.SlackTeamID,
+		"team", inst.TeamName,
+		"enterprise_id", inst.EnterpriseID,
+		"channel", inst.Channel,
+		"installed_by", inst.InstalledBy,
+	)
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintf(w, "Installed to %s. Cost anomalies will be posted to %s.\n", inst.TeamName, resp.IncomingWebhook.Channel)
+}
@@ -0,0 +1,272 @@
+package reactor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Songmu/flextime"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer/types"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	ddbtypes "github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	ssmtypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSlackWorkspace serves the Slack Web API of a workspace that installs
+// the app with OAuth.
+type fakeSlackWorkspace struct {
+	*httptest.Server
+	mu    sync.Mutex
+	calls []slackCall
+	seq   int
+}
+
+func newFakeSlackWorkspace(t *testing.T) *fakeSlackWorkspace {
+	t.Helper()
+	w := &fakeSlackWorkspace{}
+	mux := http.NewServeMux()
+	reply := func(rw http.ResponseWriter, v map[string]any) {
+		v["ok"] = true
+		rw.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(rw).Encode(v)
+	}
+	mux.HandleFunc("POST /api/oauth.v2.access", func(rw http.ResponseWriter, r *http.Request) {
+		if r.FormValue("code") != "install-code" {
+			rw.Header().Set("Content-Type", "application/json")
+			io.WriteString(rw, `{"ok":false,"error":"invalid_code"}`)
+			return
+		}
+		reply(rw, map[string]any{
+			"access_token": "xoxb-other",
+			"bot_user_id":  "U0BOT",
+			"team":         map[string]any{"id": "T0999", "name": "other"},
+			"incoming_webhook": map[string]any{
+				"channel":    "#costs",
+				"channel_id": "C0999",
+			},
+			"authed_user": map[string]any{"id": "U0INSTALLER"},
+		})
+	})
+	mux.HandleFunc("POST /api/chat.postMessage", func(rw http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "xoxb-other", r.FormValue("token"))
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		w.seq++
+		w.calls = append(w.calls, slackCall{
+			Method:  "chat.postMessage",
+			Channel: r.FormValue("channel"),
+			Thread:  r.FormValue("thread_ts"),
+			Text:    r.FormValue("text"),
+		})
+		reply(rw, map[string]any{"channel": r.FormValue("channel"), "ts": fmt.Sprintf("1800000000.%06d", w.seq)})
+	})
+	mux.HandleFunc("POST /api/chat.update", func(rw http.ResponseWriter, r *http.Request) {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		w.calls = append(w.calls, slackCall{Method: "chat.update", Channel: r.FormValue("channel"), Thread: r.FormValue("ts")})
+		reply(rw, map[string]any{"channel": r.FormValue("channel"), "ts": r.FormValue("ts")})
+	})
+	mux.HandleFunc("POST /api/files.getUploadURLExternal", func(rw http.ResponseWriter, r *http.Request) {
+		reply(rw, map[string]any{"upload_url": w.URL + "/upload", "file_id": "F0999"})
+	})
+	mux.HandleFunc("POST /upload", func(rw http.ResponseWriter, _ *http.Request) {
+		rw.WriteHeader(http.StatusOK)
+	})
+	mux.HandleFunc("POST /api/files.completeUploadExternal", func(rw http.ResponseWriter, r *http.Request) {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		w.calls = append(w.calls, slackCall{Method: "files.upload", Channel: r.FormValue("channel_id"), Thread: r.FormValue("thread_ts")})
+		reply(rw, map[string]any{"files": []map[string]any{{"id": "F0999"}}})
+	})
+	mux.HandleFunc("POST /api/files.info", func(rw http.ResponseWriter, _ *http.Request) {
+		reply(rw, map[string]any{"file": map[string]any{"id": "F0999", "permalink": "https://other.slack.com/files/F0999"}})
+	})
+	w.Server = httptest.NewServer(mux)
+	t.Cleanup(w.Close)
+	return w
+}
+
+func (w *fakeSlackWorkspace) Calls() []slackCall {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]slackCall(nil), w.calls...)
+}
+
+func TestVerifySlackOAuthState(t *testing.T) {
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	state := newSlackOAuthState("secret", now)
+	require.NoError(t, verifySlackOAuthState("secret", state, now.Add(time.Minute)))
+	require.ErrorContains(t, verifySlackOAuthState("secret", state, now.Add(time.Hour)), "expired")
+	require.ErrorContains(t, verifySlackOAuthState("other", state, now), "invalid")
+	require.Error(t, verifySlackOAuthState("secret", "", now))
+}
+
+func TestHandlerSlackOAuthInstallation(t *testing.T) {
+	workspace := newFakeSlackWorkspace(t)
+	s := newHandlerTestSuite(t, WithSlackOAuth(SlackOAuthConfig{
+		ClientID:     "client-id",
+		ClientSecret: "client-secret",
+		RedirectURL:  "https://reactor.example.com/slack/oauth",
+		AllowedTeams: []string{"T0999"},
+		APIURL:       workspace.URL + "/api/",
+	}))
+	client := *s.server.Client()
+	client.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	resp, err := client.Get(s.server.URL + "/slack/install")
+	require.NoError(t, err)
+	require.Equal(t, http.StatusFound, resp.StatusCode)
+	location, err := url.Parse(resp.Header.Get("Location"))
+	require.NoError(t, err)
+	require.Equal(t, "slack.com", location.Host)
+	require.Equal(t, "client-id", location.Query().Get("client_id"))
+	require.Contains(t, location.Query().Get("scope"), "incoming-webhook")
+	state := location.Query().Get("state")
+
+	resp, err = client.Get(s.server.URL + "/slack/oauth?" + url.Values{"code": {"install-code"}, "state": {"forged"}}.Encode())
+	require.NoError(t, err)
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	resp, err = client.Get(s.server.URL + "/slack/oauth?" + url.Values{"code": {"install-code"}, "state": {state}}.Encode())
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Contains(t, string(body), "Installed to other.")
+
+	ctx := context.Background()
+	inst, ok, err := s.h.GetSlackInstallation(ctx, "T0999")
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, "xoxb-other", inst.BotToken)
+	require.Equal(t, "C0999", inst.Channel)
+	require.Equal(t, "U0INSTALLER", inst.InstalledBy)
+	// the bot token is kept encrypted in SSM, not in the table
+	require.Equal(t, "/aws-cost-anomaly-slack-reactor/slack-installations/T0999", inst.BotTokenParameter)
+	param := s.ssm.parameters[inst.BotTokenParameter]
+	require.Equal(t, ssmtypes.ParameterTypeSecureString, param.Type)
+	require.Equal(t, "xoxb-other", aws.ToString(param.Value))
+	item, err := s.h.ddb.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String("test"),
+		Key: map[string]ddbtypes.AttributeValue{
+			"AnomalyID":   &ddbtypes.AttributeValueMemberS{Value: slackInstallationStateID},
+			"SlackTeamID": &ddbtypes.AttributeValueMemberS{Value: "T0999"},
+		},
+	})
+	require.NoError(t, err)
+	require.NotEmpty(t, item.Item)
+	require.NotContains(t, item.Item, "BotToken")
+
+	// anomalies are posted to both workspaces
+	a := loadTestAnomaly(t, "testdata/anomaly.json")
+	require.Equal(t, http.StatusOK, s.postSNS(s.notification(a)).StatusCode)
+	require.Equal(t, "chat.postMessage", s.slack.Calls()[0].Method)
+	calls := workspace.Calls()
+	require.Equal(t, "chat.postMessage", calls[0].Method)
+	require.Equal(t, "C0999", calls[0].Channel)
+	msg, ok, err := s.h.getAnomalySlackMessage(ctx, a.AnomalyID, "T0999")
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, "1800000000.000001", msg.SlackMessageTimestamp)
+
+	// feedback from the installed workspace is answered with its token
+	s.ce.On("ProvideAnomalyFeedback", mock.Anything, &costexplorer.ProvideAnomalyFeedbackInput{
+		AnomalyId: aws.String(a.AnomalyID),
+		Feedback:  types.AnomalyFeedbackTypeYes,
+	}).Return(&costexplorer.ProvideAnomalyFeedbackOutput{AnomalyId: aws.String(a.AnomalyID)}, nil).Once()
+	primary := len(s.slack.Calls())
+	resp = s.postTeamFeedback("T0999", msg.SlackMessageTimestamp, "U0INSTALLER", "bob", a.AnomalyID)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	calls = workspace.Calls()
+	last := calls[len(calls)-1]
+	require.Equal(t, "chat.postMessage", last.Method)
+	require.Equal(t, msg.SlackMessageTimestamp, last.Thread)
+	require.True(t, strings.HasPrefix(last.Text, "Feedback of `Yes` was provided"))
+	for _, c := range s.slack.Calls()[primary:] {
+		require.NotEqual(t, "chat.postMessage", c.Method, "feedback reply posted with the bot token")
+	}
+	s.ce.AssertExpectations(t)
+}
+
+func TestHandlerSlackOAuthInstallationNotAllowed(t *testing.T) {
+	workspace := newFakeSlackWorkspace(t)
+	s := newHandlerTestSuite(t, WithSlackOAuth(SlackOAuthConfig{
+		ClientID:     "client-id",
+		ClientSecret: "client-secret",
+		RedirectURL:  "https://reactor.example.com/slack/oauth",
+		AllowedTeams: []string{"T0123", "E0123"},
+		APIURL:       workspace.URL + "/api/",
+	}))
+	state := newSlackOAuthState("client-secret", time.Now())
+	resp, err := s.server.Client().Get(s.server.URL + "/slack/oauth?" + url.Values{"code": {"install-code"}, "state": {state}}.Encode())
+	require.NoError(t, err)
+	require.Equal(t, http.StatusForbidden, resp.StatusCode)
+	_, ok, err := s.h.GetSlackInstallation(context.Background(), "T0999")
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	// anomalies are posted only to the workspace of the bot token
+	a := loadTestAnomaly(t, "testdata/anomaly.json")
+	require.Equal(t, http.StatusOK, s.postSNS(s.notification(a)).StatusCode)
+	require.Empty(t, workspace.Calls())
+}
+
+func TestSlackOAuthConfigAllows(t *testing.T) {
+	cfg := SlackOAuthConfig{AllowedTeams: []string{"T0123", " E0456"}}
+	require.True(t, cfg.allows("T0123", ""))
+	require.True(t, cfg.allows("T0999", "E0456"))
+	require.False(t, cfg.allows("T0999", ""))
+	require.False(t, cfg.allows("", ""))
+	require.ErrorContains(t, SlackOAuthConfig{ClientID: "id", ClientSecret: "secret", RedirectURL: "https://reactor.example.com/slack/oauth"}.validate(), "allowed teams")
+}
+
+func TestHandlerSlackWorkspacesRefresh(t *testing.T) {
+	now := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	flextime.Fix(now)
+	defer flextime.Restore()
+	workspace := newFakeSlackWorkspace(t)
+	ddb := newMemoryDynamoDB()
+	ssmClient := &fakeSSMClient{}
+	opts := []Option{
+		WithSlackOAuth(SlackOAuthConfig{
+			ClientID:     "client-id",
+			ClientSecret: "client-secret",
+			RedirectURL:  "https://reactor.example.com/slack/oauth",
+			AllowedTeams: []string{"T0999"},
+			APIURL:       workspace.URL + "/api/",
+		}),
+		WithDynamoDBClient(ddb),
+		WithSSMClient(ssmClient),
+	}
+	s := newHandlerTestSuite(t, opts...)
+	// the installation is completed on another instance
+	other := newHandlerTestSuite(t, opts...)
+	state := newSlackOAuthState("client-secret", now)
+	resp, err := other.server.Client().Get(other.server.URL + "/slack/oauth?" + url.Values{"code": {"install-code"}, "state": {state}}.Encode())
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	a := loadTestAnomaly(t, "testdata/anomaly.json")
+	require.Equal(t, http.StatusOK, s.postSNS(s.notification(a)).StatusCode)
+	require.Empty(t, workspace.Calls())
+
+	flextime.Fix(now.Add(slackWorkspacesTTL))
+	b := loadTestAnomaly(t, "testdata/anomaly_ec2.json")
+	require.Equal(t, http.StatusOK, s.postSNS(s.notification(b)).StatusCode)
+	calls := workspace.Calls()
+	require.NotEmpty(t, calls)
+	require.Equal(t, "chat.postMessage", calls[0].Method)
+	require.Equal(t, "C0999", calls[0].Channel)
+}
@@ -0,0 +1,100 @@
+package reactor
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+	"github.com/slack-go/slack/socketmode"
+)
+
+// socketModeAcker acknowledges the requests received in Socket Mode.
+type socketModeAcker interface {
+	Ack(req socketmode.Request, payload ...any) error
+}
+
+// SocketModeEnabled reports whether the Handler connects to Slack in Socket
+// Mode, that is whether the app-level token is set.
+func (h *Handler) SocketModeEnabled() bool {
+	return h.socketMode != nil
+}
+
+// RunSocketMode connects to Slack in Socket Mode and handles the events and
+// interactions received over the connection until ctx is canceled. Requests
+// are acknowledged before being processed, so errors are posted to the thread
+// of the message like the canyon worker does.
+func (h *Handler) RunSocketMode(ctx context.Context) error {
+	if h.socketMode == nil {
+		return errors.New("slack app token is not set")
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- h.socketMode.RunContext(ctx)
+	}()
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for {
+		select {
+		case err := <-errCh:
+			if errors.Is(err, context.Canceled) {
+				return nil
+			}
+			return err
+		case evt := <-h.socketMode.Events:
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				h.handleSocketModeEvent(ctx, h.socketMode, evt)
+			}()
+		}
+	}
+}
+
+func (h *Handler) handleSocketModeEvent(ctx context.Context, acker socketModeAcker, evt socketmode.Event) {
+	switch evt.Type {
+	case socketmode.EventTypeConnecting:
+		h.logger.InfoContext(ctx, "connecting to slack in socket mode")
+		return
+	case socketmode.EventTypeConnected:
+		h.logger.InfoContext(ctx, "connected to slack in socket mode")
+		return
+	case socketmode.EventTypeConnectionError, socketmode.EventTypeInvalidAuth, socketmode.EventTypeIncomingError:
+		h.logger.WarnContext(ctx, "socket mode connection error", "type", evt.Type, "data", evt.Data)
+		return
+	}
+	if evt.Request == nil {
+		h.logger.DebugContext(ctx, "ignore socket mode event", "type", evt.Type)
+		return
+	}
+	if err := acker.Ack(*evt.Request); err != nil {
+		h.logger.ErrorContext(ctx, "failed to ack socket mode request", "type", evt.Type, "envelope_id", evt.Request.EnvelopeID, "error", err)
+		return
+	}
+	switch evt.Type {
+	case socketmode.EventTypeEventsAPI:
+		eventsAPIEvent, ok := evt.Data.(slackevents.EventsAPIEvent)
+		if !ok {
+			h.logger.WarnContext(ctx, "unexpected events api payload", "envelope_id", evt.Request.EnvelopeID)
+			return
+		}
+		h.logger.DebugContext(ctx, "events api event", "type", eventsAPIEvent.Type)
+		if eventsAPIEvent.Type == slackevents.CallbackEvent {
+			h.handleCallbackEvent(ctx, eventsAPIEvent)
+		}
+	case socketmode.EventTypeInteractive:
+		payload, ok := evt.Data.(slack.InteractionCallback)
+		if !ok {
+			h.logger.WarnContext(ctx, "unexpected interactive payload", "envelope_id", evt.Request.EnvelopeID)
+			return
+		}
+		ctx, span := startSpan(ctx, "processInteractiveMessage")
+		defer span.End()
+		h.handleInteraction(ctx, payload, true)
+	default:
+		h.logger.DebugContext(ctx, "ignore socket mode request", "type", evt.Type)
+	}
+}
@@ -0,0 +1,103 @@
+package reactor
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer/types"
+	"github.com/slack-go/slack"
+	"github.com/slack-go/slack/slackevents"
+	"github.com/slack-go/slack/socketmode"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeSocketModeAcker struct {
+	mu    sync.Mutex
+	acked []string
+}
+
+func (a *fakeSocketModeAcker) Ack(req socketmode.Request, _ ...any) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.acked = append(a.acked, req.EnvelopeID)
+	return nil
+}
+
+func TestHandlerSocketMode(t *testing.T) {
+	s := newHandlerTestSuite(t, WithSlackSignalSecret(""), WithSlackAppToken("xapp-test"))
+	require.True(t, s.h.SocketModeEnabled())
+	// events are not accepted over HTTP without the signing secret
+	resp := s.postAppMention("U0456", "<@U0123> hello")
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	ctx := context.Background()
+	a := loadTestAnomaly(t, "testdata/anomaly.json")
+	require.Equal(t, http.StatusOK, s.postSNS(s.notification(a)).StatusCode)
+	acker := &fakeSocketModeAcker{}
+	s.h.handleSocketModeEvent(ctx, acker, socketmode.Event{Type: socketmode.EventTypeConnected})
+
+	eventsAPIEvent, err := slackevents.ParseEvent(json.RawMessage(`{
+		"type": "event_callback",
+		"team_id": "T0123",
+		"event": {"type": "app_mention", "user": "U0456", "text": "<@U0123> hello", "channel": "C0456", "ts": "1700000000.000100"}
+	}`), slackevents.OptionNoVerifyToken())
+	require.NoError(t, err)
+	s.h.handleSocketModeEvent(ctx, acker, socketmode.Event{
+		Type:    socketmode.EventTypeEventsAPI,
+		Data:    eventsAPIEvent,
+		Request: &socketmode.Request{Type: socketmode.RequestTypeEventsAPI, EnvelopeID: "envelope-1"},
+	})
+	calls := s.slack.Calls()
+	last := calls[len(calls)-1]
+	require.Equal(t, "C0456", last.Channel)
+	require.True(t, strings.HasPrefix(last.Text, "I'm AWS Cost Anomaly Detection Reactor"))
+
+	s.ce.On("ProvideAnomalyFeedback", mock.Anything, &costexplorer.ProvideAnomalyFeedbackInput{
+		AnomalyId: aws.String(a.AnomalyID),
+		Feedback:  types.AnomalyFeedbackTypeYes,
+	}).Return(&costexplorer.ProvideAnomalyFeedbackOutput{AnomalyId: aws.String(a.AnomalyID)}, nil).Once()
+	var payload slack.InteractionCallback
+	require.NoError(t, json.Unmarshal([]byte(`{
+		"type": "block_actions",
+		"team": {"id": "T0123"},
+		"user": {"id": "U0456", "name": "alice"},
+		"channel": {"id": "C0123"},
+		"message": {"ts": "1700000000.000001"},
+		"actions": [{
+			"block_id": "`+actionsBlockID+`",
+			"action_id": "`+actionsYesID+`",
+			"value": "`+url.Values{"anomaly_id": {a.AnomalyID}}.Encode()+`",
+			"text": {"type": "plain_text", "text": "Yes"}
+		}]
+	}`), &payload))
+	s.h.handleSocketModeEvent(ctx, acker, socketmode.Event{
+		Type:    socketmode.EventTypeInteractive,
+		Data:    payload,
+		Request: &socketmode.Request{Type: socketmode.RequestTypeInteractive, EnvelopeID: "envelope-2"},
+	})
+	s.ce.AssertExpectations(t)
+	calls = s.slack.Calls()
+	last = calls[len(calls)-1]
+	require.Equal(t, "1700000000.000001", last.Thread)
+	require.Equal(t, "Feedback of `Yes` was provided for AnomalyID `12345678-abcd-ef12-3456-987654321a12` by user `alice` .", last.Text)
+	require.Equal(t, []string{"envelope-1", "envelope-2"}, acker.acked)
+}
+
+func TestNewSocketModeRequiresAppToken(t *testing.T) {
+	_, err := New(context.Background(),
+		WithAWSConfig(&aws.Config{Region: "us-east-1"}),
+		WithSlackBotToken("xoxb-test"),
+		WithSlackChannel("C0123"),
+		WithSlackSignalSecret(""),
+		WithSlackAppToken("xoxb-test"),
+	)
+	require.ErrorContains(t, err, "xapp-")
+}
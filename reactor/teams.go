@@ -0,0 +1,298 @@
+package reactor
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Songmu/flextime"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer/types"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const defaultTeamsOpenIDMetadataURL = "https://login.botframework.com/v1/.well-known/openidconfiguration"
+
+// botFrameworkAuthenticator verifies the JWT bearer token the Bot Framework
+// attaches to activities sent to the messaging endpoint.
+// https://learn.microsoft.com/azure/bot-service/rest-api/bot-framework-rest-connector-authentication
+type botFrameworkAuthenticator struct {
+	appID       string
+	metadataURL string
+	client      *http.Client
+
+	mu     sync.Mutex
+	issuer string
+	keys   map[string]*rsa.PublicKey
+	expire time.Time
+}
+
+func newBotFrameworkAuthenticator(cfg TeamsConfig) *botFrameworkAuthenticator {
+	metadataURL := cfg.OpenIDMetadataURL
+	if metadataURL == "" {
+		metadataURL = defaultTeamsOpenIDMetadataURL
+	}
+	return &botFrameworkAuthenticator{
+		appID:       cfg.AppID,
+		metadataURL: metadataURL,
+		client:      http.DefaultClient,
+	}
+}
+
+type openIDMetadata struct {
+	Issuer  string `json:"issuer"`
+	JWKSURI string `json:"jwks_uri"`
+}
+
+type jsonWebKeySet struct {
+	Keys []struct {
+		Kid string `json:"kid"`
+		Kty string `json:"kty"`
+		N   string `json:"n"`
+		E   string `json:"e"`
+	} `json:"keys"`
+}
+
+func (a *botFrameworkAuthenticator) loadKeys(ctx context.Context) (string, map[string]*rsa.PublicKey, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.keys != nil && flextime.Now().Before(a.expire) {
+		return a.issuer, a.keys, nil
+	}
+	var metadata openIDMetadata
+	if err := doJSONRequest(ctx, a.client, http.MethodGet, a.metadataURL, nil, nil, &metadata); err != nil {
+		return "", nil, fmt.Errorf("failed to get openid metadata: %w", err)
+	}
+	var jwks jsonWebKeySet
+	if err := doJSONRequest(ctx, a.client, http.MethodGet, metadata.JWKSURI, nil, nil, &jwks); err != nil {
+		return "", nil, fmt.Errorf("failed to get jwks: %w", err)
+	}
+	keys := make(map[string]*rsa.PublicKey, len(jwks.Keys))
+	for _, k := range jwks.Keys {
+		if k.Kty != "RSA" {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid jwk %s: %w", k.Kid, err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid jwk %s: %w", k.Kid, err)
+		}
+		keys[k.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+	a.issuer = metadata.Issuer
+	a.keys = keys
+	a.expire = flextime.Now().Add(24 * time.Hour)
+	return a.issuer, a.keys, nil
+}
+
+// Authenticate validates the Authorization header of an activity and checks
+// that the token was issued for the activity's service URL.
+func (a *botFrameworkAuthenticator) Authenticate(ctx context.Context, authorization string, serviceURL string) error {
+	tokenString, ok := strings.CutPrefix(authorization, "Bearer ")
+	if !ok || tokenString == "" {
+		return errors.New("missing bearer token")
+	}
+	issuer, keys, err := a.loadKeys(ctx)
+	if err != nil {
+		return err
+	}
+	claims := jwt.MapClaims{}
+	_, err = jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (any, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, ok := keys[kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown signing key: %s", kid)
+		}
+		return key, nil
+	},
+		jwt.WithValidMethods([]string{"RS256"}),
+		jwt.WithIssuer(issuer),
+		jwt.WithAudience(a.appID),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(5*time.Minute),
+		jwt.WithTimeFunc(flextime.Now),
+	)
+	if err != nil {
+		return fmt.Errorf("invalid token: %w", err)
+	}
+	claimed, _ := claims["serviceurl"].(string)
+	if claimed == "" {
+		return errors.New("service url claim is missing")
+	}
+	if strings.TrimSuffix(claimed, "/") != strings.TrimSuffix(serviceURL, "/") {
+		return fmt.Errorf("service url mismatch: token=%s activity=%s", claimed, serviceURL)
+	}
+	return nil
+}
+
+type teamsFeedbackData struct {
+	AnomalyID string `json:"anomaly_id"`
+	ActionID  string `json:"action_id"`
+}
+
+// feedbackData extracts the button data of an Action.Execute invoke or an
+// Action.Submit message activity.
+func (a *teamsActivity) feedbackData() (*teamsFeedbackData, bool, error) {
+	if len(a.Value) == 0 {
+		return nil, false, nil
+	}
+	raw := a.Value
+	isInvoke := a.Type == "invoke"
+	if isInvoke {
+		if a.Name != teamsAdaptiveCardActionName {
+			return nil, false, nil
+		}
+		var v struct {
+			Action struct {
+				Type string          `json:"type"`
+				Verb string          `json:"verb"`
+				Data json.RawMessage `json:"data"`
+			} `json:"action"`
+		}
+		if err := json.Unmarshal(raw, &v); err != nil {
+			return nil, true, fmt.Errorf("failed to parse invoke value: %w", err)
+		}
+		if v.Action.Verb != actionsBlockID {
+			return nil, false, nil
+		}
+		raw = v.Action.Data
+	}
+	var data teamsFeedbackData
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return nil, isInvoke, fmt.Errorf("failed to parse action data: %w", err)
+	}
+	if data.AnomalyID == "" || data.ActionID == "" {
+		return nil, false, nil
+	}
+	return &data, isInvoke, nil
+}
+
+func feedbackLabel(actionID string) string {
+	switch actionID {
+	case actionsYesID:
+		return string(types.AnomalyFeedbackTypeYes)
+	case actionsNoID:
+		return string(types.AnomalyFeedbackTypeNo)
+	case actionsPlanedActivityID:
+		return string(types.AnomalyFeedbackTypePlannedActivity)
+	}
+	return actionID
+}
+
+func writeTeamsInvokeResponse(w http.ResponseWriter, statusCode int, text string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	bs, err := json.Marshal(map[string]any{
+		"statusCode": statusCode,
+		"type":       "application/vnd.microsoft.activity.message",
+		"value":      text,
+	})
+	if err != nil {
+		return
+	}
+	w.Write(bs)
+}
+
+func (h *Handler) handleTeamsMessages(w http.ResponseWriter, r *http.Request) {
+	h.logger.Debug("start handle teams messages")
+	ctx := r.Context()
+	bs, err := io.ReadAll(r.Body)
+	if err != nil {
+		h.logger.Error("failed to read body", "error", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	var activity teamsActivity
+	if err := json.Unmarshal(bs, &activity); err != nil {
+		h.logger.Error("failed to decode activity", "error", err)
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if err := h.teamsAuth.Authenticate(ctx, r.Header.Get("Authorization"), activity.ServiceURL); err != nil {
+		h.logger.Warn("failed to authenticate teams activity", "error", err)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	data, isInvoke, err := activity.feedbackData()
+	if err != nil {
+		h.logger.Warn("failed to parse teams action", "error", err)
+		if isInvoke {
+			writeTeamsInvokeResponse(w, http.StatusBadRequest, fmt.Sprintf("[error] %s", err))
+			return
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if data == nil {
+		h.logger.Debug("ignore teams activity", "type", activity.Type, "name", activity.Name)
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	var userName, userID string
+	if activity.From != nil {
+		userName = activity.From.Name
+		userID = activity.From.ID
+	}
+	allowed, err := h.authorizeTeamsFeedback(ctx, data.AnomalyID, activity.From)
+	if err != nil {
+		h.logger.ErrorContext(ctx, "failed to authorize feedback", "anomaly_id", data.AnomalyID, "teams_user_id", userID, "error", err)
+	}
+	if !allowed {
+		h.logger.InfoContext(ctx, "deny feedback", "anomaly_id", data.AnomalyID, "action_id", data.ActionID, "teams_user_id", userID, "teams_user", userName)
+		if err := h.trackDeniedFeedback(ctx, data.AnomalyID, data.ActionID, userName, userID, "teams"); err != nil {
+			h.logger.WarnContext(ctx, "failed to track denied feedback", "anomaly_id", data.AnomalyID, "error", err)
+		}
+		text := fmt.Sprintf("You are not allowed to provide feedback for AnomalyID `%s` .", data.AnomalyID)
+		if isInvoke {
+			// the invoke response is shown only to the user
+			writeTeamsInvokeResponse(w, http.StatusOK, text)
+			return
+		}
+		if err := h.teams.replyTo(ctx, &activity, text); err != nil {
+			h.logger.WarnContext(ctx, "failed to reply to teams activity", "error", err)
+		}
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	h.logger.Info("provide feedback action", "anomaly_id", data.AnomalyID, "action_id", data.ActionID, "teams_user", userName)
+	var text string
+	status := http.StatusOK
+	if err := h.ProvideFeedback(ctx, data.AnomalyID, data.ActionID); err != nil {
+		h.logger.Error("failed to provide feedback", "error", err)
+		text = fmt.Sprintf("[error] failed to provide feedback: %s", err)
+		status = http.StatusInternalServerError
+	} else {
+		h.sendFeedbackWebhook(ctx, data.AnomalyID, data.ActionID, userName, "teams")
+		if err := h.trackFeedback(ctx, data.AnomalyID, data.ActionID, userName, userID, "teams"); err != nil {
+			h.logger.WarnContext(ctx, "failed to track feedback", "anomaly_id", data.AnomalyID, "error", err)
+		}
+		text = fmt.Sprintf("Feedback of `%s` was provided for AnomalyID `%s` by user `%s` .", feedbackLabel(data.ActionID), data.AnomalyID, userName)
+		if h.github != nil && data.ActionID == actionsYesID {
+			if issueText := h.openGitHubIssueText(ctx, data.AnomalyID, h.teams.ID(), userName); issueText != "" {
+				text += "\n\n" + issueText
+			}
+		}
+	}
+	if err := h.teams.replyTo(ctx, &activity, text); err != nil {
+		h.logger.WarnContext(ctx, "failed to reply to teams activity", "error", err)
+	}
+	if isInvoke {
+		writeTeamsInvokeResponse(w, status, text)
+		return
+	}
+	w.WriteHeader(status)
+}
@@ -0,0 +1,329 @@
+package reactor
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/Songmu/flextime"
+)
+
+// TeamsConfig configures the TeamsNotifier, which posts anomalies to a
+// Microsoft Teams channel through the Bot Framework connector of a Teams bot.
+type TeamsConfig struct {
+	// AppID and AppPassword are the Microsoft App ID and client secret of
+	// the bot registration.
+	AppID       string `json:"appId,omitempty"`
+	AppPassword string `json:"appPassword,omitempty"`
+	// TenantID is the tenant of a single-tenant bot. Multi-tenant bots leave
+	// it empty.
+	TenantID string `json:"tenantId,omitempty"`
+	// ServiceURL is the Bot Framework service URL of the tenant, e.g.
+	// https://smba.trafficmanager.net/amer/ .
+	ServiceURL string `json:"serviceUrl,omitempty"`
+	// ConversationID is the ID of the channel anomalies are posted to, e.g.
+	// 19:xxxx@thread.tacv2 .
+	ConversationID string `json:"conversationId,omitempty"`
+	// TokenURL and OpenIDMetadataURL override the Microsoft identity
+	// endpoints. They are meant for tests against a local stand-in.
+	TokenURL          string `json:"tokenUrl,omitempty"`
+	OpenIDMetadataURL string `json:"openIdMetadataUrl,omitempty"`
+}
+
+// Enabled reports whether the Teams bot is configured.
+func (cfg TeamsConfig) Enabled() bool {
+	return cfg.AppID != ""
+}
+
+func (cfg TeamsConfig) validate() error {
+	if cfg.AppPassword == "" {
+		return errors.New("teams app password is required")
+	}
+	if cfg.ServiceURL == "" {
+		return errors.New("teams service url is required")
+	}
+	if cfg.ConversationID == "" {
+		return errors.New("teams conversation id is required")
+	}
+	return nil
+}
+
+func (cfg TeamsConfig) tokenURL() string {
+	if cfg.TokenURL != "" {
+		return cfg.TokenURL
+	}
+	tenant := cfg.TenantID
+	if tenant == "" {
+		tenant = "botframework.com"
+	}
+	return fmt.Sprintf("https://login.microsoftonline.com/%s/oauth2/v2.0/token", tenant)
+}
+
+const (
+	teamsTokenScope             = "https://api.botframework.com/.default"
+	teamsAdaptiveCardType       = "application/vnd.microsoft.card.adaptive"
+	teamsAdaptiveCardActionName = "adaptiveCard/action"
+)
+
+//go:embed default_teams_card.json.tpl
+var defaultTeamsTemplate string
+
+// TeamsNotifier is the Notifier that posts anomalies to Microsoft Teams as
+// Adaptive Cards rendered from a JSON template.
+type TeamsNotifier struct {
+	cfg         TeamsConfig
+	client      *http.Client
+	tpl         *template.Template
+	retryPolicy RetryPolicy
+
+	mu          sync.Mutex
+	token       string
+	tokenExpire time.Time
+}
+
+var _ Notifier = (*TeamsNotifier)(nil)
+
+// NewTeamsNotifier returns a TeamsNotifier rendering anomalies with the given
+// Adaptive Card template. Every connector call is retried according to
+// policy.
+func NewTeamsNotifier(cfg TeamsConfig, tpl *template.Template, policy RetryPolicy) *TeamsNotifier {
+	return &TeamsNotifier{
+		cfg:         cfg,
+		client:      http.DefaultClient,
+		tpl:         tpl,
+		retryPolicy: policy,
+	}
+}
+
+// ID implements Notifier.
+func (n *TeamsNotifier) ID() string {
+	return "teams:" + n.cfg.ConversationID
+}
+
+type teamsChannelAccount struct {
+	ID          string `json:"id,omitempty"`
+	Name        string `json:"name,omitempty"`
+	AADObjectID string `json:"aadObjectId,omitempty"`
+}
+
+type teamsConversationAccount struct {
+	ID string `json:"id,omitempty"`
+}
+
+type teamsAttachment struct {
+	ContentType string `json:"contentType"`
+	ContentURL  string `json:"contentUrl,omitempty"`
+	Content     any    `json:"content,omitempty"`
+	Name        string `json:"name,omitempty"`
+}
+
+// teamsActivity is the subset of the Bot Framework Activity schema used by
+// the reactor.
+type teamsActivity struct {
+	Type         string                    `json:"type"`
+	ID           string                    `json:"id,omitempty"`
+	Name         string                    `json:"name,omitempty"`
+	ServiceURL   string                    `json:"serviceUrl,omitempty"`
+	ReplyToID    string                    `json:"replyToId,omitempty"`
+	From         *teamsChannelAccount      `json:"from,omitempty"`
+	Conversation *teamsConversationAccount `json:"conversation,omitempty"`
+	Text         string                    `json:"text,omitempty"`
+	TextFormat   string                    `json:"textFormat,omitempty"`
+	Attachments  []teamsAttachment         `json:"attachments,omitempty"`
+	Value        json.RawMessage           `json:"value,omitempty"`
+}
+
+type teamsResourceResponse struct {
+	ID string `json:"id"`
+}
+
+// PostAnomaly implements Notifier. The returned thread is the activity ID of
+// the posted card.
+func (n *TeamsNotifier) PostAnomaly(ctx context.Context, data TemplateData) (string, error) {
+	card, err := n.renderCard(data)
+	if err != nil {
+		return "", err
+	}
+	return n.sendActivity(ctx, n.cfg.ServiceURL, n.cfg.ConversationID, &teamsActivity{
+		Type:        "message",
+		Attachments: []teamsAttachment{{ContentType: teamsAdaptiveCardType, Content: card}},
+	})
+}
+
+// UpdateAnomaly implements Notifier.
+func (n *TeamsNotifier) UpdateAnomaly(ctx context.Context, thread string, data TemplateData) error {
+	card, err := n.renderCard(data)
+	if err != nil {
+		return err
+	}
+	activity := &teamsActivity{
+		Type:        "message",
+		ID:          thread,
+		Attachments: []teamsAttachment{{ContentType: teamsAdaptiveCardType, Content: card}},
+	}
+	u := n.activitiesURL(n.cfg.ServiceURL, n.cfg.ConversationID) + "/" + url.PathEscape(thread)
+	return n.do(ctx, http.MethodPut, u, activity, nil)
+}
+
+// PostThreadReply implements Notifier.
+func (n *TeamsNotifier) PostThreadReply(ctx context.Context, thread string, text string) error {
+	_, err := n.sendActivity(ctx, n.cfg.ServiceURL, n.threadConversationID(thread), &teamsActivity{
+		Type:       "message",
+		Text:       text,
+		TextFormat: "markdown",
+	})
+	return err
+}
+
+// UploadImage implements Notifier. The image is attached inline as a data
+// URL because bots cannot upload files to channel conversations, so no
+// permalink is returned.
+func (n *TeamsNotifier) UploadImage(ctx context.Context, thread string, name string, g *Graph) (string, error) {
+	bs, err := io.ReadAll(g.NewReader())
+	if err != nil {
+		return "", fmt.Errorf("failed to read graph: %w", err)
+	}
+	_, err = n.sendActivity(ctx, n.cfg.ServiceURL, n.threadConversationID(thread), &teamsActivity{
+		Type: "message",
+		Attachments: []teamsAttachment{{
+			ContentType: "image/png",
+			ContentURL:  "data:image/png;base64," + base64.StdEncoding.EncodeToString(bs),
+			Name:        name,
+		}},
+	})
+	return "", err
+}
+
+// PostMessage implements Notifier.
+func (n *TeamsNotifier) PostMessage(ctx context.Context, text string) error {
+	_, err := n.sendActivity(ctx, n.cfg.ServiceURL, n.cfg.ConversationID, &teamsActivity{
+		Type:       "message",
+		Text:       text,
+		TextFormat: "markdown",
+	})
+	return err
+}
+
+// replyTo posts text into the conversation of an incoming activity.
+func (n *TeamsNotifier) replyTo(ctx context.Context, activity *teamsActivity, text string) error {
+	if activity.Conversation == nil {
+		return errors.New("activity has no conversation")
+	}
+	_, err := n.sendActivity(ctx, activity.ServiceURL, activity.Conversation.ID, &teamsActivity{
+		Type:       "message",
+		Text:       text,
+		TextFormat: "markdown",
+		ReplyToID:  activity.ReplyToID,
+	})
+	return err
+}
+
+func (n *TeamsNotifier) threadConversationID(thread string) string {
+	return n.cfg.ConversationID + ";messageid=" + thread
+}
+
+func (n *TeamsNotifier) activitiesURL(serviceURL string, conversationID string) string {
+	return strings.TrimSuffix(serviceURL, "/") + "/v3/conversations/" + url.PathEscape(conversationID) + "/activities"
+}
+
+func (n *TeamsNotifier) sendActivity(ctx context.Context, serviceURL string, conversationID string, activity *teamsActivity) (string, error) {
+	var res teamsResourceResponse
+	if err := n.do(ctx, http.MethodPost, n.activitiesURL(serviceURL, conversationID), activity, &res); err != nil {
+		return "", err
+	}
+	return res.ID, nil
+}
+
+// do sends an authorized request to the Bot Framework. POSTs create
+// activities and are retried only when throttled.
+func (n *TeamsNotifier) do(ctx context.Context, method string, u string, in any, out any) error {
+	policy := n.retryPolicy
+	if method == http.MethodPost {
+		policy = policy.onlyThrottled()
+	}
+	return policy.Do(ctx, func(ctx context.Context) error {
+		token, err := n.accessToken(ctx)
+		if err != nil {
+			return err
+		}
+		header := http.Header{}
+		header.Set("Authorization", "Bearer "+token)
+		return doJSONRequest(ctx, n.client, method, u, header, in, out)
+	})
+}
+
+type teamsTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+func (n *TeamsNotifier) accessToken(ctx context.Context) (string, error) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.token != "" && flextime.Now().Before(n.tokenExpire) {
+		return n.token, nil
+	}
+	form := url.Values{
+		"grant_type":    []string{"client_credentials"},
+		"client_id":     []string{n.cfg.AppID},
+		"client_secret": []string{n.cfg.AppPassword},
+		"scope":         []string{teamsTokenScope},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.cfg.tokenURL(), strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", fmt.Errorf("failed to create token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to get teams access token: %w", err)
+	}
+	defer resp.Body.Close()
+	bs, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read token response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", &httpStatusError{
+			Method:     http.MethodPost,
+			URL:        req.URL.String(),
+			StatusCode: resp.StatusCode,
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+			Body:       string(bs),
+		}
+	}
+	var token teamsTokenResponse
+	if err := json.Unmarshal(bs, &token); err != nil {
+		return "", fmt.Errorf("failed to decode token response: %w", err)
+	}
+	n.token = token.AccessToken
+	// refresh a few minutes before the token actually expires
+	n.tokenExpire = flextime.Now().Add(time.Duration(token.ExpiresIn)*time.Second - 5*time.Minute)
+	return n.token, nil
+}
+
+func (n *TeamsNotifier) renderCard(data TemplateData) (map[string]any, error) {
+	var buf bytes.Buffer
+	if err := n.tpl.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("failed to execute template: %w", err)
+	}
+	var card map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &card); err != nil {
+		return nil, fmt.Errorf("failed to decode template: %w", err)
+	}
+	if card["type"] != "AdaptiveCard" {
+		return nil, fmt.Errorf("template is not an AdaptiveCard: type=%v", card["type"])
+	}
+	return card, nil
+}
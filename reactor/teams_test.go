@@ -0,0 +1,285 @@
+package reactor
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer"
+	"github.com/aws/aws-sdk-go-v2/service/costexplorer/types"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeBotFramework stands in for the Microsoft identity platform and the Bot
+// Framework connector.
+type fakeBotFramework struct {
+	*httptest.Server
+	key *rsa.PrivateKey
+
+	mu         sync.Mutex
+	activities []recordedTeamsActivity
+}
+
+type recordedTeamsActivity struct {
+	Method   string
+	Path     string
+	Activity teamsActivity
+}
+
+func newFakeBotFramework(t *testing.T) *fakeBotFramework {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	f := &fakeBotFramework{key: key}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /token", func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		require.Equal(t, "client_credentials", r.FormValue("grant_type"))
+		require.Equal(t, "app-id", r.FormValue("client_id"))
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"access_token":"connector-token","expires_in":3600}`)
+	})
+	mux.HandleFunc("GET /openid", func(w http.ResponseWriter, _ *http.Request) {
+		json.NewEncoder(w).Encode(openIDMetadata{Issuer: "https://api.botframework.com", JWKSURI: f.URL + "/keys"})
+	})
+	mux.HandleFunc("GET /keys", func(w http.ResponseWriter, _ *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"keys": []map[string]string{{
+				"kid": "test-key",
+				"kty": "RSA",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/v3/", func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "Bearer connector-token", r.Header.Get("Authorization"))
+		var activity teamsActivity
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&activity))
+		f.mu.Lock()
+		f.activities = append(f.activities, recordedTeamsActivity{Method: r.Method, Path: r.URL.Path, Activity: activity})
+		f.mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"id":"1700000000001"}`)
+	})
+	f.Server = httptest.NewServer(mux)
+	t.Cleanup(f.Close)
+	return f
+}
+
+func (f *fakeBotFramework) Activities() []recordedTeamsActivity {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]recordedTeamsActivity(nil), f.activities...)
+}
+
+func (f *fakeBotFramework) Config() TeamsConfig {
+	return TeamsConfig{
+		AppID:             "app-id",
+		AppPassword:       "app-password",
+		ServiceURL:        f.URL + "/",
+		ConversationID:    "19:channel@thread.tacv2",
+		TokenURL:          f.URL + "/token",
+		OpenIDMetadataURL: f.URL + "/openid",
+	}
+}
+
+func (f *fakeBotFramework) SignToken(t *testing.T, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = "test-key"
+	signed, err := token.SignedString(f.key)
+	require.NoError(t, err)
+	return signed
+}
+
+func TestTeamsNotifier(t *testing.T) {
+	f := newFakeBotFramework(t)
+	tpl, err := parseTemplate("teams", defaultTeamsTemplate)
+	require.NoError(t, err)
+	n := NewTeamsNotifier(f.Config(), tpl, RetryPolicy{})
+	h := &Handler{}
+	data, err := h.newTemplateData(context.Background(), loadTestAnomaly(t, "testdata/anomaly.json"))
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	thread, err := n.PostAnomaly(ctx, data)
+	require.NoError(t, err)
+	require.Equal(t, "1700000000001", thread)
+	require.NoError(t, n.UpdateAnomaly(ctx, thread, data))
+	require.NoError(t, n.PostThreadReply(ctx, thread, "hello"))
+
+	activities := f.Activities()
+	require.Len(t, activities, 3)
+	require.Equal(t, http.MethodPost, activities[0].Method)
+	require.Equal(t, "/v3/conversations/19:channel@thread.tacv2/activities", activities[0].Path)
+	require.Len(t, activities[0].Activity.Attachments, 1)
+	require.Equal(t, teamsAdaptiveCardType, activities[0].Activity.Attachments[0].ContentType)
+	card, ok := activities[0].Activity.Attachments[0].Content.(map[string]any)
+	require.True(t, ok)
+	require.Equal(t, "AdaptiveCard", card["type"])
+	require.Len(t, card["actions"], 3)
+	require.Equal(t, http.MethodPut, activities[1].Method)
+	require.Equal(t, "/v3/conversations/19:channel@thread.tacv2/activities/1700000000001", activities[1].Path)
+	require.Equal(t, http.MethodPost, activities[2].Method)
+	require.Equal(t, "/v3/conversations/19:channel@thread.tacv2;messageid=1700000000001/activities", activities[2].Path)
+	require.Equal(t, "hello", activities[2].Activity.Text)
+}
+
+func TestHandlerTeamsMessages(t *testing.T) {
+	f := newFakeBotFramework(t)
+	cfg := f.Config()
+	tpl, err := parseTemplate("teams", defaultTeamsTemplate)
+	require.NoError(t, err)
+	mockClient := mockGetCostAndUsageAPIClient{t: t}
+	defer mockClient.AssertExpectations(t)
+	mockClient.On("ProvideAnomalyFeedback", mock.Anything, &costexplorer.ProvideAnomalyFeedbackInput{
+		AnomalyId: aws.String("12345678-abcd-ef12-3456-987654321a12"),
+		Feedback:  types.AnomalyFeedbackTypeNo,
+	}).Return(&costexplorer.ProvideAnomalyFeedbackOutput{}, nil).Once()
+	h := &Handler{
+		ce:        &mockClient,
+		logger:    slog.Default(),
+		teams:     NewTeamsNotifier(cfg, tpl, RetryPolicy{}),
+		teamsAuth: newBotFrameworkAuthenticator(cfg),
+	}
+	invoke := teamsActivity{
+		Type:         "invoke",
+		Name:         teamsAdaptiveCardActionName,
+		ServiceURL:   cfg.ServiceURL,
+		ReplyToID:    "1700000000001",
+		From:         &teamsChannelAccount{ID: "29:user", Name: "Alice"},
+		Conversation: &teamsConversationAccount{ID: cfg.ConversationID},
+		Value:        json.RawMessage(`{"action":{"type":"Action.Execute","verb":"` + actionsBlockID + `","data":{"anomaly_id":"12345678-abcd-ef12-3456-987654321a12","action_id":"` + actionsNoID + `"}}}`),
+	}
+	now := time.Now()
+	validClaims := jwt.MapClaims{
+		"iss":        "https://api.botframework.com",
+		"aud":        "app-id",
+		"exp":        now.Add(time.Hour).Unix(),
+		"nbf":        now.Add(-time.Minute).Unix(),
+		"serviceurl": cfg.ServiceURL,
+	}
+	cases := []struct {
+		name          string
+		authorization string
+		status        int
+	}{
+		{name: "missing token", authorization: "", status: http.StatusUnauthorized},
+		{name: "wrong audience", authorization: "Bearer " + f.SignToken(t, jwt.MapClaims{
+			"iss": "https://api.botframework.com",
+			"aud": "other-app",
+			"exp": now.Add(time.Hour).Unix(),
+		}), status: http.StatusUnauthorized},
+		{name: "service url missing", authorization: "Bearer " + f.SignToken(t, jwt.MapClaims{
+			"iss": "https://api.botframework.com",
+			"aud": "app-id",
+			"exp": now.Add(time.Hour).Unix(),
+		}), status: http.StatusUnauthorized},
+		{name: "service url mismatch", authorization: "Bearer " + f.SignToken(t, jwt.MapClaims{
+			"iss":        "https://api.botframework.com",
+			"aud":        "app-id",
+			"exp":        now.Add(time.Hour).Unix(),
+			"serviceurl": "https://evil.example.com/",
+		}), status: http.StatusUnauthorized},
+		{name: "valid", authorization: "Bearer " + f.SignToken(t, validClaims), status: http.StatusOK},
+	}
+	bs, err := json.Marshal(invoke)
+	require.NoError(t, err)
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/teams/messages", bytes.NewReader(bs))
+			req.Header.Set("Authorization", c.authorization)
+			w := httptest.NewRecorder()
+			h.handleTeamsMessages(w, req)
+			require.Equal(t, c.status, w.Code)
+			if c.status != http.StatusOK {
+				return
+			}
+			var res map[string]any
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+			require.EqualValues(t, http.StatusOK, res["statusCode"])
+			require.True(t, strings.HasPrefix(res["value"].(string), "Feedback of `NO` was provided"), res["value"])
+		})
+	}
+	activities := f.Activities()
+	require.Len(t, activities, 1)
+	require.Equal(t, "/v3/conversations/19:channel@thread.tacv2/activities", activities[0].Path)
+	require.Equal(t, "1700000000001", activities[0].Activity.ReplyToID)
+}
+
+func TestHandlerTeamsMessagesFeedbackAuth(t *testing.T) {
+	f := newFakeBotFramework(t)
+	cfg := f.Config()
+	tpl, err := parseTemplate("teams", defaultTeamsTemplate)
+	require.NoError(t, err)
+	mockClient := mockGetCostAndUsageAPIClient{t: t}
+	defer mockClient.AssertExpectations(t)
+	mockClient.On("ProvideAnomalyFeedback", mock.Anything, &costexplorer.ProvideAnomalyFeedbackInput{
+		AnomalyId: aws.String("12345678-abcd-ef12-3456-987654321a12"),
+		Feedback:  types.AnomalyFeedbackTypeNo,
+	}).Return(&costexplorer.ProvideAnomalyFeedbackOutput{}, nil).Once()
+	h := &Handler{
+		ce:           &mockClient,
+		logger:       slog.Default(),
+		teams:        NewTeamsNotifier(cfg, tpl, RetryPolicy{}),
+		teamsAuth:    newBotFrameworkAuthenticator(cfg),
+		feedbackAuth: FeedbackAuthConfig{Users: []string{"U0123", "aad-alice"}},
+	}
+	now := time.Now()
+	token := f.SignToken(t, jwt.MapClaims{
+		"iss":        "https://api.botframework.com",
+		"aud":        "app-id",
+		"exp":        now.Add(time.Hour).Unix(),
+		"nbf":        now.Add(-time.Minute).Unix(),
+		"serviceurl": cfg.ServiceURL,
+	})
+	cases := []struct {
+		name string
+		from *teamsChannelAccount
+		want string
+	}{
+		{name: "missing sender", from: nil, want: "You are not allowed to provide feedback"},
+		{name: "not allowed", from: &teamsChannelAccount{ID: "29:bob", Name: "Bob", AADObjectID: "aad-bob"}, want: "You are not allowed to provide feedback"},
+		{name: "allowed", from: &teamsChannelAccount{ID: "29:alice", Name: "Alice", AADObjectID: "aad-alice"}, want: "Feedback of `NO` was provided"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			bs, err := json.Marshal(teamsActivity{
+				Type:         "invoke",
+				Name:         teamsAdaptiveCardActionName,
+				ServiceURL:   cfg.ServiceURL,
+				ReplyToID:    "1700000000001",
+				From:         c.from,
+				Conversation: &teamsConversationAccount{ID: cfg.ConversationID},
+				Value:        json.RawMessage(`{"action":{"type":"Action.Execute","verb":"` + actionsBlockID + `","data":{"anomaly_id":"12345678-abcd-ef12-3456-987654321a12","action_id":"` + actionsNoID + `"}}}`),
+			})
+			require.NoError(t, err)
+			req := httptest.NewRequest(http.MethodPost, "/teams/messages", bytes.NewReader(bs))
+			req.Header.Set("Authorization", "Bearer "+token)
+			w := httptest.NewRecorder()
+			h.handleTeamsMessages(w, req)
+			require.Equal(t, http.StatusOK, w.Code)
+			var res map[string]any
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+			require.True(t, strings.HasPrefix(res["value"].(string), c.want), res["value"])
+		})
+	}
+	// only the allowed feedback is replied to the channel
+	require.Len(t, f.Activities(), 1)
+}
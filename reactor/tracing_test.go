@@ -0,0 +1,88 @@
+package reactor
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func setupTestTracing(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	prevTP, prevProp := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(prevTP)
+		otel.SetTextMapPropagator(prevProp)
+	})
+	return recorder
+}
+
+func TestHandlerTracing(t *testing.T) {
+	recorder := setupTestTracing(t)
+	bs, err := os.ReadFile("testdata/anomaly.json")
+	require.NoError(t, err)
+	h := &Handler{
+		notifiers:      []Notifier{&recordingNotifier{}},
+		logger:         slog.Default(),
+		router:         mux.NewRouter(),
+		graphGenerator: newTestGraphGenerator(t),
+	}
+	h.router.HandleFunc("/amazon-sns", h.handleAmazonSNS).Methods(http.MethodPost)
+
+	const traceparent = "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"
+	req := httptest.NewRequest(http.MethodPost, "/amazon-sns", bytes.NewReader(bs))
+	req.Header.Set("traceparent", traceparent)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	spans := recorder.Ended()
+	var root sdktrace.ReadOnlySpan
+	var graphs []sdktrace.ReadOnlySpan
+	for _, s := range spans {
+		switch s.Name() {
+		case "handleAmazonSNS":
+			root = s
+		case "generateGraph":
+			graphs = append(graphs, s)
+		}
+	}
+	require.NotNil(t, root)
+	require.Equal(t, "0af7651916cd43dd8448eb211c80319c", root.SpanContext().TraceID().String())
+	require.Equal(t, "b7ad6b7169203331", root.Parent().SpanID().String())
+	require.Len(t, graphs, 1)
+	require.Equal(t, root.SpanContext().TraceID(), graphs[0].SpanContext().TraceID())
+	require.Contains(t, graphs[0].Attributes(), attribute.String("root_cause.service", "Amazon Relational Database Service"))
+}
+
+func TestTraceContextWorkerHandOff(t *testing.T) {
+	setupTestTracing(t)
+	ctx, span := startSpan(context.Background(), "processInteractiveMessage")
+	defer span.End()
+
+	// canyon sends the request headers along with the request to the worker
+	header := http.Header{}
+	injectTraceContext(ctx, header)
+	req := httptest.NewRequest(http.MethodPost, "/slack/events", nil)
+	req.Header = header
+	got := trace.SpanContextFromContext(extractTraceContext(req))
+	require.True(t, got.IsRemote())
+	require.Equal(t, span.SpanContext().TraceID(), got.TraceID())
+	require.Equal(t, span.SpanContext().SpanID(), got.SpanID())
+}
@@ -0,0 +1,277 @@
+package reactor
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/Songmu/flextime"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/google/uuid"
+)
+
+// Webhook event types.
+const (
+	WebhookEventAnomalyDetected  = "anomaly.detected"
+	WebhookEventAnomalyUpdated   = "anomaly.updated"
+	WebhookEventFeedbackProvided = "anomaly.feedback"
+)
+
+// Headers set on every webhook delivery. The signature is the hex encoded
+// HMAC-SHA256 of "<timestamp>.<body>" keyed with the endpoint secret, prefixed
+// with "sha256=".
+const (
+	WebhookHeaderEvent     = "X-Reactor-Event"
+	WebhookHeaderDelivery  = "X-Reactor-Delivery"
+	WebhookHeaderTimestamp = "X-Reactor-Timestamp"
+	WebhookHeaderSignature = "X-Reactor-Signature"
+)
+
+// WebhookEndpoint is an HTTP endpoint receiving WebhookEvents as JSON.
+type WebhookEndpoint struct {
+	URL string `json:"url"`
+	// Secret signs the deliveries. Deliveries are not signed when empty.
+	Secret string        `json:"secret,omitempty"`
+	Filter WebhookFilter `json:"filter,omitempty"`
+}
+
+// WebhookFilter selects the events delivered to a WebhookEndpoint. The zero
+// value matches every event.
+type WebhookFilter struct {
+	// Events limits the event types. Empty means all types.
+	Events []string `json:"events,omitempty"`
+	// AccountIDs limits the anomalies to the given accounts, matching the
+	// anomaly account or any root-cause linked account.
+	AccountIDs []string `json:"accountIds,omitempty"`
+	// Services limits the anomalies to those with a root cause in the given
+	// services.
+	Services []string `json:"services,omitempty"`
+	// MinTotalImpact and MinTotalImpactPercentage drop anomalies below the
+	// thresholds.
+	MinTotalImpact           float64 `json:"minTotalImpact,omitempty"`
+	MinTotalImpactPercentage float64 `json:"minTotalImpactPercentage,omitempty"`
+}
+
+func (f WebhookFilter) hasAnomalyConditions() bool {
+	return len(f.AccountIDs) > 0 || len(f.Services) > 0 || f.MinTotalImpact > 0 || f.MinTotalImpactPercentage > 0
+}
+
+// Match reports whether ev passes the filter. Events without an anomaly
+// (e.g. feedback) never match a filter with anomaly conditions.
+func (f WebhookFilter) Match(ev *WebhookEvent) bool {
+	if len(f.Events) > 0 && !slices.Contains(f.Events, ev.Type) {
+		return false
+	}
+	if !f.hasAnomalyConditions() {
+		return true
+	}
+	a := ev.Anomaly
+	if a == nil {
+		return false
+	}
+	if a.Impact.TotalImpact < f.MinTotalImpact {
+		return false
+	}
+	if a.Impact.TotalImpactPercentage < f.MinTotalImpactPercentage {
+		return false
+	}
+	if len(f.AccountIDs) > 0 {
+		matched := slices.Contains(f.AccountIDs, a.AccountID)
+		for _, rc := range a.RootCauses {
+			matched = matched || slices.Contains(f.AccountIDs, rc.LinkedAccount)
+		}
+		if !matched {
+			return false
+		}
+	}
+	if len(f.Services) > 0 {
+		matched := false
+		for _, rc := range a.RootCauses {
+			matched = matched || slices.Contains(f.Services, rc.Service)
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+// WebhookEvent is the JSON payload delivered to webhook endpoints.
+type WebhookEvent struct {
+	Type      string `json:"type"`
+	Timestamp int64  `json:"timestamp"`
+	AnomalyID string `json:"anomalyId"`
+	// Anomaly is set for anomaly.detected and anomaly.updated events.
+	Anomaly     *Anomaly         `json:"anomaly,omitempty"`
+	MonitorID   string           `json:"monitorId,omitempty"`
+	AccountName string           `json:"accountName,omitempty"`
+	Feedback    *WebhookFeedback `json:"feedback,omitempty"`
+}
+
+// WebhookFeedback describes the feedback of an anomaly.feedback event.
+type WebhookFeedback struct {
+	// Type is the Cost Anomaly Detection feedback type (YES, NO or
+	// PLANNED_ACTIVITY).
+	Type string `json:"type"`
+	User string `json:"user,omitempty"`
+	// Source is the channel the feedback was given on (slack or teams).
+	Source string `json:"source"`
+}
+
+// WebhookSink delivers WebhookEvents to the configured endpoints.
+type WebhookSink struct {
+	endpoints   []WebhookEndpoint
+	client      *http.Client
+	retryPolicy RetryPolicy
+	logger      *slog.Logger
+}
+
+// NewWebhookSink returns a WebhookSink delivering to endpoints. Every
+// delivery is retried according to policy.
+func NewWebhookSink(endpoints []WebhookEndpoint, policy RetryPolicy, logger *slog.Logger) *WebhookSink {
+	return &WebhookSink{
+		endpoints:   endpoints,
+		client:      http.DefaultClient,
+		retryPolicy: policy,
+		logger:      logger,
+	}
+}
+
+// ParseWebhookEndpoints parses a JSON array of WebhookEndpoint, as given in
+// the WEBHOOK_ENDPOINTS environment variable.
+func ParseWebhookEndpoints(str string) ([]WebhookEndpoint, error) {
+	var endpoints []WebhookEndpoint
+	if err := json.Unmarshal([]byte(str), &endpoints); err != nil {
+		return nil, fmt.Errorf("failed to parse webhook endpoints: %w", err)
+	}
+	for i, e := range endpoints {
+		if e.URL == "" {
+			return nil, fmt.Errorf("webhook endpoint #%d: url is required", i)
+		}
+	}
+	return endpoints, nil
+}
+
+// Send delivers ev to every endpoint whose filter matches it.
+func (s *WebhookSink) Send(ctx context.Context, ev *WebhookEvent) error {
+	body, err := json.Marshal(ev)
+	if err != nil {
+		return fmt.Errorf("failed to marshal webhook event: %w", err)
+	}
+	var errs []error
+	for _, e := range s.endpoints {
+		if !e.Filter.Match(ev) {
+			s.logger.DebugContext(ctx, "skip webhook endpoint", "url", e.URL, "type", ev.Type, "anomaly_id", ev.AnomalyID)
+			continue
+		}
+		delivery := uuid.NewString()
+		err := s.retryPolicy.onlyThrottled().Do(ctx, func(ctx context.Context) error {
+			return s.deliver(ctx, e, ev.Type, delivery, body)
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("webhook %s: %w", e.URL, err))
+			continue
+		}
+		s.logger.InfoContext(ctx, "delivered webhook", "url", e.URL, "type", ev.Type, "anomaly_id", ev.AnomalyID, "delivery", delivery)
+	}
+	return errors.Join(errs...)
+}
+
+func (s *WebhookSink) deliver(ctx context.Context, e WebhookEndpoint, eventType string, delivery string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	timestamp := strconv.FormatInt(flextime.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookHeaderEvent, eventType)
+	req.Header.Set(WebhookHeaderDelivery, delivery)
+	req.Header.Set(WebhookHeaderTimestamp, timestamp)
+	if e.Secret != "" {
+		req.Header.Set(WebhookHeaderSignature, SignWebhookPayload(e.Secret, timestamp, body))
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	bs, err := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return &httpStatusError{
+			Method:     http.MethodPost,
+			URL:        e.URL,
+			StatusCode: resp.StatusCode,
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+			Body:       string(bs),
+		}
+	}
+	return nil
+}
+
+// SignWebhookPayload returns the X-Reactor-Signature header value for body.
+// Receivers recompute it with the shared secret to verify a delivery.
+func SignWebhookPayload(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func (h *Handler) sendAnomalyWebhook(ctx context.Context, a Anomaly, data TemplateData, updated bool) {
+	if h.webhooks == nil {
+		return
+	}
+	ev := &WebhookEvent{
+		Type:      WebhookEventAnomalyDetected,
+		Timestamp: flextime.Now().Unix(),
+		AnomalyID: a.AnomalyID,
+		Anomaly:   &a,
+		MonitorID: data.MonitorID,
+	}
+	if updated {
+		ev.Type = WebhookEventAnomalyUpdated
+	}
+	if h.graphGenerator != nil && a.AccountID != "" {
+		if out, err := h.graphGenerator.describeAccount(ctx, a.AccountID); err == nil && out.Account != nil {
+			ev.AccountName = aws.ToString(out.Account.Name)
+		} else if err != nil {
+			h.logger.DebugContext(ctx, "failed to describe account for webhook", "account_id", a.AccountID, "error", err)
+		}
+	}
+	if err := h.webhooks.Send(ctx, ev); err != nil {
+		h.logger.ErrorContext(ctx, "failed to send webhook", "anomaly_id", a.AnomalyID, "error", err)
+	}
+}
+
+func (h *Handler) sendFeedbackWebhook(ctx context.Context, anomalyID string, actionID string, user string, source string) {
+	if h.webhooks == nil {
+		return
+	}
+	ev := &WebhookEvent{
+		Type:      WebhookEventFeedbackProvided,
+		Timestamp: flextime.Now().Unix(),
+		AnomalyID: anomalyID,
+		Feedback: &WebhookFeedback{
+			Type:   feedbackLabel(actionID),
+			User:   user,
+			Source: source,
+		},
+	}
+	if err := h.webhooks.Send(ctx, ev); err != nil {
+		h.logger.ErrorContext(ctx, "failed to send webhook", "anomaly_id", anomalyID, "error", err)
+	}
+}
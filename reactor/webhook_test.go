@@ -0,0 +1,145 @@
+package reactor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhookFilterMatch(t *testing.T) {
+	a := Anomaly{
+		AccountID: "123456789012",
+		Impact:    AnomalyImpact{TotalImpact: 120, TotalImpactPercentage: 30},
+		RootCauses: []RootCause{
+			{LinkedAccount: "210987654321", Service: "Amazon Elastic Compute Cloud - Compute"},
+		},
+	}
+	detected := &WebhookEvent{Type: WebhookEventAnomalyDetected, AnomalyID: "a", Anomaly: &a}
+	feedback := &WebhookEvent{Type: WebhookEventFeedbackProvided, AnomalyID: "a", Feedback: &WebhookFeedback{Type: "YES"}}
+	cases := []struct {
+		name     string
+		filter   WebhookFilter
+		ev       *WebhookEvent
+		expected bool
+	}{
+		{name: "zero value", ev: detected, expected: true},
+		{name: "zero value feedback", ev: feedback, expected: true},
+		{name: "event type", filter: WebhookFilter{Events: []string{WebhookEventFeedbackProvided}}, ev: detected, expected: false},
+		{name: "min total impact", filter: WebhookFilter{MinTotalImpact: 100}, ev: detected, expected: true},
+		{name: "below min total impact", filter: WebhookFilter{MinTotalImpact: 200}, ev: detected, expected: false},
+		{name: "below min percentage", filter: WebhookFilter{MinTotalImpactPercentage: 50}, ev: detected, expected: false},
+		{name: "linked account", filter: WebhookFilter{AccountIDs: []string{"210987654321"}}, ev: detected, expected: true},
+		{name: "other account", filter: WebhookFilter{AccountIDs: []string{"000000000000"}}, ev: detected, expected: false},
+		{name: "service", filter: WebhookFilter{Services: []string{"Amazon Elastic Compute Cloud - Compute"}}, ev: detected, expected: true},
+		{name: "other service", filter: WebhookFilter{Services: []string{"AmazonCloudWatch"}}, ev: detected, expected: false},
+		{name: "feedback with anomaly conditions", filter: WebhookFilter{MinTotalImpact: 1}, ev: feedback, expected: false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			require.Equal(t, c.expected, c.filter.Match(c.ev))
+		})
+	}
+}
+
+func TestWebhookSinkSend(t *testing.T) {
+	var mu sync.Mutex
+	var attempts int
+	var received []http.Header
+	var bodies [][]byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		bs, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		mu.Lock()
+		defer mu.Unlock()
+		attempts++
+		if attempts == 1 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		received = append(received, r.Header.Clone())
+		bodies = append(bodies, bs)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	sink := NewWebhookSink([]WebhookEndpoint{
+		{URL: srv.URL, Secret: "s3cr3t"},
+		{URL: srv.URL, Filter: WebhookFilter{Events: []string{WebhookEventFeedbackProvided}}},
+	}, RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond}, slog.Default())
+	a := loadTestAnomaly(t, "testdata/anomaly.json")
+	err := sink.Send(context.Background(), &WebhookEvent{
+		Type:      WebhookEventAnomalyDetected,
+		Timestamp: 1700000000,
+		AnomalyID: a.AnomalyID,
+		Anomaly:   &a,
+	})
+	require.NoError(t, err)
+	require.Equal(t, 2, attempts)
+	require.Len(t, received, 1)
+	h := received[0]
+	require.Equal(t, WebhookEventAnomalyDetected, h.Get(WebhookHeaderEvent))
+	require.NotEmpty(t, h.Get(WebhookHeaderDelivery))
+	require.Equal(t, SignWebhookPayload("s3cr3t", h.Get(WebhookHeaderTimestamp), bodies[0]), h.Get(WebhookHeaderSignature))
+	var ev WebhookEvent
+	require.NoError(t, json.Unmarshal(bodies[0], &ev))
+	require.Equal(t, a.AnomalyID, ev.AnomalyID)
+	require.Equal(t, a, *ev.Anomaly)
+}
+
+func TestParseWebhookEndpoints(t *testing.T) {
+	endpoints, err := ParseWebhookEndpoints(`[{"url":"https://example.com/hook","secret":"x","filter":{"events":["anomaly.detected"],"minTotalImpact":10}}]`)
+	require.NoError(t, err)
+	require.Equal(t, []WebhookEndpoint{{
+		URL:    "https://example.com/hook",
+		Secret: "x",
+		Filter: WebhookFilter{Events: []string{WebhookEventAnomalyDetected}, MinTotalImpact: 10},
+	}}, endpoints)
+	_, err = ParseWebhookEndpoints(`[{"secret":"x"}]`)
+	require.Error(t, err)
+}
+
+// failingNotifier fails to post anomalies.
+type failingNotifier struct {
+	recordingNotifier
+}
+
+func (n *failingNotifier) PostAnomaly(context.Context, TemplateData) (string, error) {
+	return "", errors.New("channel_not_found")
+}
+
+func TestHandlerAnomalyWebhookAfterPost(t *testing.T) {
+	var mu sync.Mutex
+	var events []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		events = append(events, r.Header.Get(WebhookHeaderEvent))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+	h := &Handler{
+		notifiers:      []Notifier{&failingNotifier{recordingNotifier{id: "T0123"}}},
+		logger:         slog.Default(),
+		graphGenerator: newTestGraphGenerator(t),
+		webhooks:       NewWebhookSink([]WebhookEndpoint{{URL: srv.URL}}, RetryPolicy{}, slog.Default()),
+	}
+	ctx := context.Background()
+	a := loadTestAnomaly(t, "testdata/anomaly.json")
+	// the account name is not looked up
+	a.AccountID = ""
+	require.ErrorContains(t, h.postAnomalyDetectedMessage(ctx, a), "channel_not_found")
+	require.Empty(t, events)
+
+	h.notifiers = []Notifier{&recordingNotifier{id: "T0123"}}
+	require.NoError(t, h.postAnomalyDetectedMessage(ctx, a))
+	require.Equal(t, []string{WebhookEventAnomalyDetected}, events)
+}